@@ -3,7 +3,10 @@ package config
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,12 +15,24 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Login    LoginConfig
+	Password PasswordConfig
+	Notify   NotifyConfig
+	TOTP     TOTPConfig
+	WebAuthn WebAuthnConfig
 	Upload   UploadConfig
+	Media    MediaConfig
+	Jobs     JobsConfig
+	Stream   StreamConfig
+	Export   ExportConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
+	// Reverse proxies whose X-Forwarded-For header is believed. Without
+	// any, the client address is the connection's remote address.
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -30,13 +45,82 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry string
+	Secret     string
+	Expiry     time.Duration // lifetime of access tokens
+	RefreshTTL time.Duration // idle lifetime of a login session
+}
+
+type LoginConfig struct {
+	MaxAttempts   int           // failed passwords before a username is locked
+	IPMaxAttempts int           // failed passwords before a client address is locked
+	LockoutBase   time.Duration // first lockout, doubled by each further failure
+	LockoutMax    time.Duration // longest lockout; failures are forgotten after this long
+}
+
+type PasswordConfig struct {
+	MinLength    int
+	RejectCommon bool // reject passwords from the embedded common list
+	History      int  // recent passwords that cannot be reused, 0 to allow reuse
+	ResetTTL     time.Duration
+}
+
+type NotifyConfig struct {
+	Backend    string // "display" (shown to the supervisor), "log" or "webhook"
+	WebhookURL string
+}
+
+type TOTPConfig struct {
+	Issuer        string        // account label shown by authenticator apps
+	RequiredRoles []string      // roles that must enroll before using the app
+	ChallengeTTL  time.Duration // time to enter a code after the password
+}
+
+type WebAuthnConfig struct {
+	RPID      string   // domain the passkeys are bound to
+	RPName    string   // name shown by the authenticator
+	RPOrigins []string // origins the frontend is served from
 }
 
 type UploadConfig struct {
 	Dir         string
+	TempDir     string
 	MaxFileSize int64
+	ChunkSize   int64
+	SessionTTL  time.Duration
+	Storage     string // "local" or "s3"
+	S3          S3Config
+}
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+}
+
+type MediaConfig struct {
+	FFmpegPath     string
+	ThumbnailWidth int
+	SpriteFrames   int  // 0 disables sprite strips
+	Transcode      bool // produce normalized MP4 renditions
+	HLS            bool // produce adaptive HLS packages
+}
+
+type JobsConfig struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+}
+
+type StreamConfig struct {
+	SigningKey string // falls back to a key derived from the JWT secret
+	URLTTL     time.Duration
+}
+
+type ExportConfig struct {
+	TTL time.Duration // how long finished export archives are kept
 }
 
 var AppConfig *Config
@@ -47,10 +131,13 @@ func LoadConfig() {
 		log.Println("Warning: config.env file not found, using environment variables")
 	}
 
+	uploadDir := getEnv("UPLOAD_DIR", "./uploads")
+
 	AppConfig = &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
-			Host: getEnv("HOST", "0.0.0.0"), // Allow network access
+			Port:           getEnv("PORT", "8080"),
+			Host:           getEnv("HOST", "0.0.0.0"), // Allow network access
+			TrustedProxies: getEnvAsList("TRUSTED_PROXIES", nil),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -61,12 +148,70 @@ func LoadConfig() {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
-			Expiry: getEnv("JWT_EXPIRY", "24h"),
+			Secret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
+			Expiry:     getEnvAsDuration("JWT_EXPIRY", 15*time.Minute),
+			RefreshTTL: getEnvAsDuration("JWT_REFRESH_TTL", 30*24*time.Hour),
+		},
+		Login: LoginConfig{
+			MaxAttempts:   getEnvAsInt("LOGIN_MAX_ATTEMPTS", 5),
+			IPMaxAttempts: getEnvAsInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+			LockoutBase:   getEnvAsDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+			LockoutMax:    getEnvAsDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+		},
+		Password: PasswordConfig{
+			MinLength:    getEnvAsInt("PASSWORD_MIN_LENGTH", 10),
+			RejectCommon: getEnvAsBool("PASSWORD_REJECT_COMMON", true),
+			History:      getEnvAsInt("PASSWORD_HISTORY", 5),
+			ResetTTL:     getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+		},
+		Notify: NotifyConfig{
+			Backend:    getEnv("NOTIFIER", "display"),
+			WebhookURL: getEnv("NOTIFIER_WEBHOOK_URL", ""),
+		},
+		TOTP: TOTPConfig{
+			Issuer:        getEnv("TOTP_ISSUER", "RA Room Report"),
+			RequiredRoles: getEnvAsList("TOTP_REQUIRED_ROLES", []string{"manager", "supervisor"}),
+			ChallengeTTL:  getEnvAsDuration("TOTP_CHALLENGE_TTL", 5*time.Minute),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:      getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:    getEnv("WEBAUTHN_RP_NAME", "RA Room Report"),
+			RPOrigins: getEnvAsList("WEBAUTHN_RP_ORIGIN", []string{"http://localhost:3000"}),
 		},
 		Upload: UploadConfig{
-			Dir:         getEnv("UPLOAD_DIR", "./uploads"),
-			MaxFileSize: getEnvAsInt64("MAX_FILE_SIZE", 1073741824), // 1GB default
+			Dir:         uploadDir,
+			TempDir:     getEnv("UPLOAD_TEMP_DIR", filepath.Join(uploadDir, ".partial")),
+			MaxFileSize: getEnvAsInt64("MAX_FILE_SIZE", 1073741824),  // 1GB default
+			ChunkSize:   getEnvAsInt64("UPLOAD_CHUNK_SIZE", 5242880), // 5MB default
+			SessionTTL:  getEnvAsDuration("UPLOAD_SESSION_TTL", 24*time.Hour),
+			Storage:     getEnv("STORAGE_BACKEND", "local"),
+			S3: S3Config{
+				Endpoint:  getEnv("S3_ENDPOINT", "http://localhost:9000"),
+				Region:    getEnv("S3_REGION", "us-east-1"),
+				Bucket:    getEnv("S3_BUCKET", "videos"),
+				AccessKey: getEnv("S3_ACCESS_KEY", ""),
+				SecretKey: getEnv("S3_SECRET_KEY", ""),
+				PathStyle: getEnvAsBool("S3_PATH_STYLE", true),
+			},
+		},
+		Media: MediaConfig{
+			FFmpegPath:     getEnv("FFMPEG_PATH", "ffmpeg"),
+			ThumbnailWidth: getEnvAsInt("THUMBNAIL_WIDTH", 320),
+			SpriteFrames:   getEnvAsInt("THUMBNAIL_SPRITE_FRAMES", 10),
+			Transcode:      getEnvAsBool("TRANSCODE_ENABLED", true),
+			HLS:            getEnvAsBool("HLS_ENABLED", true),
+		},
+		Jobs: JobsConfig{
+			Workers:      getEnvAsInt("JOB_WORKERS", 2),
+			MaxAttempts:  getEnvAsInt("JOB_MAX_ATTEMPTS", 5),
+			PollInterval: getEnvAsDuration("JOB_POLL_INTERVAL", 2*time.Second),
+		},
+		Stream: StreamConfig{
+			SigningKey: getEnv("STREAM_SIGNING_KEY", ""),
+			URLTTL:     getEnvAsDuration("STREAM_URL_TTL", 4*time.Hour),
+		},
+		Export: ExportConfig{
+			TTL: getEnvAsDuration("EXPORT_TTL", 24*time.Hour),
 		},
 	}
 }
@@ -86,3 +231,44 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	}
 	return defaultValue
 }
+
+func getEnvAsInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList splits a comma separated value, ignoring empty entries
+func getEnvAsList(key string, defaultValue []string) []string {
+	var list []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	if len(list) == 0 {
+		return defaultValue
+	}
+	return list
+}
@@ -2,12 +2,14 @@ package config
 
 import (
 	"log"
+	"strings"
 
 	"trialuploadhk/backend/models"
 	"trialuploadhk/backend/utils"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -26,11 +28,7 @@ func InitDatabase() {
 	log.Println("Database connected successfully")
 
 	// Auto migrate tables
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Room{},
-		&models.Video{},
-	)
+	err = MigrateDatabase()
 
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
@@ -43,6 +41,9 @@ func InitDatabase() {
 
 	// Create sample rooms if not exists
 	createSampleRooms()
+
+	// Grant the default permissions if none are configured
+	createDefaultPermissions()
 }
 
 func createDefaultAdmin() {
@@ -61,8 +62,10 @@ func createDefaultAdmin() {
 			Username:     "admin",
 			Email:        "admin@raroomreport.com",
 			PasswordHash: passwordHash,
-			Role:         "supervisor",
+			Role:         models.RoleSupervisor,
 			IsActive:     true,
+
+			MustChangePassword: true,
 		}
 
 		result := DB.Create(&adminUser)
@@ -96,3 +99,85 @@ func createSampleRooms() {
 		log.Printf("Sample rooms created")
 	}
 }
+
+// createDefaultPermissions grants the defaults of every permission that has
+// not been seeded yet, so permissions added in an upgrade reach existing
+// databases while grants an admin revoked are left alone
+func createDefaultPermissions() {
+	var seeded []string
+	DB.Model(&models.SeededPermission{}).Pluck("permission", &seeded)
+
+	// Databases from before seeding was recorded: anything already granted
+	// to some role counts as seeded
+	if len(seeded) == 0 {
+		DB.Model(&models.RolePermission{}).Distinct().Pluck("permission", &seeded)
+		for _, permission := range seeded {
+			DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SeededPermission{Permission: permission})
+		}
+	}
+
+	done := make(map[string]bool, len(seeded))
+	for _, permission := range seeded {
+		done[permission] = true
+	}
+
+	var created []string
+	for _, info := range models.Permissions {
+		if done[info.Name] {
+			continue
+		}
+
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			for _, role := range models.Roles {
+				for _, permission := range models.DefaultRolePermissions[role] {
+					if permission != info.Name {
+						continue
+					}
+					grant := models.RolePermission{Role: role, Permission: permission}
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SeededPermission{Permission: info.Name}).Error
+		})
+		if err != nil {
+			log.Printf("Failed to create default grants for %s: %v", info.Name, err)
+			continue
+		}
+		created = append(created, info.Name)
+	}
+
+	if len(created) > 0 {
+		log.Printf("Default role permissions created for %s", strings.Join(created, ", "))
+	}
+}
+
+// MigrateDatabase creates or updates the table of every model
+func MigrateDatabase() error {
+	return DB.AutoMigrate(
+		&models.User{},
+		&models.Room{},
+		&models.Blob{},
+		&models.Video{},
+		&models.UploadSession{},
+		&models.UploadChunk{},
+		&models.Job{},
+		&models.Rendition{},
+		&models.AuditLog{},
+		&models.Export{},
+		&models.UserRoom{},
+		&models.UserFloor{},
+		&models.RolePermission{},
+		&models.SeededPermission{},
+		&models.Session{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.LoginThrottle{},
+		&models.PasswordHistory{},
+		&models.PasswordReset{},
+		&models.TOTPSecret{},
+		&models.RecoveryCode{},
+		&models.TwoFactorChallenge{},
+	)
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"trialuploadhk/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := MigrateDatabase(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+}
+
+// grantedRoles lists the roles holding permission
+func grantedRoles(permission string) []string {
+	var roles []string
+	DB.Model(&models.RolePermission{}).Where("permission = ?", permission).Order("role").Pluck("role", &roles)
+	return roles
+}
+
+// defaultRoles lists the roles DefaultRolePermissions grants permission to
+func defaultRoles(permission string) []string {
+	roles := []string{}
+	for role, permissions := range models.DefaultRolePermissions {
+		for _, p := range permissions {
+			if p == permission {
+				roles = append(roles, role)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func assertRoles(t *testing.T, permission string, want []string) {
+	t.Helper()
+	got := grantedRoles(permission)
+	if len(got) != len(want) {
+		t.Fatalf("%s granted to %v, want %v", permission, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s granted to %v, want %v", permission, got, want)
+		}
+	}
+}
+
+func TestCreateDefaultPermissionsOnNewDatabase(t *testing.T) {
+	openTestDB(t)
+	createDefaultPermissions()
+
+	for _, info := range models.Permissions {
+		assertRoles(t, info.Name, defaultRoles(info.Name))
+	}
+}
+
+func TestCreateDefaultPermissionsAddsNewPermissionsOnUpgrade(t *testing.T) {
+	openTestDB(t)
+
+	// A database seeded before users.unlock and passwords.reset existed,
+	// where an admin has since revoked exports.create from managers
+	for role, permissions := range models.DefaultRolePermissions {
+		for _, permission := range permissions {
+			switch {
+			case permission == models.PermUsersUnlock, permission == models.PermPasswordsReset:
+				continue
+			case permission == models.PermExportsCreate && role == models.RoleManager:
+				continue
+			}
+			DB.Create(&models.RolePermission{Role: role, Permission: permission})
+		}
+	}
+
+	createDefaultPermissions()
+
+	assertRoles(t, models.PermUsersUnlock, defaultRoles(models.PermUsersUnlock))
+	assertRoles(t, models.PermPasswordsReset, defaultRoles(models.PermPasswordsReset))
+	assertRoles(t, models.PermExportsCreate, []string{models.RoleSupervisor})
+}
+
+func TestCreateDefaultPermissionsKeepsRevokedGrants(t *testing.T) {
+	openTestDB(t)
+	createDefaultPermissions()
+
+	DB.Where("permission = ?", models.PermUsersUnlock).Delete(&models.RolePermission{})
+	DB.Where("permission = ? AND role = ?", models.PermVideosDeleteAny, models.RoleManager).Delete(&models.RolePermission{})
+
+	// Restarting must not hand the revoked grants back
+	createDefaultPermissions()
+	createDefaultPermissions()
+
+	assertRoles(t, models.PermUsersUnlock, nil)
+	assertRoles(t, models.PermVideosDeleteAny, []string{models.RoleSupervisor})
+}
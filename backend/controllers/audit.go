@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit stores an audit entry for the authenticated user. Failures
+// are logged rather than failing the request being audited.
+func recordAudit(c *gin.Context, action string, videoID *uint, detail string) {
+	principal := middleware.CurrentPrincipal(c)
+	recordAuditAs(c, principal.UserID, principal.Username, action, videoID, detail)
+}
+
+// recordAuditAs stores an audit entry for a user who is not signed in, such
+// as one redeeming a password reset code
+func recordAuditAs(c *gin.Context, userID uint, username, action string, videoID *uint, detail string) {
+	entry := models.AuditLog{
+		UserID:    userID,
+		Username:  username,
+		Action:    action,
+		VideoID:   videoID,
+		Detail:    detail,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 255),
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit entry %s for user %d: %v", action, entry.UserID, err)
+	}
+}
+
+// GetAuditLogs lists audit entries, newest first, optionally filtered by
+// action, user_id or video_id
+func GetAuditLogs(c *gin.Context) {
+	query := config.DB.Order("id DESC")
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if videoID := c.Query("video_id"); videoID != "" {
+		query = query.Where("video_id = ?", videoID)
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed > 200 {
+			parsed = 200
+		}
+		limit = parsed
+	}
+
+	var list []models.AuditLog
+	if err := query.Limit(limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit_logs": list,
+	})
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -24,41 +24,86 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	if until := addressLockedUntil(ip); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+
 	// Find user by username
 	var user models.User
 	result := config.DB.Where("username = ? AND is_active = ?", req.Username, true).First(&user)
 	if result.Error != nil {
+		recordLoginFailure(nil, ip)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	if until := userLockedUntil(&user); until != nil {
+		respondLocked(c, *until)
+		return
+	}
 
 	// Check password
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		recordLoginFailure(&user, ip)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
+	// Passwords set before the current policy must be replaced
+	if !user.MustChangePassword && passwordPolicy().Validate(req.Password) != nil {
+		if err := config.DB.Model(&user).UpdateColumn("must_change_password", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+		user.MustChangePassword = true
+	}
+
+	// The failures are only cleared once the second factor is verified too
+	if user.TwoFactorEnabled {
+		startTwoFactorChallenge(c, &user)
+		return
+	}
+	clearLoginFailures(&user)
+
+	completeLogin(c, &user)
+}
+
+// completeLogin opens a session for an authenticated user and responds
+// with its tokens. Every login method ends here.
+func completeLogin(c *gin.Context, user *models.User) {
+	token, refreshToken, err := startSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
+		"message":       "Login successful",
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(config.AppConfig.JWT.Expiry.Seconds()),
 		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-			"role":     user.Role,
+			"id":                   user.ID,
+			"username":             user.Username,
+			"email":                user.Email,
+			"role":                 user.Role,
+			"permissions":          grantedPermissions(user.Role),
+			"must_change_password": user.MustChangePassword,
+
+			"two_factor_enabled":        user.TwoFactorEnabled,
+			"two_factor_setup_required": middleware.TwoFactorRequired(user.Role) && !user.TwoFactorEnabled,
 		},
 	})
 }
 
-// Logout handles user logout
+// Logout revokes the session of the access token, so neither it nor the
+// session's refresh token can be used again
 func Logout(c *gin.Context) {
+	if err := revokeSession(config.DB, middleware.CurrentPrincipal(c).SessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
@@ -67,7 +112,39 @@ func AuthMiddleware() gin.HandlerFunc {
 	return middleware.AuthMiddleware()
 }
 
+// PasswordChangeMiddleware wrapper for middleware
+func PasswordChangeMiddleware() gin.HandlerFunc {
+	return middleware.PasswordChangeMiddleware()
+}
+
+// TwoFactorSetupMiddleware wrapper for middleware
+func TwoFactorSetupMiddleware() gin.HandlerFunc {
+	return middleware.TwoFactorSetupMiddleware()
+}
+
+// SignedURLMiddleware wrapper for middleware
+func SignedURLMiddleware() gin.HandlerFunc {
+	return middleware.SignedURLMiddleware()
+}
+
+// RequirePermission wrapper for middleware
+func RequirePermission(permission string) gin.HandlerFunc {
+	return middleware.RequirePermission(permission)
+}
+
 // RoleMiddleware wrapper for middleware
 func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	return middleware.RoleMiddleware(roles...)
 }
+
+// grantedPermissions lists the permissions a role holds, so clients can
+// decide which features to offer
+func grantedPermissions(role string) []string {
+	granted := []string{}
+	for _, p := range models.Permissions {
+		if middleware.RoleHasPermission(role, p.Name) {
+			granted = append(granted, p.Name)
+		}
+	}
+	return granted
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"gorm.io/gorm"
+)
+
+// spooledFile is upload content written to local disk and hashed on the way
+type spooledFile struct {
+	File *os.File
+	Hash string
+	Size int64
+
+	temporary bool
+}
+
+// Close releases the spooled file, deleting it if it was a temporary copy
+func (s *spooledFile) Close() {
+	s.File.Close()
+	if s.temporary {
+		os.Remove(s.File.Name())
+	}
+}
+
+// spoolContent computes the SHA-256 of src while streaming it to a file in
+// the temp directory. Files that already live on disk (assembled resumable
+// uploads) are hashed in place instead of being copied again.
+func spoolContent(src io.Reader) (*spooledFile, error) {
+	hasher := sha256.New()
+
+	if file, ok := src.(*os.File); ok {
+		size, err := io.Copy(hasher, file)
+		if err != nil {
+			return nil, fmt.Errorf("hash file: %w", err)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return &spooledFile{File: file, Hash: hex.EncodeToString(hasher.Sum(nil)), Size: size}, nil
+	}
+
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return nil, fmt.Errorf("create temp directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(config.AppConfig.Upload.TempDir, "spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("spool upload: %w", err)
+	}
+
+	return &spooledFile{File: tmp, Hash: hex.EncodeToString(hasher.Sum(nil)), Size: size, temporary: true}, nil
+}
+
+// blobLocks are striped by the first byte of the content hash. Storing a
+// blob and deleting the content of a released one both happen under the
+// hash's lock, so a release cannot remove content that an upload of the
+// same file has just stored.
+var blobLocks [256]sync.Mutex
+
+// lockBlob holds the lock for hash until the returned func is called
+func lockBlob(hash string) func() {
+	stripe, _ := strconv.ParseUint(hash[:2], 16, 8)
+	lock := &blobLocks[stripe]
+	lock.Lock()
+	return lock.Unlock
+}
+
+// blobKey is the storage key for content with the given SHA-256
+func blobKey(hash string) string {
+	return path.Join("objects", hash[:2], hash)
+}
+
+// acquireBlob returns the blob holding the spooled content with its
+// reference count already incremented, uploading the content only when no
+// blob with the same hash exists yet. Lookups are retried when a concurrent
+// upload or delete changes the blob underneath us.
+func acquireBlob(spool *spooledFile) (*models.Blob, error) {
+	defer lockBlob(spool.Hash)()
+
+	for attempt := 0; attempt < 3; attempt++ {
+		var blob models.Blob
+		err := config.DB.Where("hash = ?", spool.Hash).First(&blob).Error
+		if err == nil {
+			result := config.DB.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1"))
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if result.RowsAffected == 1 {
+				blob.RefCount++
+				return &blob, nil
+			}
+			// The blob was released in the meantime
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if _, err := spool.File.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		key := blobKey(spool.Hash)
+		if _, err := storage.Service.Upload(key, spool.File); err != nil {
+			return nil, fmt.Errorf("store blob: %w", err)
+		}
+
+		blob = models.Blob{Hash: spool.Hash, Size: spool.Size, StorageKey: key, RefCount: 1}
+		if err := config.DB.Create(&blob).Error; err == nil {
+			return &blob, nil
+		}
+		// Another upload of the same content created the blob first
+	}
+
+	return nil, fmt.Errorf("acquire blob %s: too many concurrent changes", spool.Hash)
+}
+
+// releaseBlob drops one reference inside tx and reports whether the blob
+// row was removed, in which case the caller deletes its storage key once
+// the transaction has committed.
+func releaseBlob(tx *gorm.DB, blobID uint) (*models.Blob, bool, error) {
+	// Decrement in the database rather than from a value read earlier, so
+	// concurrent releases cannot both see the same count
+	result := tx.Model(&models.Blob{}).Where("id = ?", blobID).Update("ref_count", gorm.Expr("ref_count - 1"))
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+
+	var blob models.Blob
+	if err := tx.First(&blob, blobID).Error; err != nil {
+		return nil, false, err
+	}
+
+	// Only the release that brings the count to zero removes the row, and
+	// not if an upload has acquired the blob again in the meantime
+	result = tx.Where("id = ? AND ref_count <= 0", blobID).Delete(&models.Blob{})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	return &blob, result.RowsAffected == 1, nil
+}
+
+// deleteBlobContent removes a released blob and the files generated from
+// it from storage
+func deleteBlobContent(blob *models.Blob) {
+	defer lockBlob(blob.Hash)()
+
+	// The same content may have been uploaded again since the release
+	// committed, in which case the key holds live content
+	var count int64
+	if err := config.DB.Model(&models.Blob{}).Where("hash = ?", blob.Hash).Count(&count).Error; err != nil {
+		log.Printf("Failed to check blob %s before deleting it: %v", blob.StorageKey, err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	if err := storage.Service.Delete(blob.StorageKey); err != nil {
+		log.Printf("Failed to delete blob %s: %v", blob.StorageKey, err)
+	}
+	deleteDerivedContent(derivedPrefixForHash(blob.Hash))
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"gorm.io/gorm"
+)
+
+// newTestBlob stores content as a blob with no references yet
+func newTestBlob(t *testing.T, hash string) *models.Blob {
+	t.Helper()
+	key := blobKey(hash)
+	if _, err := storage.Service.Upload(key, strings.NewReader(hash)); err != nil {
+		t.Fatalf("store blob: %v", err)
+	}
+	blob := models.Blob{Hash: hash, Size: int64(len(hash)), StorageKey: key}
+	if err := config.DB.Create(&blob).Error; err != nil {
+		t.Fatalf("create blob: %v", err)
+	}
+	return &blob
+}
+
+func blobStored(blob *models.Blob) bool {
+	_, err := storage.Service.Stat(blob.StorageKey)
+	return !errors.Is(err, storage.ErrNotFound)
+}
+
+func TestDeleteVideoReleasesSharedBlob(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	blob := newTestBlob(t, strings.Repeat("ab", 32))
+
+	var videos []*models.Video
+	for i := 0; i < 2; i++ {
+		video, _ := newTestVideo(t, user, nil, 10)
+		config.DB.Model(video).Updates(map[string]interface{}{"blob_id": blob.ID, "file_path": blob.StorageKey})
+		config.DB.Model(blob).Update("ref_count", gorm.Expr("ref_count + 1"))
+		videos = append(videos, video)
+	}
+
+	assertStatus(t, performRequest(t, user, http.MethodDelete, "/videos/:id", fmt.Sprintf("/videos/%d", videos[0].ID), nil, DeleteVideo), http.StatusOK)
+	var remaining models.Blob
+	if err := config.DB.First(&remaining, blob.ID).Error; err != nil {
+		t.Fatalf("blob was removed while video %d still uses it: %v", videos[1].ID, err)
+	}
+	if remaining.RefCount != 1 || !blobStored(blob) {
+		t.Fatalf("after the first delete ref_count = %d, stored = %v; want 1 and stored", remaining.RefCount, blobStored(blob))
+	}
+
+	assertStatus(t, performRequest(t, user, http.MethodDelete, "/videos/:id", fmt.Sprintf("/videos/%d", videos[1].ID), nil, DeleteVideo), http.StatusOK)
+	if err := config.DB.First(&remaining, blob.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("blob row after the last delete: %v", err)
+	}
+	if blobStored(blob) {
+		t.Fatal("blob content is still stored after the last delete")
+	}
+}
+
+func TestReleaseBlobConcurrently(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	const references = 8
+	blob := newTestBlob(t, strings.Repeat("cd", 32))
+	config.DB.Model(blob).Update("ref_count", references)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	removed := 0
+	for i := 0; i < references; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := releaseBlob(config.DB, blob.ID)
+			if err != nil {
+				t.Errorf("releaseBlob: %v", err)
+			}
+			if ok {
+				mu.Lock()
+				removed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if removed != 1 {
+		t.Fatalf("%d releases removed the blob, want exactly 1", removed)
+	}
+	if err := config.DB.First(&models.Blob{}, blob.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("blob row after the last release: %v", err)
+	}
+}
+
+func TestReleaseMissingBlob(t *testing.T) {
+	setupTestDB(t)
+	if _, _, err := releaseBlob(config.DB, 42); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("releaseBlob of a missing blob = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestReleasedBlobContentSurvivesReupload(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	config.AppConfig.Upload.TempDir = t.TempDir()
+
+	acquire := func() *models.Blob {
+		t.Helper()
+		spool, err := spoolContent(strings.NewReader("the same footage"))
+		if err != nil {
+			t.Fatalf("spool: %v", err)
+		}
+		defer spool.Close()
+		blob, err := acquireBlob(spool)
+		if err != nil {
+			t.Fatalf("acquireBlob: %v", err)
+		}
+		return blob
+	}
+
+	blob := acquire()
+	released, removed, err := releaseBlob(config.DB, blob.ID)
+	if err != nil || !removed {
+		t.Fatalf("releaseBlob = %v, %v; want the blob removed", removed, err)
+	}
+
+	// The same file is uploaded again after the release committed but
+	// before its content was deleted
+	again := acquire()
+	deleteBlobContent(released)
+
+	object, _, err := storage.Service.Open(again.StorageKey)
+	if err != nil {
+		t.Fatalf("content of the re-uploaded blob: %v", err)
+	}
+	defer object.Close()
+	data, _ := io.ReadAll(object)
+	if string(data) != "the same footage" {
+		t.Fatalf("stored content = %q", data)
+	}
+
+	// Once the last reference is gone the content is removed as before
+	released, removed, err = releaseBlob(config.DB, again.ID)
+	if err != nil || !removed {
+		t.Fatalf("releaseBlob = %v, %v; want the blob removed", removed, err)
+	}
+	deleteBlobContent(released)
+	if blobStored(released) {
+		t.Fatal("content is still stored after the last release")
+	}
+}
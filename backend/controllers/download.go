@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadVideo sends the original upload as an attachment named after
+// the uploaded file. Range requests are honoured so interrupted downloads
+// can resume, and every download is recorded in the audit log.
+func DownloadVideo(c *gin.Context) {
+	var video models.Video
+	err := config.DB.Preload("Room").Scopes(visibleVideos(c)).Where("videos.id = ?", c.Param("id")).First(&video).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	file, info, err := storage.Service.Open(video.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video file not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open video %d: %v", video.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open video file"})
+		return
+	}
+	defer file.Close()
+
+	contentType := video.ContentType
+	if contentType == "" {
+		contentType = sniffStoredVideo(&video, file)
+	}
+
+	filename := downloadFilename(&video, contentType)
+	if c.Request.Method == http.MethodGet {
+		detail := "file " + filename
+		if byteRange := c.GetHeader("Range"); byteRange != "" {
+			detail += ", range " + byteRange
+		}
+		recordAudit(c, models.AuditVideoDownload, &video.ID, detail)
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "private, no-cache")
+	serveVideoContent(c, file, info.ModTime, contentType, contentETag(video.ContentHash, ""))
+}
+
+// downloadFilename is the name a video is saved under: the name it was
+// uploaded with, or one built from the room and recording time such as
+// room_101_2026-10-16_0930.mp4. An uploaded name that only refers to a
+// directory, such as "..", becomes video_<id>.mp4.
+func downloadFilename(video *models.Video, contentType string) string {
+	// Clients may send full paths, with either separator
+	uploaded := strings.TrimSpace(strings.ReplaceAll(video.OriginalFilename, "\\", "/"))
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(path.Base(uploaded)))
+	switch {
+	case uploaded == "" || name == "":
+		// No name was uploaded; one is built below
+	case name == "." || name == ".." || name == "/":
+		return fmt.Sprintf("video_%d%s", video.ID, downloadExtension(contentType))
+	default:
+		return name
+	}
+
+	recorded := video.UploadDate
+	if video.Metadata.CreationTime != nil {
+		recorded = *video.Metadata.CreationTime
+	}
+
+	room := video.Metadata.RoomNumber
+	if video.Room != nil {
+		room = video.Room.RoomNumber
+	}
+
+	prefix := "video"
+	if room != "" {
+		prefix = roomFolder(room)
+	}
+
+	return fmt.Sprintf("%s_%s%s", prefix, recorded.Local().Format("2006-01-02_1504"), downloadExtension(contentType))
+}
+
+// downloadExtension is the file extension for a video's content type
+func downloadExtension(contentType string) string {
+	if ext := media.Extension(contentType); ext != "" {
+		return ext
+	}
+	return ".mp4"
+}
+
+// roomFolder is "room_<number>" with characters unsafe in file names
+// replaced
+func roomFolder(room string) string {
+	return "room_" + strings.NewReplacer("/", "-", "\\", "-", " ", "-").Replace(room)
+}
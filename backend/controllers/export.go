@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExportRequest struct {
+	RoomIDs    []uint `json:"room_ids"`
+	UploadedBy *uint  `json:"uploaded_by"`
+	StartDate  string `json:"start_date"` // YYYY-MM-DD
+	EndDate    string `json:"end_date"`   // YYYY-MM-DD, inclusive
+	Async      bool   `json:"async"`
+}
+
+// CreateExport packages the videos matching the filters into a ZIP archive.
+// By default the archive is streamed in the response; with "async" it is
+// built in the background and downloaded from GET /exports/:id/download.
+func CreateExport(c *gin.Context) {
+	var req ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	filters, err := parseExportFilters(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	videos, err := exportVideos(filters, visibleVideos(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
+		return
+	}
+	if len(videos) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No videos match the filters"})
+		return
+	}
+
+	filterJSON, _ := json.Marshal(filters)
+	recordAudit(c, models.AuditVideoExport, nil, fmt.Sprintf("%d videos, filters %s", len(videos), filterJSON))
+
+	if req.Async {
+		export := models.Export{
+			UserID:     middleware.CurrentPrincipal(c).UserID,
+			Filters:    filters,
+			Status:     models.ExportStatusProcessing,
+			VideoCount: len(videos),
+			ExpiresAt:  time.Now().Add(config.AppConfig.Export.TTL),
+		}
+		if err := config.DB.Create(&export).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create export"})
+			return
+		}
+
+		job, err := jobs.Enqueue(models.JobTypeExport, nil, strconv.FormatUint(uint64(export.ID), 10))
+		if err != nil {
+			config.DB.Delete(&export)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue export"})
+			return
+		}
+		export.JobID = &job.ID
+		config.DB.Model(&export).Update("job_id", job.ID)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Export queued",
+			"export":  export,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=export_%s.zip", time.Now().Format("20060102_150405")))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// The status line is already sent, so a failure can only cut the
+	// archive short; clients see a ZIP without a central directory
+	if err := writeExportArchive(c.Request.Context(), c.Writer, videos); err != nil {
+		log.Printf("Export stream failed: %v", err)
+	}
+}
+
+// GetExports lists the caller's background exports, newest first
+func GetExports(c *gin.Context) {
+	var list []models.Export
+	if err := config.DB.Scopes(ownExports(c)).Order("id DESC").Limit(50).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exports"})
+		return
+	}
+	for i := range list {
+		resolveExportStatus(&list[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exports": list,
+	})
+}
+
+// GetExport reports the progress of one of the caller's background exports
+func GetExport(c *gin.Context) {
+	var export models.Export
+	if err := config.DB.Scopes(ownExports(c)).Where("id = ?", c.Param("id")).First(&export).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	resolveExportStatus(&export)
+
+	c.JSON(http.StatusOK, gin.H{
+		"export": export,
+	})
+}
+
+// DownloadExport sends one of the caller's finished export archives
+func DownloadExport(c *gin.Context) {
+	var export models.Export
+	if err := config.DB.Scopes(ownExports(c)).Where("id = ?", c.Param("id")).First(&export).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	if export.Status != models.ExportStatusReady {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready"})
+		return
+	}
+
+	file, info, err := storage.Service.Open(export.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export file not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open export %d: %v", export.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open export file"})
+		return
+	}
+	defer file.Close()
+
+	if c.Request.Method == http.MethodGet {
+		recordAudit(c, models.AuditVideoExport, nil, fmt.Sprintf("downloaded export %d", export.ID))
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=export_%d.zip", export.ID))
+	c.Header("Cache-Control", "private, no-cache")
+	serveVideoContent(c, file, info.ModTime, "application/zip", "")
+}
+
+// ownExports limits a query to the exports created by the caller. An
+// archive holds what its creator could see, so it is never shared.
+func ownExports(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userID := middleware.CurrentPrincipal(c).UserID
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", userID)
+	}
+}
+
+// StartExportCleanup periodically removes expired export archives
+func StartExportCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupExpiredExports()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpiredExports() {
+	var exports []models.Export
+	if err := config.DB.Where("expires_at < ?", time.Now()).Find(&exports).Error; err != nil {
+		log.Printf("Failed to query expired exports: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, export := range exports {
+		// Exports still being built are kept until their job gives up
+		resolveExportStatus(&export)
+		if export.Status == models.ExportStatusProcessing {
+			continue
+		}
+
+		if export.StorageKey != "" {
+			if err := storage.Service.Delete(export.StorageKey); err != nil {
+				log.Printf("Failed to delete export %d: %v", export.ID, err)
+				continue
+			}
+		}
+		config.DB.Delete(&export)
+		removed++
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d expired exports", removed)
+	}
+}
+
+// resolveExportStatus reports an export whose job has given up as failed
+func resolveExportStatus(export *models.Export) {
+	if export.Status != models.ExportStatusProcessing || export.JobID == nil {
+		return
+	}
+
+	var job models.Job
+	if err := config.DB.First(&job, *export.JobID).Error; err != nil {
+		return
+	}
+	if job.Status == models.JobStatusFailed {
+		export.Status = models.ExportStatusFailed
+		export.Error = job.LastError
+	}
+}
+
+// exportJob is the queue handler for models.JobTypeExport
+func exportJob(ctx context.Context, job *models.Job) error {
+	var export models.Export
+	if err := config.DB.Where("id = ?", job.Payload).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	// Exports contain what their creator could see at the time they run
+	var user models.User
+	if err := config.DB.First(&user, export.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	videos, err := exportVideos(export.Filters, videoVisibility(user.ID, user.Role))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(config.AppConfig.Upload.TempDir, "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := writeExportArchive(ctx, tmp, videos); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/export_%d.zip", export.ID)
+	size, err := storage.Service.Upload(key, tmp)
+	if err != nil {
+		return fmt.Errorf("upload export: %w", err)
+	}
+
+	return config.DB.Model(&export).Updates(map[string]interface{}{
+		"status":      models.ExportStatusReady,
+		"storage_key": key,
+		"size":        size,
+		"video_count": len(videos),
+		"expires_at":  time.Now().Add(config.AppConfig.Export.TTL),
+	}).Error
+}
+
+// parseExportFilters validates the request filters
+func parseExportFilters(req *ExportRequest) (models.ExportFilters, error) {
+	filters := models.ExportFilters{
+		RoomIDs:    req.RoomIDs,
+		UploadedBy: req.UploadedBy,
+	}
+
+	var err error
+	filters.StartDate, filters.EndDate, err = parseDateRange(req.StartDate, req.EndDate)
+	return filters, err
+}
+
+// exportVideos finds the videos selected by filters among those visible
+// through scope, oldest first
+func exportVideos(filters models.ExportFilters, scope func(*gorm.DB) *gorm.DB) ([]models.Video, error) {
+	// The manifest names the uploader, so nothing else of the user is loaded
+	query := config.DB.Preload("Room").Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "username")
+	}).Scopes(scope).Order("upload_date, id")
+
+	if len(filters.RoomIDs) > 0 {
+		query = query.Where("room_id IN ?", filters.RoomIDs)
+	}
+	if filters.UploadedBy != nil {
+		query = query.Where("uploaded_by = ?", *filters.UploadedBy)
+	}
+	if filters.StartDate != nil {
+		query = query.Where("upload_date >= ?", *filters.StartDate)
+	}
+	if filters.EndDate != nil {
+		query = query.Where("upload_date < ?", *filters.EndDate)
+	}
+
+	var videos []models.Video
+	err := query.Find(&videos).Error
+	return videos, err
+}
+
+// exportManifestEntry describes one video in an export manifest
+type exportManifestEntry struct {
+	Path  string        `json:"path,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Video exportedVideo `json:"video"`
+}
+
+// exportedVideo is the part of a video record that leaves the system in an
+// export. It is spelled out so that storage paths and the uploader's
+// account details never end up in an archive.
+type exportedVideo struct {
+	ID               uint                 `json:"id"`
+	OriginalFilename string               `json:"original_filename"`
+	RoomID           *uint                `json:"room_id"`
+	RoomNumber       string               `json:"room_number,omitempty"`
+	UploadedBy       uint                 `json:"uploaded_by"`
+	Uploader         string               `json:"uploader"`
+	UploadDate       time.Time            `json:"upload_date"`
+	FileSize         int64                `json:"file_size"`
+	ContentType      string               `json:"content_type"`
+	ContentHash      string               `json:"content_hash"`
+	Duration         *int                 `json:"duration"`
+	Metadata         models.VideoMetadata `json:"metadata"`
+}
+
+func newExportedVideo(video *models.Video) exportedVideo {
+	exported := exportedVideo{
+		ID:               video.ID,
+		OriginalFilename: video.OriginalFilename,
+		RoomID:           video.RoomID,
+		UploadedBy:       video.UploadedBy,
+		Uploader:         video.User.Username,
+		UploadDate:       video.UploadDate,
+		FileSize:         video.FileSize,
+		ContentType:      video.ContentType,
+		ContentHash:      video.ContentHash,
+		Duration:         video.Duration,
+		Metadata:         video.Metadata,
+	}
+	if video.Room != nil {
+		exported.RoomNumber = video.Room.RoomNumber
+	}
+	return exported
+}
+
+// writeExportArchive writes a ZIP of the videos, one folder per room, with
+// manifest.json and manifest.csv at the root. Videos are stored without
+// compression; ZIP64 records are added for files and archives over 4 GiB.
+func writeExportArchive(ctx context.Context, w io.Writer, videos []models.Video) error {
+	zw := zip.NewWriter(w)
+	names := exportEntryNames(videos)
+
+	manifest := make([]exportManifestEntry, len(videos))
+	for i := range videos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		manifest[i] = exportManifestEntry{Path: names[i], Video: newExportedVideo(&videos[i])}
+		err := addExportFile(zw, &videos[i], names[i])
+		if errors.Is(err, storage.ErrNotFound) {
+			// Keep going; the manifest records what could not be included
+			manifest[i].Path = ""
+			manifest[i].Error = "video file not found"
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("export video %d: %w", videos[i].ID, err)
+		}
+	}
+
+	if err := writeManifestJSON(zw, manifest); err != nil {
+		return err
+	}
+	if err := writeManifestCSV(zw, manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addExportFile(zw *zip.Writer, video *models.Video, name string) error {
+	file, info, err := storage.Service.Open(video.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Modified:           video.UploadDate,
+		UncompressedSize64: uint64(info.Size),
+	}
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// exportEntryNames picks a unique path in the archive for each video,
+// using the same file names as single downloads
+func exportEntryNames(videos []models.Video) []string {
+	names := make([]string, len(videos))
+	used := make(map[string]bool)
+
+	for i := range videos {
+		video := &videos[i]
+		folder := "no_room"
+		if video.Room != nil {
+			folder = roomFolder(video.Room.RoomNumber)
+		}
+
+		name := path.Join(folder, downloadFilename(video, video.ContentType))
+		if used[name] {
+			ext := path.Ext(name)
+			name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), video.ID, ext)
+		}
+		used[name] = true
+		names[i] = name
+	}
+
+	return names
+}
+
+func writeManifestJSON(zw *zip.Writer, manifest []exportManifestEntry) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gin.H{
+		"generated_at": time.Now(),
+		"video_count":  len(manifest),
+		"videos":       manifest,
+	})
+}
+
+func writeManifestCSV(zw *zip.Writer, manifest []exportManifestEntry) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(entry)
+	w.Write([]string{
+		"path", "video_id", "original_filename", "room_number", "uploaded_by", "upload_date", "recorded_at",
+		"file_size", "content_type", "content_hash", "duration", "width", "height", "video_codec", "audio_codec", "error",
+	})
+	for _, item := range manifest {
+		video := item.Video
+		recorded := ""
+		if video.Metadata.CreationTime != nil {
+			recorded = video.Metadata.CreationTime.Format(time.RFC3339)
+		}
+		duration := ""
+		if video.Duration != nil {
+			duration = strconv.Itoa(*video.Duration)
+		}
+
+		record := []string{
+			item.Path,
+			strconv.FormatUint(uint64(video.ID), 10),
+			video.OriginalFilename,
+			video.RoomNumber,
+			video.Uploader,
+			video.UploadDate.Format(time.RFC3339),
+			recorded,
+			strconv.FormatInt(video.FileSize, 10),
+			video.ContentType,
+			video.ContentHash,
+			duration,
+			strconv.Itoa(video.Metadata.Width),
+			strconv.Itoa(video.Metadata.Height),
+			video.Metadata.VideoCodec,
+			video.Metadata.AudioCodec,
+			item.Error,
+		}
+		for i := range record {
+			record[i] = csvCell(record[i])
+		}
+		w.Write(record)
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// csvCell keeps a spreadsheet from evaluating a value, such as an uploaded
+// file name, as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
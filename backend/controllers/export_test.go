@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+func TestExportsAreOnlyVisibleToTheirCreator(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner", models.RoleManager)
+	other := createTestUser(t, "other", models.RoleSupervisor)
+
+	export := models.Export{
+		UserID:     owner.ID,
+		Status:     models.ExportStatusReady,
+		StorageKey: "exports/export_1.zip",
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	if err := config.DB.Create(&export).Error; err != nil {
+		t.Fatalf("create export: %v", err)
+	}
+	target := fmt.Sprintf("/exports/%d", export.ID)
+
+	w := performRequest(t, owner, http.MethodGet, "/exports/:id", target, nil, GetExport)
+	assertStatus(t, w, http.StatusOK)
+
+	w = performRequest(t, other, http.MethodGet, "/exports/:id", target, nil, GetExport)
+	assertStatus(t, w, http.StatusNotFound)
+
+	w = performRequest(t, other, http.MethodGet, "/exports/:id/download", target+"/download", nil, DownloadExport)
+	assertStatus(t, w, http.StatusNotFound)
+
+	for _, tt := range []struct {
+		user *models.User
+		want int
+	}{{owner, 1}, {other, 0}} {
+		w = performRequest(t, tt.user, http.MethodGet, "/exports", "/exports", nil, GetExports)
+		assertStatus(t, w, http.StatusOK)
+		var body struct {
+			Exports []models.Export `json:"exports"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode exports: %v", err)
+		}
+		if len(body.Exports) != tt.want {
+			t.Errorf("%s sees %d exports, want %d", tt.user.Username, len(body.Exports), tt.want)
+		}
+	}
+}
+
+func TestExportManifestListsOnlyVideoMetadata(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	config.DB.Model(user).Updates(map[string]interface{}{"two_factor_enabled": true, "failed_login_attempts": 3})
+	room := models.Room{RoomNumber: "101", Floor: "1"}
+	config.DB.Create(&room)
+	video, _ := newTestVideo(t, user, &room, 100)
+
+	videos, err := exportVideos(models.ExportFilters{}, videoVisibility(user.ID, user.Role))
+	if err != nil {
+		t.Fatalf("exportVideos: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := writeExportArchive(context.Background(), &archive, videos); err != nil {
+		t.Fatalf("writeExportArchive: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	manifests := map[string]string{}
+	for _, file := range zr.File {
+		if !strings.HasPrefix(file.Name, "manifest.") {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		manifests[file.Name] = string(data)
+	}
+
+	for name, content := range manifests {
+		for _, private := range []string{user.Email, "two_factor", "failed_login", "locked_until", "file_path", video.FilePath} {
+			if strings.Contains(content, private) {
+				t.Errorf("%s contains %q", name, private)
+			}
+		}
+		for _, public := range []string{"uploader", "101", video.ContentHash} {
+			if !strings.Contains(content, public) {
+				t.Errorf("%s lacks %q", name, public)
+			}
+		}
+	}
+
+	var manifest struct {
+		Videos []struct {
+			Path  string                 `json:"path"`
+			Video map[string]interface{} `json:"video"`
+		} `json:"videos"`
+	}
+	if err := json.Unmarshal([]byte(manifests["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("decode manifest.json: %v", err)
+	}
+	if len(manifest.Videos) != 1 || manifest.Videos[0].Path == "" {
+		t.Fatalf("manifest.json lists %+v, want the one video", manifest.Videos)
+	}
+	if _, ok := manifest.Videos[0].Video["user"]; ok {
+		t.Error("manifest.json includes the uploader's user record")
+	}
+}
+
+func TestExportEntryNames(t *testing.T) {
+	room := &models.Room{RoomNumber: "101"}
+	uploaded := time.Date(2026, 10, 16, 9, 30, 0, 0, time.Local)
+	video := func(id uint, name string, room *models.Room) models.Video {
+		return models.Video{ID: id, OriginalFilename: name, Room: room, ContentType: "video/mp4", UploadDate: uploaded}
+	}
+
+	videos := []models.Video{
+		video(1, "lobby.mp4", room),
+		video(2, "lobby.mp4", room),
+		video(3, `C:\Users\cam\desk.mp4`, nil),
+		video(4, "..", room),
+		video(5, "clips/..", nil),
+		video(6, `clips\.`, room),
+		video(7, "", room),
+		video(8, "\x01..", nil),
+	}
+	want := []string{
+		"room_101/lobby.mp4",
+		"room_101/lobby_2.mp4",
+		"no_room/desk.mp4",
+		"room_101/video_4.mp4",
+		"no_room/video_5.mp4",
+		"room_101/video_6.mp4",
+		"room_101/room_101_2026-10-16_0930.mp4",
+		"no_room/video_8.mp4",
+	}
+
+	names := exportEntryNames(videos)
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("video %d (%q) exported as %q, want %q", videos[i].ID, videos[i].OriginalFilename, names[i], want[i])
+		}
+	}
+}
+
+func TestExportManifestCSVEscapesFormulas(t *testing.T) {
+	names := []string{"=HYPERLINK(\"http://example.com\")", "+1.mp4", "-clip.mp4", "@SUM(A1).mp4", "\tlobby.mp4", "lobby=1.mp4"}
+	manifest := make([]exportManifestEntry, len(names))
+	for i, name := range names {
+		manifest[i] = exportManifestEntry{
+			Path:  "no_room/" + name,
+			Video: exportedVideo{ID: uint(i + 1), OriginalFilename: name, Uploader: "@admin"},
+		}
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	if err := writeManifestCSV(zw, manifest); err != nil {
+		t.Fatalf("writeManifestCSV: %v", err)
+	}
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	r, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("open manifest.csv: %v", err)
+	}
+	defer r.Close()
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatalf("read manifest.csv: %v", err)
+	}
+
+	want := []string{"'=HYPERLINK(\"http://example.com\")", "'+1.mp4", "'-clip.mp4", "'@SUM(A1).mp4", "'\tlobby.mp4", "lobby=1.mp4"}
+	for i, record := range records[1:] {
+		if record[2] != want[i] {
+			t.Errorf("original_filename = %q, want %q", record[2], want[i])
+		}
+		if record[4] != "'@admin" {
+			t.Errorf("uploaded_by = %q, want %q", record[4], "'@admin")
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB loads the default configuration and points config.DB at an
+// empty database with the default role grants
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.LoadConfig()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	config.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := config.MigrateDatabase(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	for role, permissions := range models.DefaultRolePermissions {
+		for _, permission := range permissions {
+			if err := db.Create(&models.RolePermission{Role: role, Permission: permission}).Error; err != nil {
+				t.Fatalf("grant %s: %v", permission, err)
+			}
+		}
+	}
+	if err := middleware.LoadPermissions(); err != nil {
+		t.Fatalf("load permissions: %v", err)
+	}
+}
+
+// createTestUser stores an active user with a role
+func createTestUser(t *testing.T, username, role string) *models.User {
+	t.Helper()
+	user := models.User{
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: "unused",
+		Role:         role,
+		IsActive:     true,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return &user
+}
+
+// performRequest serves one request with handler registered at route, as
+// user when one is given. A non-nil body is sent as JSON.
+func performRequest(t *testing.T, user *models.User, method, route, target string, body interface{}, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return serveRequest(user, route, req, handler)
+}
+
+// serveRequest serves req with handlers registered at route, as user when
+// one is given
+func serveRequest(user *models.User, route string, req *http.Request, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(req.Method, route, append([]gin.HandlerFunc{func(c *gin.Context) {
+		if user != nil {
+			middleware.SetPrincipal(c, &middleware.Principal{
+				UserID:   user.ID,
+				Username: user.Username,
+				Role:     user.Role,
+			})
+		}
+		c.Next()
+	}}, handlers...)...)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// assertStatus fails the test unless the response has the expected status
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, want, w.Body.String())
+	}
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ServeHLS serves the master playlist, variant playlists and segments of a
+// video's HLS package. Only files inside that video's package can be read.
+func ServeHLS(c *gin.Context) {
+	var video models.Video
+	if err := findVisibleVideo(c, &video); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+	if video.HLSPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HLS stream not available"})
+		return
+	}
+
+	name := strings.TrimPrefix(c.Param("path"), "/")
+	if name == "" || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid HLS path"})
+		return
+	}
+
+	var contentType, cacheControl string
+	switch path.Ext(name) {
+	case ".m3u8":
+		contentType, cacheControl = media.TypeHLSPlaylist, "no-cache"
+	case ".ts":
+		contentType, cacheControl = media.TypeHLSSegment, "private, max-age=86400"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid HLS path"})
+		return
+	}
+
+	key := path.Join(path.Dir(video.HLSPath), name)
+	file, info, err := storage.Service.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HLS file not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open HLS file %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open HLS file"})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", cacheControl)
+
+	// Playlists reference their children by relative URI, which drops the
+	// query string, so the signature is carried over to every entry
+	if contentType == media.TypeHLSPlaylist {
+		playlist, err := io.ReadAll(file)
+		if err != nil {
+			log.Printf("Failed to read HLS playlist %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open HLS file"})
+			return
+		}
+		c.Data(http.StatusOK, contentType, []byte(media.SignPlaylist(string(playlist), middleware.SignedMediaQuery(c))))
+		return
+	}
+
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, file)
+}
+
+// packageHLSJob is the queue handler for models.JobTypeHLS
+func packageHLSJob(ctx context.Context, job *models.Job) error {
+	if job.VideoID == nil {
+		return jobs.Permanent(errors.New("hls job without a video"))
+	}
+	return packageHLS(ctx, *job.VideoID)
+}
+
+// packageHLS segments a video into an adaptive HLS package with one
+// variant per rung of the ladder the source is tall enough for
+func packageHLS(ctx context.Context, videoID uint) error {
+	var video models.Video
+	if err := config.DB.First(&video, videoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	prefix := derivedPrefix(&video) + "hls/"
+	master := prefix + media.HLSPlaylist
+
+	// The master playlist is uploaded last, so its presence means another
+	// video with the same content already has a complete package
+	if _, err := storage.Service.Stat(master); err != nil {
+		if err := buildHLSPackage(ctx, &video, prefix); err != nil {
+			return err
+		}
+	}
+
+	return config.DB.Model(&video).Update("hls_path", master).Error
+}
+
+func buildHLSPackage(ctx context.Context, video *models.Video, prefix string) error {
+	input, cleanup, err := localVideoFile(video.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(config.AppConfig.Upload.TempDir, "hls-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	metadata := video.Metadata
+	_, height := media.UprightSize(metadata.Width, metadata.Height, metadata.Rotation)
+
+	var streams []media.HLSStream
+	for _, variant := range media.HLSLadder(height) {
+		variantDir := filepath.Join(dir, variant.Name)
+		if err := os.MkdirAll(variantDir, 0755); err != nil {
+			return err
+		}
+
+		err := media.HLS.PackageVariant(ctx, input, variantDir, variant)
+		if errors.Is(err, media.ErrUnavailable) {
+			return jobs.Permanent(fmt.Errorf("hls %s: %w", variant.Name, err))
+		}
+		if err != nil {
+			return fmt.Errorf("hls %s: %w", variant.Name, err)
+		}
+
+		width, height := variant.OutputSize(metadata.Width, metadata.Height, metadata.Rotation)
+		streams = append(streams, media.HLSStream{Profile: variant, Width: width, Height: height})
+	}
+
+	// Upload the variants first and the master playlist last
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		return uploadDerivedFile(prefix+filepath.ToSlash(rel), file)
+	})
+	if err != nil {
+		return fmt.Errorf("upload hls package: %w", err)
+	}
+
+	masterFile := filepath.Join(dir, media.HLSPlaylist)
+	if err := os.WriteFile(masterFile, []byte(media.MasterPlaylist(streams)), 0644); err != nil {
+		return err
+	}
+	return uploadDerivedFile(prefix+media.HLSPlaylist, masterFile)
+}
+
+// uploadDerivedFile stores a generated local file under key
+func uploadDerivedFile(key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = storage.Service.Upload(key, f)
+	return err
+}
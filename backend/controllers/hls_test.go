@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+)
+
+// newTestHLSVideo stores a one-variant HLS package for a new video
+func newTestHLSVideo(t *testing.T, user *models.User) *models.Video {
+	t.Helper()
+	video, _ := newTestVideo(t, user, nil, 100)
+
+	dir := fmt.Sprintf("hls/%d", video.ID)
+	files := map[string]string{
+		"index.m3u8":          "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n360p/index.m3u8\n",
+		"360p/index.m3u8":     "#EXTM3U\n#EXTINF:4.0,\nsegment_000.ts\n#EXT-X-ENDLIST\n",
+		"360p/segment_000.ts": "segment",
+	}
+	for name, content := range files {
+		if _, err := storage.Service.Upload(dir+"/"+name, strings.NewReader(content)); err != nil {
+			t.Fatalf("store %s: %v", name, err)
+		}
+	}
+
+	config.DB.Model(video).Update("hls_path", dir+"/index.m3u8")
+	return video
+}
+
+func serveHLS(user *models.User, video *models.Video, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", fmt.Sprintf("/videos/%d/hls/%s?uid=1&sid=s&exp=2&sig=x", video.ID, name), nil)
+	req.Header.Set("Origin", "https://attacker.example")
+	return serveRequest(user, "/videos/:id/hls/*path", req, ServeHLS)
+}
+
+func TestServeHLS(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "viewer", models.RoleUser)
+	video := newTestHLSVideo(t, user)
+
+	w := serveHLS(user, video, "360p/index.m3u8")
+	assertStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "segment_000.ts?exp=2&sid=s&sig=x&uid=1") {
+		t.Errorf("variant playlist does not carry the signature:\n%s", w.Body.String())
+	}
+
+	w = serveHLS(user, video, "360p/segment_000.ts")
+	assertStatus(t, w, http.StatusOK)
+	if w.Body.String() != "segment" {
+		t.Errorf("segment body = %q", w.Body.String())
+	}
+
+	// Cross-origin access is left to the application-wide CORS policy
+	for _, name := range []string{"index.m3u8", "360p/segment_000.ts"} {
+		if origin := serveHLS(user, video, name).Header().Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Errorf("%s allows origin %q", name, origin)
+		}
+	}
+}
+
+func TestServeHLSRejectsOtherFiles(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "viewer", models.RoleUser)
+	other := createTestUser(t, "other", models.RoleUser)
+	video := newTestHLSVideo(t, user)
+
+	tests := []struct {
+		user *models.User
+		name string
+		want int
+	}{
+		{user, "../../videos/clip_1.mp4", http.StatusBadRequest},
+		{user, "360p/../index.m3u8", http.StatusBadRequest},
+		{user, "poster.jpg", http.StatusBadRequest},
+		{user, "720p/index.m3u8", http.StatusNotFound},
+		{other, "index.m3u8", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if w := serveHLS(tt.user, video, tt.name); w.Code != tt.want {
+			t.Errorf("%s as %s: status %d, want %d", tt.name, tt.user.Username, w.Code, tt.want)
+		}
+	}
+}
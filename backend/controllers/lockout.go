@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Password guessing is slowed down per username and per client address.
+// Once a username or address reaches its failure threshold it is locked for
+// LOGIN_LOCKOUT_BASE, and every further failure doubles the lock up to
+// LOGIN_LOCKOUT_MAX. Failures are forgotten after LOGIN_LOCKOUT_MAX without
+// a new one; a successful login only clears the username's failures.
+
+// GetLockouts lists users and client addresses with recent failed logins
+func GetLockouts(c *gin.Context) {
+	since := time.Now().Add(-config.AppConfig.Login.LockoutMax)
+
+	var users []models.User
+	if err := config.DB.
+		Where("last_failed_login_at > ? OR locked_until > ?", since, time.Now()).
+		Order("last_failed_login_at DESC").
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+	for i := range users {
+		users[i].PasswordHash = ""
+	}
+
+	var addresses []models.LoginThrottle
+	if err := config.DB.
+		Where("last_failed_at > ? OR locked_until > ?", since, time.Now()).
+		Order("last_failed_at DESC").
+		Find(&addresses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"addresses": addresses,
+	})
+}
+
+// UnlockUser clears the failed logins and any lock of a user
+func UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := resetLoginFailures(config.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	recordAudit(c, models.AuditUserUnlock, nil, user.Username)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// UnlockAddress clears the failed logins and any lock of a client address
+func UnlockAddress(c *gin.Context) {
+	ip := c.Param("ip")
+
+	result := config.DB.Where("ip_address = ?", ip).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock address"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+	recordAudit(c, models.AuditIPUnlock, nil, ip)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address unlocked successfully"})
+}
+
+// addressLockedUntil returns when the lock on a client address ends, or nil
+// when it is not locked
+func addressLockedUntil(ip string) *time.Time {
+	var throttle models.LoginThrottle
+	if err := config.DB.Where("ip_address = ? AND locked_until > ?", ip, time.Now()).Limit(1).Find(&throttle).Error; err != nil {
+		log.Printf("Failed to check login throttle for %s: %v", ip, err)
+		return nil
+	}
+	return throttle.LockedUntil
+}
+
+// userLockedUntil returns when the lock on a user ends, or nil when the
+// user is not locked
+func userLockedUntil(user *models.User) *time.Time {
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return user.LockedUntil
+	}
+	return nil
+}
+
+// respondLocked rejects a login attempt while a lock is in place
+func respondLocked(c *gin.Context, until time.Time) {
+	retryAfter := int(time.Until(until).Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":        "Too many failed login attempts, try again later",
+		"locked_until": until,
+	})
+}
+
+// recordLoginFailure counts a wrong password against the client address
+// and, when the username exists, against the user
+func recordLoginFailure(user *models.User, ip string) {
+	cfg := config.AppConfig.Login
+	now := time.Now()
+
+	if user != nil {
+		var attempts int
+		var until *time.Time
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			var current models.User
+			if err := tx.Select("id", "failed_login_attempts", "last_failed_login_at").First(&current, user.ID).Error; err != nil {
+				return err
+			}
+			attempts, until = nextLockout(current.FailedLoginAttempts, current.LastFailedLoginAt, cfg.MaxAttempts, now)
+			return tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+				"failed_login_attempts": attempts,
+				"last_failed_login_at":  now,
+				"locked_until":          until,
+			}).Error
+		})
+		if err != nil {
+			log.Printf("Failed to record failed login for user %s: %v", user.Username, err)
+		} else if until != nil {
+			log.Printf("Locked user %s until %s after %d failed logins", user.Username, until.Format(time.RFC3339), attempts)
+		}
+	}
+
+	var throttle models.LoginThrottle
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ip_address = ?", ip).Limit(1).Find(&throttle).Error; err != nil {
+			return err
+		}
+		var last *time.Time
+		if throttle.FailedAttempts > 0 {
+			last = &throttle.LastFailedAt
+		}
+		throttle.IPAddress = ip
+		throttle.FailedAttempts, throttle.LockedUntil = nextLockout(throttle.FailedAttempts, last, cfg.IPMaxAttempts, now)
+		throttle.LastFailedAt = now
+		return tx.Save(&throttle).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record failed login from %s: %v", ip, err)
+	} else if throttle.LockedUntil != nil {
+		log.Printf("Locked logins from %s until %s after %d failures", ip, throttle.LockedUntil.Format(time.RFC3339), throttle.FailedAttempts)
+	}
+}
+
+// clearLoginFailures forgets a user's failed logins after a successful one
+func clearLoginFailures(user *models.User) {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return
+	}
+	if err := resetLoginFailures(config.DB, user.ID); err != nil {
+		log.Printf("Failed to clear failed logins of user %s: %v", user.Username, err)
+	}
+}
+
+func resetLoginFailures(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+}
+
+// nextLockout adds a failure to attempts and returns the new count and,
+// once it reaches threshold, when the resulting lock ends. A threshold
+// below 1 disables locking.
+func nextLockout(attempts int, lastFailed *time.Time, threshold int, now time.Time) (int, *time.Time) {
+	cfg := config.AppConfig.Login
+	if lastFailed == nil || now.Sub(*lastFailed) > cfg.LockoutMax {
+		attempts = 0
+	}
+	attempts++
+	if threshold < 1 || attempts < threshold {
+		return attempts, nil
+	}
+
+	lock := cfg.LockoutBase
+	for i := threshold; i < attempts && lock < cfg.LockoutMax; i++ {
+		lock *= 2
+	}
+	if lock > cfg.LockoutMax {
+		lock = cfg.LockoutMax
+	}
+	until := now.Add(lock)
+	return attempts, &until
+}
+
+func cleanupLoginThrottles() {
+	now := time.Now()
+	result := config.DB.
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-config.AppConfig.Login.LockoutMax), now).
+		Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		log.Printf("Failed to remove stale login throttles: %v", result.Error)
+	}
+}
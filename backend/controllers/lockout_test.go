@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testPassword = "correct-horse-battery"
+
+// setupLockoutTest sets small thresholds: a username locks after three
+// failures and a client address after five
+func setupLockoutTest(t *testing.T) {
+	t.Helper()
+	setupTestDB(t)
+	config.AppConfig.Login.MaxAttempts = 3
+	config.AppConfig.Login.IPMaxAttempts = 5
+	config.AppConfig.Login.LockoutBase = time.Minute
+	config.AppConfig.Login.LockoutMax = time.Hour
+}
+
+// createLoginUser stores a user who signs in with testPassword
+func createLoginUser(t *testing.T, username string) *models.User {
+	t.Helper()
+	user := createTestUser(t, username, models.RoleUser)
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Model(user).Update("password_hash", hash)
+	return user
+}
+
+// login posts credentials to Login from a client address
+func login(ip, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(LoginRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":40000"
+	return serveRequest(nil, "/login", req, Login)
+}
+
+func lockedUntil(t *testing.T, user *models.User) *time.Time {
+	t.Helper()
+	var stored models.User
+	if err := config.DB.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored.LockedUntil
+}
+
+// expireLock lets the current lock of a user run out, keeping its failures
+func expireLock(user *models.User) {
+	config.DB.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("locked_until", time.Now().Add(-time.Second))
+}
+
+// assertLockedFor checks that a lock ends about d from now
+func assertLockedFor(t *testing.T, until *time.Time, d time.Duration) {
+	t.Helper()
+	if until == nil {
+		t.Fatalf("not locked, want a lock of %s", d)
+	}
+	if remaining := time.Until(*until); remaining > d || remaining < d-5*time.Second {
+		t.Fatalf("locked for %s, want %s", remaining.Round(time.Second), d)
+	}
+}
+
+func TestNextLockout(t *testing.T) {
+	setupLockoutTest(t)
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	stale := now.Add(-2 * time.Hour)
+
+	tests := []struct {
+		name         string
+		attempts     int
+		lastFailed   *time.Time
+		threshold    int
+		wantAttempts int
+		wantLock     time.Duration
+	}{
+		{"first failure", 0, nil, 3, 1, 0},
+		{"below threshold", 1, &recent, 3, 2, 0},
+		{"reaches threshold", 2, &recent, 3, 3, time.Minute},
+		{"one past threshold", 3, &recent, 3, 4, 2 * time.Minute},
+		{"two past threshold", 4, &recent, 3, 5, 4 * time.Minute},
+		{"capped", 20, &recent, 3, 21, time.Hour},
+		{"old failures forgotten", 10, &stale, 3, 1, 0},
+		{"locking disabled", 50, &recent, 0, 51, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts, until := nextLockout(tt.attempts, tt.lastFailed, tt.threshold, now)
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			switch {
+			case tt.wantLock == 0 && until != nil:
+				t.Errorf("locked until %s, want no lock", until)
+			case tt.wantLock != 0 && (until == nil || until.Sub(now) != tt.wantLock):
+				t.Errorf("locked until %v, want %s from now", until, tt.wantLock)
+			}
+		})
+	}
+}
+
+func TestLoginLocksUserWithBackoff(t *testing.T) {
+	setupLockoutTest(t)
+	config.AppConfig.Login.IPMaxAttempts = 0 // only the username's lock is under test
+	user := createLoginUser(t, "alice")
+
+	for i := 0; i < 3; i++ {
+		assertStatus(t, login("192.0.2.1", "alice", "wrong"), http.StatusUnauthorized)
+	}
+	assertLockedFor(t, lockedUntil(t, user), time.Minute)
+
+	// Even the right password is refused while locked, and refused
+	// attempts do not extend the lock
+	w := login("192.0.2.1", "alice", testPassword)
+	assertStatus(t, w, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 55 || retry > 61 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	assertLockedFor(t, lockedUntil(t, user), time.Minute)
+
+	// Each failure after the lock runs out doubles the next one
+	expireLock(user)
+	assertStatus(t, login("192.0.2.1", "alice", "wrong"), http.StatusUnauthorized)
+	assertLockedFor(t, lockedUntil(t, user), 2*time.Minute)
+	expireLock(user)
+	assertStatus(t, login("192.0.2.1", "alice", "wrong"), http.StatusUnauthorized)
+	assertLockedFor(t, lockedUntil(t, user), 4*time.Minute)
+
+	expireLock(user)
+	assertStatus(t, login("192.0.2.1", "alice", testPassword), http.StatusOK)
+	var stored models.User
+	config.DB.First(&stored, user.ID)
+	if stored.FailedLoginAttempts != 0 || stored.LockedUntil != nil {
+		t.Errorf("after login: %d failures, locked until %v", stored.FailedLoginAttempts, stored.LockedUntil)
+	}
+}
+
+func TestLoginLimitsUsersAndAddressesSeparately(t *testing.T) {
+	setupLockoutTest(t)
+	createLoginUser(t, "alice")
+	createLoginUser(t, "bob")
+
+	for i := 0; i < 3; i++ {
+		assertStatus(t, login("192.0.2.1", "alice", "wrong"), http.StatusUnauthorized)
+	}
+
+	// The username is locked from every address, other users are not
+	assertStatus(t, login("198.51.100.7", "alice", testPassword), http.StatusTooManyRequests)
+	assertStatus(t, login("192.0.2.1", "bob", testPassword), http.StatusOK)
+
+	// Guesses at unknown usernames count against the address only; bob's
+	// login did not clear its earlier failures
+	assertStatus(t, login("192.0.2.1", "nobody", "wrong"), http.StatusUnauthorized)
+	assertStatus(t, login("192.0.2.1", "someone", "wrong"), http.StatusUnauthorized)
+
+	var throttle models.LoginThrottle
+	config.DB.Where("ip_address = ?", "192.0.2.1").First(&throttle)
+	if throttle.FailedAttempts != 5 {
+		t.Errorf("address has %d failures, want 5", throttle.FailedAttempts)
+	}
+	assertLockedFor(t, throttle.LockedUntil, time.Minute)
+
+	assertStatus(t, login("192.0.2.1", "bob", testPassword), http.StatusTooManyRequests)
+	assertStatus(t, login("198.51.100.7", "bob", testPassword), http.StatusOK)
+}
+
+func TestUnlock(t *testing.T) {
+	setupLockoutTest(t)
+	supervisor := createTestUser(t, "supervisor", models.RoleSupervisor)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	user := createLoginUser(t, "alice")
+
+	for _, username := range []string{"alice", "alice", "alice", "nobody", "nobody"} {
+		assertStatus(t, login("192.0.2.1", username, "wrong"), http.StatusUnauthorized)
+	}
+	assertStatus(t, login("198.51.100.7", "alice", testPassword), http.StatusTooManyRequests)
+
+	unlock := func(caller *models.User, route, target string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		return serveRequest(caller, route, req, RequirePermission(models.PermUsersUnlock), handler)
+	}
+	unlockUser := fmt.Sprintf("/lockouts/users/%d", user.ID)
+
+	assertStatus(t, unlock(manager, "/lockouts/users/:id", unlockUser, UnlockUser), http.StatusForbidden)
+	assertStatus(t, unlock(supervisor, "/lockouts/users/:id", unlockUser, UnlockUser), http.StatusOK)
+	if until := lockedUntil(t, user); until != nil {
+		t.Fatalf("user still locked until %s", until)
+	}
+	assertStatus(t, login("198.51.100.7", "alice", testPassword), http.StatusOK)
+
+	// Unlocking the user leaves the address locked
+	assertStatus(t, login("192.0.2.1", "alice", testPassword), http.StatusTooManyRequests)
+	assertStatus(t, unlock(manager, "/lockouts/addresses/:ip", "/lockouts/addresses/192.0.2.1", UnlockAddress), http.StatusForbidden)
+	assertStatus(t, unlock(supervisor, "/lockouts/addresses/:ip", "/lockouts/addresses/192.0.2.1", UnlockAddress), http.StatusOK)
+	assertStatus(t, login("192.0.2.1", "alice", testPassword), http.StatusOK)
+	assertStatus(t, unlock(supervisor, "/lockouts/addresses/:ip", "/lockouts/addresses/192.0.2.1", UnlockAddress), http.StatusNotFound)
+
+	var audits int64
+	config.DB.Model(&models.AuditLog{}).Where("action IN ?", []string{models.AuditUserUnlock, models.AuditIPUnlock}).Count(&audits)
+	if audits != 2 {
+		t.Errorf("%d unlock audit entries, want 2", audits)
+	}
+}
@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/notify"
+	"trialuploadhk/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+var (
+	errPasswordReused = errors.New("password reused")
+	errResetUsed      = errors.New("reset code already used")
+)
+
+// ChangePassword replaces the caller's password. It is the only protected
+// endpoint open to users who must change their password, and it signs out
+// the user's other sessions.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	var user models.User
+	if err := config.DB.First(&user, principal.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Wrong current passwords count towards the login lockout, so a stolen
+	// access token cannot be used to guess the password
+	ip := c.ClientIP()
+	if until := userLockedUntil(&user); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		recordLoginFailure(&user, ip)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := passwordPolicy().Validate(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		return revokeOtherSessions(tx, user.ID, principal.SessionID)
+	})
+	if errors.Is(err, errPasswordReused) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password was used recently, choose a different one"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	clearLoginFailures(&user)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// passwordPolicy is the policy configured for new passwords
+func passwordPolicy() utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:    config.AppConfig.Password.MinLength,
+		RejectCommon: config.AppConfig.Password.RejectCommon,
+	}
+}
+
+// setPassword stores a new password for an existing user, clears
+// must_change_password and records the password in the user's history. It
+// fails with errPasswordReused when the password is among the recent ones.
+func setPassword(tx *gorm.DB, user *models.User, password string) error {
+	reused, err := passwordReused(tx, user, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		return errPasswordReused
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(user).UpdateColumns(map[string]interface{}{
+		"password_hash":        hash,
+		"must_change_password": false,
+	}).Error; err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.MustChangePassword = false
+	return recordPasswordHistory(tx, user.ID, hash)
+}
+
+// passwordReused reports whether password matches the user's current
+// password or one of the PASSWORD_HISTORY most recent ones
+func passwordReused(tx *gorm.DB, user *models.User, password string) (bool, error) {
+	limit := config.AppConfig.Password.History
+	if limit < 1 {
+		return false, nil
+	}
+	if utils.CheckPasswordHash(password, user.PasswordHash) {
+		return true, nil
+	}
+
+	var history []models.PasswordHistory
+	if err := tx.Where("user_id = ?", user.ID).Order("id DESC").Limit(limit).Find(&history).Error; err != nil {
+		return false, err
+	}
+	for _, entry := range history {
+		if utils.CheckPasswordHash(password, entry.PasswordHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordPasswordHistory remembers a password hash and forgets those older
+// than the configured history
+func recordPasswordHistory(tx *gorm.DB, userID uint, hash string) error {
+	limit := config.AppConfig.Password.History
+	if limit < 1 {
+		return nil
+	}
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: hash}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ? AND id NOT IN ?", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+type RedeemPasswordResetRequest struct {
+	Username    string `json:"username" binding:"required"`
+	Code        string `json:"code" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// maxResetAttempts is how many wrong codes void a password reset
+const maxResetAttempts = 5
+
+// oneTimeCodeAlphabet leaves out letters easily mistaken for digits
+const oneTimeCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IssuePasswordReset creates a one-time code the user can redeem to set a
+// new password. The code is delivered by the configured notifier, or
+// returned so the supervisor can pass it on.
+func IssuePasswordReset(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if user.ID == principal.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot reset your own password"})
+		return
+	}
+	if !outranks(principal, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage users at or above your own role"})
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reset the password of an inactive user"})
+		return
+	}
+
+	code, err := newOneTimeCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset code"})
+		return
+	}
+
+	// A new code replaces any earlier one
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		CodeHash:  hashOneTimeCode(code),
+		CreatedBy: principal.UserID,
+		ExpiresAt: time.Now().Add(config.AppConfig.Password.ResetTTL),
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&reset).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset code"})
+		return
+	}
+
+	if notify.Service == nil {
+		recordAudit(c, models.AuditPasswordResetIssue, nil, user.Username)
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Reset code created",
+			"code":       code,
+			"expires_at": reset.ExpiresAt,
+		})
+		return
+	}
+
+	to := notify.Recipient{Username: user.Username, Email: user.Email}
+	if err := notify.Service.SendPasswordResetCode(to, code, reset.ExpiresAt); err != nil {
+		log.Printf("Failed to deliver password reset code for user %s: %v", user.Username, err)
+		config.DB.Delete(&reset)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to deliver reset code"})
+		return
+	}
+	recordAudit(c, models.AuditPasswordResetIssue, nil, user.Username+" (sent to user)")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Reset code sent to the user",
+		"expires_at": reset.ExpiresAt,
+	})
+}
+
+// RedeemPasswordReset sets a new password with a reset code. It signs the
+// user out everywhere and clears any login lockout.
+func RedeemPasswordReset(c *gin.Context) {
+	var req RedeemPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	// Wrong codes count towards the client address lockout
+	ip := c.ClientIP()
+	if until := addressLockedUntil(ip); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+
+	var user models.User
+	var reset models.PasswordReset
+	found := config.DB.Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error == nil &&
+		config.DB.Where("user_id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", user.ID, time.Now(), maxResetAttempts).
+			Order("id DESC").First(&reset).Error == nil
+	if !found {
+		recordLoginFailure(nil, ip)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset code"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(hashOneTimeCode(req.Code)), []byte(reset.CodeHash)) != 1 {
+		config.DB.Model(&reset).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+		recordLoginFailure(nil, ip)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset code"})
+		return
+	}
+
+	if err := passwordPolicy().Validate(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The used_at condition lets only one of concurrent redemptions win
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetUsed
+		}
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		if err := resetLoginFailures(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+	if errors.Is(err, errResetUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset code"})
+		return
+	}
+	if errors.Is(err, errPasswordReused) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password was used recently, choose a different one"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	recordAuditAs(c, user.ID, user.Username, models.AuditPasswordResetRedeem, nil, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// newOneTimeCode returns ten random characters from oneTimeCodeAlphabet,
+// grouped as XXXXX-XXXXX
+func newOneTimeCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 11)
+	for i, b := range buf {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		code = append(code, oneTimeCodeAlphabet[int(b)%len(oneTimeCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// hashOneTimeCode hashes a code as typed, ignoring case, spaces and dashes
+func hashOneTimeCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func cleanupPasswordResets() {
+	result := config.DB.Where("expires_at < ? OR used_at IS NOT NULL", time.Now()).Delete(&models.PasswordReset{})
+	if result.Error != nil {
+		log.Printf("Failed to remove expired password resets: %v", result.Error)
+	}
+}
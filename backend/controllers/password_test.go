@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+func TestIssuePasswordResetRequiresHigherRank(t *testing.T) {
+	setupTestDB(t)
+	supervisor := createTestUser(t, "supervisor", models.RoleSupervisor)
+	peer := createTestUser(t, "peer", models.RoleSupervisor)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	otherManager := createTestUser(t, "other-manager", models.RoleManager)
+
+	tests := []struct {
+		name   string
+		caller *models.User
+		target *models.User
+		want   int
+	}{
+		{"supervisor resets manager", supervisor, manager, http.StatusCreated},
+		{"supervisor resets peer", supervisor, peer, http.StatusForbidden},
+		{"manager resets peer", manager, otherManager, http.StatusForbidden},
+		{"manager resets supervisor", manager, supervisor, http.StatusForbidden},
+		{"supervisor resets self", supervisor, supervisor, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(t, tt.caller, http.MethodPost, "/users/:id/password-reset",
+				fmt.Sprintf("/users/%d/password-reset", tt.target.ID), nil, IssuePasswordReset)
+			assertStatus(t, w, tt.want)
+
+			var codes int64
+			config.DB.Model(&models.PasswordReset{}).Where("user_id = ?", tt.target.ID).Count(&codes)
+			if tt.want != http.StatusCreated && codes != 0 {
+				t.Errorf("refused reset still created %d codes", codes)
+			}
+		})
+	}
+}
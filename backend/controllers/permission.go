@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// GetPermissions lists every permission and the grants of each role
+func GetPermissions(c *gin.Context) {
+	roles := gin.H{}
+	for _, role := range models.Roles {
+		granted, err := rolePermissions(role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			return
+		}
+		roles[role] = granted
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"permissions": models.Permissions,
+		"roles":       roles,
+	})
+}
+
+// GetRolePermissions lists the permissions granted to a role
+func GetRolePermissions(c *gin.Context) {
+	role := c.Param("role")
+	if !models.IsValidRole(role) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	granted, err := rolePermissions(role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":        role,
+		"permissions": granted,
+	})
+}
+
+// UpdateRolePermissions replaces the permissions granted to a role. The
+// change applies to the role's users on their next request.
+func UpdateRolePermissions(c *gin.Context) {
+	role := c.Param("role")
+	if !models.IsValidRole(role) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	var req UpdateRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	// Keep the catalogue order and drop duplicates
+	requested := map[string]bool{}
+	for _, permission := range req.Permissions {
+		if !models.IsValidPermission(permission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + permission})
+			return
+		}
+		requested[permission] = true
+	}
+	granted := []string{}
+	for _, p := range models.Permissions {
+		if requested[p.Name] {
+			granted = append(granted, p.Name)
+		}
+	}
+
+	// Someone must always be able to edit the grants
+	if !requested[models.PermPermissionsManage] {
+		var others int64
+		err := config.DB.Model(&models.RolePermission{}).
+			Where("permission = ? AND role != ?", models.PermPermissionsManage, role).
+			Count(&others).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update permissions"})
+			return
+		}
+		if others == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one role must keep " + models.PermPermissionsManage})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for _, permission := range granted {
+			if err := tx.Create(&models.RolePermission{Role: role, Permission: permission}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update permissions"})
+		return
+	}
+
+	if err := middleware.LoadPermissions(); err != nil {
+		log.Printf("Failed to reload permissions: %v", err)
+	}
+
+	recordAudit(c, models.AuditPermissionsUpdate, nil, role+": "+strings.Join(granted, ", "))
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":        role,
+		"permissions": granted,
+	})
+}
+
+// rolePermissions lists the permissions granted to role in catalogue order
+func rolePermissions(role string) ([]string, error) {
+	var rows []models.RolePermission
+	if err := config.DB.Where("role = ?", role).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	held := map[string]bool{}
+	for _, row := range rows {
+		held[row.Permission] = true
+	}
+	granted := []string{}
+	for _, p := range models.Permissions {
+		if held[p.Name] {
+			granted = append(granted, p.Name)
+		}
+	}
+	return granted, nil
+}
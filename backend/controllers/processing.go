@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterJobHandlers installs the handlers for background video processing
+func RegisterJobHandlers() {
+	jobs.Register(models.JobTypeThumbnails, generateThumbnailsJob)
+	jobs.Register(models.JobTypeTranscode, transcodeJob)
+	jobs.Register(models.JobTypeHLS, packageHLSJob)
+	jobs.Register(models.JobTypeExport, exportJob)
+}
+
+// enqueueProcessing queues the background work for a new video. Without
+// ffmpeg there is nothing to do and the video stays ready as uploaded.
+func enqueueProcessing(video *models.Video) {
+	if !media.Available {
+		return
+	}
+
+	if _, err := jobs.Enqueue(models.JobTypeThumbnails, &video.ID, ""); err != nil {
+		log.Printf("Failed to queue thumbnails for video %d: %v", video.ID, err)
+	}
+
+	if config.AppConfig.Media.Transcode {
+		for _, profile := range media.Profiles {
+			if _, err := jobs.Enqueue(models.JobTypeTranscode, &video.ID, profile.Name); err != nil {
+				log.Printf("Failed to queue %s rendition for video %d: %v", profile.Name, video.ID, err)
+			}
+		}
+	}
+
+	if config.AppConfig.Media.HLS {
+		if _, err := jobs.Enqueue(models.JobTypeHLS, &video.ID, ""); err != nil {
+			log.Printf("Failed to queue HLS packaging for video %d: %v", video.ID, err)
+		}
+	}
+}
+
+// GetJobs lists background jobs, newest first, optionally filtered by
+// status, type or video_id. Only jobs for videos the user may see are
+// listed; jobs without a video, such as exports, need videos.view.all.
+func GetJobs(c *gin.Context) {
+	query := config.DB.Select("jobs.*").
+		Joins("LEFT JOIN videos ON videos.id = jobs.video_id").
+		Scopes(visibleVideos(c)).
+		Order("jobs.id DESC")
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("jobs.status = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("jobs.type = ?", jobType)
+	}
+	if videoID := c.Query("video_id"); videoID != "" {
+		query = query.Where("jobs.video_id = ?", videoID)
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed > 200 {
+			parsed = 200
+		}
+		limit = parsed
+	}
+
+	var list []models.Job
+	if err := query.Limit(limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": list,
+	})
+}
+
+// GetVideoProcessing reports whether a video is still being processed
+// together with its jobs
+func GetVideoProcessing(c *gin.Context) {
+	var video models.Video
+	if err := findVisibleVideo(c, &video); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	var list []models.Job
+	if err := config.DB.Where("video_id = ?", video.ID).Order("id").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":          video.ID,
+		"processing_status": video.ProcessingStatus,
+		"jobs":              list,
+	})
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/models"
+)
+
+func TestEnqueueProcessingNeedsFFmpeg(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	defer func(available bool) { media.Available = available }(media.Available)
+
+	tests := []struct {
+		available bool
+		want      int64
+	}{
+		{false, 0},
+		// Thumbnails, one job per rendition profile and HLS
+		{true, int64(2 + len(media.Profiles))},
+	}
+
+	for _, tt := range tests {
+		media.Available = tt.available
+		video, _ := newTestVideo(t, user, nil, 100)
+
+		enqueueProcessing(video)
+
+		var count int64
+		config.DB.Model(&models.Job{}).Where("video_id = ?", video.ID).Count(&count)
+		if count != tt.want {
+			t.Errorf("with ffmpeg available = %v, queued %d jobs, want %d", tt.available, count, tt.want)
+		}
+
+		config.DB.First(video, video.ID)
+		wantStatus := models.ProcessingStatusReady
+		if tt.available {
+			wantStatus = models.ProcessingStatusProcessing
+		}
+		if video.ProcessingStatus != wantStatus {
+			t.Errorf("with ffmpeg available = %v, status = %q, want %q", tt.available, video.ProcessingStatus, wantStatus)
+		}
+	}
+}
+
+func TestGetJobsOnlyListsVisibleVideos(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	owner := createTestUser(t, "owner", models.RoleUser)
+	other := createTestUser(t, "other", models.RoleUser)
+	supervisor := createTestUser(t, "supervisor", models.RoleSupervisor)
+
+	own, _ := newTestVideo(t, owner, nil, 100)
+	foreign, _ := newTestVideo(t, other, nil, 100)
+	for _, job := range []models.Job{
+		{Type: models.JobTypeThumbnails, VideoID: &own.ID, Status: models.JobStatusQueued},
+		{Type: models.JobTypeThumbnails, VideoID: &foreign.ID, Status: models.JobStatusQueued},
+		{Type: models.JobTypeExport, Payload: "1", Status: models.JobStatusQueued},
+	} {
+		if err := config.DB.Create(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		user *models.User
+		want int
+	}{
+		{owner, 1},
+		{supervisor, 3},
+	}
+
+	for _, tt := range tests {
+		w := performRequest(t, tt.user, "GET", "/jobs", "/jobs", nil, GetJobs)
+		assertStatus(t, w, http.StatusOK)
+
+		var body struct {
+			Jobs []models.Job `json:"jobs"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Jobs) != tt.want {
+			t.Errorf("%s sees %d jobs, want %d", tt.user.Username, len(body.Jobs), tt.want)
+		}
+		for _, job := range body.Jobs {
+			if tt.user == owner && (job.VideoID == nil || *job.VideoID != own.ID) {
+				t.Errorf("owner sees job %d for video %v", job.ID, job.VideoID)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateProfileRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+}
+
+// GetProfile returns the signed in user with their assignments and
+// permissions
+func GetProfile(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := loadUserAssignments(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"permissions": grantedPermissions(user.Role),
+	})
+}
+
+// UpdateProfile changes the signed in user's username and email. Role,
+// status and assignments are managed through the user endpoints.
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Check if new username conflicts with existing user
+	var existingUser models.User
+	if err := config.DB.Where("username = ? AND id != ?", req.Username, user.ID).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	// Check if new email conflicts with existing user
+	if err := config.DB.Where("email = ? AND id != ?", req.Email, user.ID).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"username": req.Username,
+		"email":    req.Email,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+	user.Username = req.Username
+	user.Email = req.Email
+	if err := loadUserAssignments(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"user":    user,
+	})
+}
@@ -11,10 +11,12 @@ import (
 
 type CreateRoomRequest struct {
 	RoomNumber string `json:"room_number" binding:"required"`
+	Floor      string `json:"floor" binding:"max=20"`
 }
 
 type UpdateRoomRequest struct {
-	RoomNumber string `json:"room_number" binding:"required"`
+	RoomNumber string  `json:"room_number" binding:"required"`
+	Floor      *string `json:"floor" binding:"omitempty,max=20"` // unchanged when omitted
 }
 
 // GetRooms returns list of rooms
@@ -47,6 +49,7 @@ func CreateRoom(c *gin.Context) {
 
 	room := models.Room{
 		RoomNumber: req.RoomNumber,
+		Floor:      req.Floor,
 	}
 
 	if err := config.DB.Create(&room).Error; err != nil {
@@ -84,6 +87,9 @@ func UpdateRoom(c *gin.Context) {
 	}
 
 	room.RoomNumber = req.RoomNumber
+	if req.Floor != nil {
+		room.Floor = *req.Floor
+	}
 
 	if err := config.DB.Save(&room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
@@ -118,6 +124,7 @@ func DeleteRoom(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
 		return
 	}
+	config.DB.Where("room_id = ?", room.ID).Delete(&models.UserRoom{})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Room deleted successfully",
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+var errSessionInvalid = errors.New("invalid session")
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; presenting one that was
+// already exchanged revokes the whole session, since it must have leaked.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	hash := hashRefreshToken(req.RefreshToken)
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	var session models.Session
+	var user models.User
+	var reused bool
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+			reused = tx.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error == nil
+			return errSessionInvalid
+		}
+		if session.RevokedAt != nil || session.ExpiresAt.Before(time.Now()) {
+			return errSessionInvalid
+		}
+		if err := tx.Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+			return errSessionInvalid
+		}
+
+		// The hash condition makes concurrent refreshes with the same token
+		// race for a single winner
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+			Updates(map[string]interface{}{
+				"refresh_token_hash":  hashRefreshToken(refreshToken),
+				"previous_token_hash": hash,
+				"expires_at":          time.Now().Add(config.AppConfig.JWT.RefreshTTL),
+				"ip_address":          c.ClientIP(),
+				"user_agent":          truncate(c.Request.UserAgent(), 255),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSessionInvalid
+		}
+		return nil
+	})
+	if reused {
+		log.Printf("Refresh token reuse on session %s of user %d, revoking", session.ID, session.UserID)
+		if err := revokeSession(config.DB, session.ID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", session.ID, err)
+		}
+	}
+	if errors.Is(err, errSessionInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(config.AppConfig.JWT.Expiry.Seconds()),
+	})
+}
+
+// startSession opens a login session for user and returns its access and
+// refresh tokens
+func startSession(c *gin.Context, user *models.User) (string, string, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	session := models.Session{
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		IPAddress:        c.ClientIP(),
+		UserAgent:        truncate(c.Request.UserAgent(), 255),
+		ExpiresAt:        time.Now().Add(config.AppConfig.JWT.RefreshTTL),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return "", "", err
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// revokeSession ends a session; its access and refresh tokens stop working
+func revokeSession(tx *gorm.DB, sessionID string) error {
+	return tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeUserSessions ends every session of a user
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeOtherSessions ends every session of a user except keepID
+func revokeOtherSessions(tx *gorm.DB, userID uint, keepID string) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND id != ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// newRefreshToken returns 256 random bits, URL-safe encoded
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartSessionCleanup periodically removes expired login sessions,
+// abandoned passkey ceremonies and two-factor logins, stale failed-login
+// counters and spent password reset codes
+func StartSessionCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupExpiredSessions()
+			cleanupExpiredWebAuthnChallenges()
+			cleanupLoginThrottles()
+			cleanupPasswordResets()
+			cleanupTwoFactorChallenges()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpiredSessions() {
+	result := config.DB.Where("expires_at < ?", time.Now()).Delete(&models.Session{})
+	if result.Error != nil {
+		log.Printf("Failed to remove expired sessions: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Removed %d expired sessions", result.RowsAffected)
+	}
+}
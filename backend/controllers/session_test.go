@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginTestUser opens a session for user and returns its access and
+// refresh tokens
+func loginTestUser(t *testing.T, user *models.User) (string, string) {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/login", nil)
+
+	token, refreshToken, err := startSession(c, user)
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	return token, refreshToken
+}
+
+// sessionOf loads the session a refresh token currently belongs to
+func sessionOf(t *testing.T, refreshToken string) models.Session {
+	t.Helper()
+	var session models.Session
+	if err := config.DB.Where("refresh_token_hash = ?", hashRefreshToken(refreshToken)).First(&session).Error; err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	return session
+}
+
+func refresh(t *testing.T, refreshToken string) *httptest.ResponseRecorder {
+	t.Helper()
+	return performRequest(t, nil, "POST", "/refresh", "/refresh", RefreshRequest{RefreshToken: refreshToken}, RefreshToken)
+}
+
+// refreshedTokens decodes a successful refresh response
+func refreshedTokens(t *testing.T, w *httptest.ResponseRecorder) (string, string) {
+	t.Helper()
+	assertStatus(t, w, http.StatusOK)
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Token == "" || body.RefreshToken == "" {
+		t.Fatalf("refresh returned %s", w.Body.String())
+	}
+	return body.Token, body.RefreshToken
+}
+
+// authorized serves handler behind AuthMiddleware with a bearer token
+func authorized(token, method string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, "/protected", AuthMiddleware(), handler)
+
+	req := httptest.NewRequest(method, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func noContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	_, first := loginTestUser(t, user)
+	session := sessionOf(t, first)
+
+	token, second := refreshedTokens(t, refresh(t, first))
+	if second == first {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	rotated := sessionOf(t, second)
+	if rotated.ID != session.ID {
+		t.Errorf("refresh moved to session %s, want %s", rotated.ID, session.ID)
+	}
+	if rotated.PreviousTokenHash != hashRefreshToken(first) {
+		t.Error("previous token hash does not record the exchanged token")
+	}
+	if w := authorized(token, "GET", noContent); w.Code != http.StatusNoContent {
+		t.Errorf("refreshed access token: status %d", w.Code)
+	}
+
+	// The new token can be exchanged in turn
+	_, third := refreshedTokens(t, refresh(t, second))
+	if sessionOf(t, third).PreviousTokenHash != hashRefreshToken(second) {
+		t.Error("second rotation did not record the exchanged token")
+	}
+}
+
+func TestRefreshTokenReplayRevokesSession(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	_, stolen := loginTestUser(t, user)
+	otherToken, _ := loginTestUser(t, user)
+
+	// The legitimate client refreshes first; the stolen copy is replayed
+	token, current := refreshedTokens(t, refresh(t, stolen))
+	session := sessionOf(t, current)
+
+	assertStatus(t, refresh(t, stolen), http.StatusUnauthorized)
+
+	config.DB.First(&session, "id = ?", session.ID)
+	if session.RevokedAt == nil {
+		t.Fatal("replaying a rotated refresh token did not revoke the session")
+	}
+	assertStatus(t, refresh(t, current), http.StatusUnauthorized)
+	if w := authorized(token, "GET", noContent); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token of the revoked session: status %d", w.Code)
+	}
+
+	// Other logins of the same user are not affected
+	if w := authorized(otherToken, "GET", noContent); w.Code != http.StatusNoContent {
+		t.Errorf("access token of another session: status %d", w.Code)
+	}
+}
+
+func TestRefreshTokenRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(user *models.User, session *models.Session)
+	}{
+		{"expired session", func(user *models.User, session *models.Session) {
+			config.DB.Model(session).Update("expires_at", time.Now().Add(-time.Minute))
+		}},
+		{"revoked session", func(user *models.User, session *models.Session) {
+			config.DB.Model(session).Update("revoked_at", time.Now())
+		}},
+		{"deactivated user", func(user *models.User, session *models.Session) {
+			config.DB.Model(user).Update("is_active", false)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			user := createTestUser(t, "alice", models.RoleUser)
+			_, refreshToken := loginTestUser(t, user)
+			session := sessionOf(t, refreshToken)
+
+			tt.setup(user, &session)
+
+			assertStatus(t, refresh(t, refreshToken), http.StatusUnauthorized)
+			if sessionOf(t, refreshToken).ID != session.ID {
+				t.Error("rejected refresh token was rotated")
+			}
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		setupTestDB(t)
+		assertStatus(t, refresh(t, "not-a-refresh-token"), http.StatusUnauthorized)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		setupTestDB(t)
+		w := performRequest(t, nil, "POST", "/refresh", "/refresh", gin.H{}, RefreshToken)
+		assertStatus(t, w, http.StatusBadRequest)
+	})
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	token, refreshToken := loginTestUser(t, user)
+	otherToken, _ := loginTestUser(t, user)
+
+	if w := authorized(token, "GET", noContent); w.Code != http.StatusNoContent {
+		t.Fatalf("access token before logout: status %d", w.Code)
+	}
+
+	w := authorized(token, "POST", Logout)
+	assertStatus(t, w, http.StatusOK)
+
+	if w := authorized(token, "GET", noContent); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: status %d", w.Code)
+	}
+	assertStatus(t, refresh(t, refreshToken), http.StatusUnauthorized)
+
+	if w := authorized(otherToken, "GET", noContent); w.Code != http.StatusNoContent {
+		t.Errorf("access token of another session after logout: status %d", w.Code)
+	}
+}
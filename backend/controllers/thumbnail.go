@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// thumbnailTimeout bounds frame extraction for one video
+const thumbnailTimeout = 2 * time.Minute
+
+// generateThumbnailsJob is the queue handler for models.JobTypeThumbnails
+func generateThumbnailsJob(ctx context.Context, job *models.Job) error {
+	if job.VideoID == nil {
+		return jobs.Permanent(errors.New("thumbnail job without a video"))
+	}
+	return generateThumbnails(ctx, *job.VideoID)
+}
+
+// GetVideoThumbnail serves the poster frame of a video, or the preview
+// sprite strip with ?variant=sprite
+func GetVideoThumbnail(c *gin.Context) {
+	var video models.Video
+	if err := findVisibleVideo(c, &video); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	key := video.ThumbnailPath
+	variant := c.DefaultQuery("variant", "poster")
+	switch variant {
+	case "poster":
+	case "sprite":
+		key = video.SpritePath
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thumbnail variant"})
+		return
+	}
+	if key == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not available"})
+		return
+	}
+
+	file, info, err := storage.Service.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not available"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to open thumbnail %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open thumbnail"})
+		return
+	}
+	defer file.Close()
+
+	// A video's content never changes, so its frames can be cached for long
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", "private, max-age=86400")
+	if video.ContentHash != "" {
+		c.Header("ETag", fmt.Sprintf(`"%s-%s"`, video.ContentHash, variant))
+	}
+
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, file)
+}
+
+// generateThumbnails extracts the poster frame and sprite strip for a
+// video and records them on it. Videos sharing content share the images,
+// so existing ones are reused.
+func generateThumbnails(ctx context.Context, videoID uint) error {
+	var video models.Video
+	if err := config.DB.First(&video, videoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted before the job ran
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	input, cleanup, err := localVideoFile(video.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(ctx, thumbnailTimeout)
+	defer cancel()
+
+	cfg := config.AppConfig.Media
+	prefix := derivedPrefix(&video)
+	duration := video.Metadata.Duration
+
+	posterKey := prefix + "poster.jpg"
+	err = storeDerivedFile(ctx, posterKey, func(ctx context.Context, output string) error {
+		return media.Frames.Poster(ctx, input, output, media.PosterOffset(duration), cfg.ThumbnailWidth)
+	})
+	if errors.Is(err, media.ErrUnavailable) {
+		// Retrying will not install ffmpeg
+		return jobs.Permanent(fmt.Errorf("poster: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("poster: %w", err)
+	}
+	updates := map[string]interface{}{"thumbnail_path": posterKey}
+
+	// Sprites need the duration to spread frames over the video
+	if cfg.SpriteFrames > 0 && duration > 0 {
+		spriteKey := prefix + "sprite.jpg"
+		err = storeDerivedFile(ctx, spriteKey, func(ctx context.Context, output string) error {
+			return media.Frames.Sprite(ctx, input, output, duration, cfg.SpriteFrames, cfg.ThumbnailWidth)
+		})
+		if err != nil {
+			log.Printf("Failed to build sprite for video %d: %v", video.ID, err)
+		} else {
+			updates["sprite_path"] = spriteKey
+		}
+	}
+
+	return config.DB.Model(&video).Updates(updates).Error
+}
+
+// derivedPrefix is the storage prefix for files generated from a video,
+// kept next to the content-addressed objects so identical uploads share them
+func derivedPrefix(video *models.Video) string {
+	if video.ContentHash != "" {
+		return derivedPrefixForHash(video.ContentHash)
+	}
+	return fmt.Sprintf("derived/video-%d/", video.ID)
+}
+
+func derivedPrefixForHash(hash string) string {
+	return path.Join("derived", hash) + "/"
+}
+
+// deleteDerivedContent removes every generated file below prefix
+func deleteDerivedContent(prefix string) {
+	files, err := storage.Service.ListFiles(prefix)
+	if err != nil {
+		log.Printf("Failed to list derived files %s: %v", prefix, err)
+		return
+	}
+
+	for _, file := range files {
+		if err := storage.Service.Delete(file.Key); err != nil {
+			log.Printf("Failed to delete derived file %s: %v", file.Key, err)
+		}
+	}
+}
+
+// storeDerivedFile runs generate into a temporary file and uploads the
+// result under key, unless key already exists
+func storeDerivedFile(ctx context.Context, key string, generate func(ctx context.Context, output string) error) error {
+	if _, err := storage.Service.Stat(key); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(config.AppConfig.Upload.TempDir, "derived-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, path.Base(key))
+	if err := generate(ctx, output); err != nil {
+		return err
+	}
+
+	return uploadDerivedFile(key, output)
+}
+
+// localVideoFile returns a path on local disk holding the stored object,
+// downloading it to a temporary file when the backend is remote
+func localVideoFile(key string) (string, func(), error) {
+	if local, ok := storage.Service.(interface {
+		LocalPath(key string) (string, error)
+	}); ok {
+		file, err := local.LocalPath(key)
+		return file, func() {}, err
+	}
+
+	object, _, err := storage.Service.Open(key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer object.Close()
+
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.CreateTemp(config.AppConfig.Upload.TempDir, "source-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := io.Copy(tmp, object); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("download %s: %w", key, err)
+	}
+	tmp.Close()
+
+	return tmp.Name(), func() { os.Remove(tmp.Name()) }, nil
+}
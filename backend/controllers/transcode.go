@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transcodeJob is the queue handler for models.JobTypeTranscode
+func transcodeJob(ctx context.Context, job *models.Job) error {
+	if job.VideoID == nil {
+		return jobs.Permanent(errors.New("transcode job without a video"))
+	}
+
+	profile, ok := media.LookupProfile(job.Payload)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("unknown rendition quality %q", job.Payload))
+	}
+
+	return transcodeVideo(ctx, *job.VideoID, profile)
+}
+
+// transcodeVideo produces the rendition of a video described by profile
+// and records it. Sources that already satisfy the profile are served as
+// they are instead of being re-encoded.
+func transcodeVideo(ctx context.Context, videoID uint, profile media.Profile) error {
+	var video models.Video
+	if err := config.DB.First(&video, videoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	metadata := video.Metadata
+	width, height := profile.OutputSize(metadata.Width, metadata.Height, metadata.Rotation)
+	rendition := models.Rendition{
+		VideoID:     video.ID,
+		Quality:     profile.Name,
+		ContentType: media.TypeMP4,
+		Width:       width,
+		Height:      height,
+	}
+
+	source := &media.Info{
+		Container:  metadata.Container,
+		VideoCodec: metadata.VideoCodec,
+		AudioCodec: metadata.AudioCodec,
+		Width:      metadata.Width,
+		Height:     metadata.Height,
+		Rotation:   metadata.Rotation,
+	}
+	if profile.Compatible(source) {
+		rendition.StorageKey = video.FilePath
+		rendition.Size = video.FileSize
+		return saveRendition(&rendition)
+	}
+
+	input, cleanup, err := localVideoFile(video.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	key := derivedPrefix(&video) + profile.Name + ".mp4"
+	err = storeDerivedFile(ctx, key, func(ctx context.Context, output string) error {
+		return media.Transcoder.Transcode(ctx, input, output, profile)
+	})
+	if errors.Is(err, media.ErrUnavailable) {
+		return jobs.Permanent(fmt.Errorf("%s rendition: %w", profile.Name, err))
+	}
+	if err != nil {
+		return fmt.Errorf("%s rendition: %w", profile.Name, err)
+	}
+
+	info, err := storage.Service.Stat(key)
+	if err != nil {
+		return err
+	}
+
+	rendition.StorageKey = key
+	rendition.Size = info.Size
+	return saveRendition(&rendition)
+}
+
+var errUnknownQuality = errors.New("unknown quality")
+
+// findRendition looks up the rendition a stream request asked for. "auto"
+// prefers the normalized rendition and returns nil, meaning the original,
+// while it is not ready yet.
+func findRendition(videoID uint, quality string) (*models.Rendition, error) {
+	lookup := quality
+	if quality == "auto" {
+		lookup = models.QualityNormalized
+	}
+	if _, ok := media.LookupProfile(lookup); !ok {
+		return nil, errUnknownQuality
+	}
+
+	var rendition models.Rendition
+	err := config.DB.Where("video_id = ? AND quality = ?", videoID, lookup).First(&rendition).Error
+	if quality == "auto" && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rendition, nil
+}
+
+// saveRendition creates or replaces the rendition row for its quality
+func saveRendition(rendition *models.Rendition) error {
+	return config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "video_id"}, {Name: "quality"}},
+		DoUpdates: clause.AssignmentColumns([]string{"storage_key", "content_type", "size", "width", "height", "updated_at"}),
+	}).Create(rendition).Error
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination,checksum"
+	tusAlgorithms = "md5,sha1,sha256"
+
+	// 460 is defined by the tus checksum extension
+	statusChecksumMismatch = 460
+)
+
+// TusMiddleware sets the headers every tus response carries and rejects
+// requests made with an unsupported protocol version
+func TusMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.AbortWithStatus(http.StatusPreconditionFailed)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TusOptions advertises the supported tus version and extensions
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(config.AppConfig.Upload.MaxFileSize, 10))
+	c.Header("Tus-Checksum-Algorithm", tusAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreateUpload implements the tus creation extension. Upload-Metadata
+// must carry room_id and may carry filename and filetype.
+func TusCreateUpload(c *gin.Context) {
+	userID := middleware.CurrentPrincipal(c).UserID
+
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length header is required"})
+		return
+	}
+
+	if size > config.AppConfig.Upload.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata header"})
+		return
+	}
+
+	roomID, err := strconv.ParseUint(metadata["room_id"], 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "room_id metadata is required"})
+		return
+	}
+
+	// Check if room exists
+	var room models.Room
+	if err := config.DB.First(&room, roomID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room not found"})
+		return
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "video"
+	}
+
+	session, err := newUploadSession(models.UploadProtocolTus, userID, room.ID, filename, metadata["filetype"], size, size)
+	if err != nil {
+		log.Printf("Failed to create tus upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", "/api/videos/tus/"+session.ID)
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// TusUploadOffset reports how many bytes of an upload have been received
+func TusUploadOffset(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolTus)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(uploadedBytes(session), 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.TotalSize, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.VideoID != nil {
+		c.Header("X-Video-ID", strconv.FormatUint(uint64(*session.VideoID), 10))
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusPatchUpload appends the request body at Upload-Offset. Once the last
+// byte arrives the upload is turned into a video just like UploadVideo.
+func TusPatchUpload(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolTus)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	defer lockUploadSession(session.ID)()
+	if !reloadUploadSession(c, session) {
+		return
+	}
+
+	if session.Status != models.UploadStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+
+	offset := uploadedBytes(session)
+	requestOffset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || requestOffset != offset {
+		c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match current offset"})
+		return
+	}
+
+	var checksum hash.Hash
+	var expected []byte
+	if value := c.GetHeader("Upload-Checksum"); value != "" {
+		checksum, expected, err = parseTusChecksum(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported or malformed Upload-Checksum"})
+			return
+		}
+	}
+
+	body := io.Reader(c.Request.Body)
+	if checksum != nil {
+		body = io.TeeReader(body, checksum)
+	}
+
+	remaining := session.TotalSize - offset
+	written, err := writeUploadChunk(session.TempPath, offset, remaining, body)
+	if err != nil {
+		log.Printf("Failed to write tus upload %s: %v", session.ID, err)
+
+		// Keep what arrived before the body broke off so the client resumes
+		// from there. Without a checksum there is nothing to verify it against.
+		if checksum == nil && written > 0 && written <= remaining {
+			if recordTusChunk(session, offset, written) == nil {
+				offset += written
+			}
+		}
+		c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chunk"})
+		return
+	}
+	if written > remaining {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Body exceeds Upload-Length"})
+		return
+	}
+
+	// Bytes that fail verification are simply not recorded, so the next
+	// PATCH overwrites them at the same offset
+	if checksum != nil && !bytes.Equal(checksum.Sum(nil), expected) {
+		c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+		c.JSON(statusChecksumMismatch, gin.H{"error": "Checksum mismatch"})
+		return
+	}
+
+	if offset < media.SniffLength && rejectNonVideoUpload(c, session, offset+written) {
+		return
+	}
+
+	if written > 0 {
+		if err := recordTusChunk(session, offset, written); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record chunk"})
+			return
+		}
+	}
+
+	newOffset := offset + written
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+
+	if newOffset == session.TotalSize {
+		video, _, duplicateOf, err := finalizeUploadSession(session)
+		if errors.Is(err, media.ErrNotVideo) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported video format"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to finalize tus upload %s: %v", session.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save video"})
+			return
+		}
+		c.Header("X-Video-ID", strconv.FormatUint(uint64(video.ID), 10))
+		if duplicateOf != nil {
+			c.Header("X-Duplicate-Of", strconv.FormatUint(uint64(duplicateOf.ID), 10))
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// recordTusChunk stores the bytes written at offset as the session's next chunk
+func recordTusChunk(session *models.UploadSession, offset, size int64) error {
+	var index int64
+	config.DB.Model(&models.UploadChunk{}).Where("session_id = ?", session.ID).Count(&index)
+
+	chunk := models.UploadChunk{SessionID: session.ID, Index: int(index), Offset: offset, Size: size}
+	return saveUploadChunk(session, &chunk)
+}
+
+// TusTerminateUpload implements the tus termination extension
+func TusTerminateUpload(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolTus)
+	if !ok {
+		return
+	}
+
+	// Wait for a PATCH in progress rather than deleting the file it writes;
+	// the reload then sees whether that PATCH finalized the upload
+	defer lockUploadSession(session.ID)()
+	if !reloadUploadSession(c, session) {
+		return
+	}
+
+	switch session.Status {
+	case models.UploadStatusAssembling:
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is being finalized"})
+		return
+	case models.UploadStatusCompleted:
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+
+	if err := removeUploadSession(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to terminate upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if len(parts) == 1 {
+			metadata[parts[0]] = ""
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		metadata[parts[0]] = string(value)
+	}
+
+	return metadata, nil
+}
+
+var errInvalidChecksum = errors.New("unsupported checksum")
+
+// parseTusChecksum decodes "<algorithm> <base64 digest>"
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 {
+		return nil, nil, errInvalidChecksum
+	}
+
+	digest, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch parts[0] {
+	case "md5":
+		return md5.New(), digest, nil
+	case "sha1":
+		return sha1.New(), digest, nil
+	case "sha256":
+		return sha256.New(), digest, nil
+	}
+
+	return nil, nil, errInvalidChecksum
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brokenReader returns its data and then fails like a dropped connection
+type brokenReader struct {
+	data *bytes.Reader
+}
+
+func (r *brokenReader) Read(p []byte) (int, error) {
+	if r.data.Len() == 0 {
+		return 0, errors.New("connection reset")
+	}
+	return r.data.Read(p)
+}
+
+// newTestTusUpload starts a tus upload of size bytes for user
+func newTestTusUpload(t *testing.T, user *models.User, size int64) *models.UploadSession {
+	t.Helper()
+	return newTestUploadSession(t, user, models.UploadProtocolTus, size, size)
+}
+
+// newTestUploadSession starts an upload of size bytes for user into room 101
+func newTestUploadSession(t *testing.T, user *models.User, protocol string, size, chunkSize int64) *models.UploadSession {
+	t.Helper()
+	config.AppConfig.Upload.TempDir = t.TempDir()
+
+	room := models.Room{RoomNumber: "101", Floor: "1"}
+	if err := config.DB.Where(room).FirstOrCreate(&room).Error; err != nil {
+		t.Fatalf("create room: %v", err)
+	}
+	session, err := newUploadSession(protocol, user.ID, room.ID, "clip.mp4", "video/mp4", size, chunkSize)
+	if err != nil {
+		t.Fatalf("create upload: %v", err)
+	}
+	return session
+}
+
+// patchTusUpload sends body as a PATCH at offset, with an Upload-Checksum
+// header when checksum is set
+func patchTusUpload(user *models.User, session *models.UploadSession, offset int64, body io.Reader, checksum string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/tus/"+session.ID, body)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if checksum != "" {
+		req.Header.Set("Upload-Checksum", checksum)
+	}
+
+	return serveRequest(user, "/tus/:upload_id", req, TusPatchUpload)
+}
+
+func TestTusPatchKeepsBytesReceivedBeforeFailure(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestTusUpload(t, user, 1<<20)
+
+	w := patchTusUpload(user, session, 0, &brokenReader{data: bytes.NewReader(make([]byte, 1000))}, "")
+	assertStatus(t, w, http.StatusInternalServerError)
+	if got := w.Header().Get("Upload-Offset"); got != "1000" {
+		t.Fatalf("Upload-Offset = %q, want 1000", got)
+	}
+	if got := uploadedBytes(session); got != 1000 {
+		t.Fatalf("recorded %d bytes, want 1000", got)
+	}
+
+	// The client resumes from the reported offset
+	w = patchTusUpload(user, session, 1000, bytes.NewReader(make([]byte, 500)), "")
+	assertStatus(t, w, http.StatusNoContent)
+	if got := w.Header().Get("Upload-Offset"); got != "1500" {
+		t.Fatalf("Upload-Offset = %q, want 1500", got)
+	}
+}
+
+func TestTusPatchDropsUnverifiedPartialChunk(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestTusUpload(t, user, 1<<20)
+
+	body := &brokenReader{data: bytes.NewReader(make([]byte, 1000))}
+	w := patchTusUpload(user, session, 0, body, "sha1 2jmj7l5rSw0yVb/vlWAYkK/YBwk=")
+	assertStatus(t, w, http.StatusInternalServerError)
+	if got := uploadedBytes(session); got != 0 {
+		t.Fatalf("recorded %d bytes, want 0", got)
+	}
+}
+
+func TestTusPatchSerializesConcurrentWrites(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestTusUpload(t, user, 1<<20)
+
+	const requests = 4
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = patchTusUpload(user, session, 0, bytes.NewReader(make([]byte, 100)), "").Code
+		}(i)
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusNoContent:
+			accepted++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("%d PATCHes at offset 0 were accepted, want 1", accepted)
+	}
+	if got := uploadedBytes(session); got != 100 {
+		t.Fatalf("recorded %d bytes, want 100", got)
+	}
+}
+
+// testMP4 returns size bytes that sniff as an MP4: an ftyp box followed by
+// an mdat box of filler
+func testMP4(size int) []byte {
+	data := make([]byte, size)
+	copy(data, []byte{0, 0, 0, 24, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm', 0, 0, 2, 0, 'i', 's', 'o', 'm', 'm', 'p', '4', '1'})
+	mdat := size - 24
+	copy(data[24:], []byte{byte(mdat >> 24), byte(mdat >> 16), byte(mdat >> 8), byte(mdat), 'm', 'd', 'a', 't'})
+	return data
+}
+
+func tusRequest(user *models.User, session *models.UploadSession, method string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/tus/"+session.ID, nil)
+	return serveRequest(user, "/tus/:upload_id", req, handler)
+}
+
+func TestTusCompletedUploadReportsFullOffset(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestTusUpload(t, user, 1000)
+
+	w := patchTusUpload(user, session, 0, bytes.NewReader(testMP4(1000)), "")
+	assertStatus(t, w, http.StatusNoContent)
+	if w.Header().Get("X-Video-ID") == "" {
+		t.Fatal("final PATCH did not create a video")
+	}
+
+	// A client that lost the response above asks where to resume
+	w = tusRequest(user, session, http.MethodHead, TusUploadOffset)
+	assertStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("Upload-Offset"); got != "1000" {
+		t.Fatalf("Upload-Offset = %q after completion, want 1000", got)
+	}
+	if w.Header().Get("X-Video-ID") == "" {
+		t.Error("HEAD after completion lacks X-Video-ID")
+	}
+
+	// Terminating a finished upload must not remove the session
+	w = tusRequest(user, session, http.MethodDelete, TusTerminateUpload)
+	assertStatus(t, w, http.StatusConflict)
+	var count int64
+	config.DB.Model(&models.UploadSession{}).Where("id = ?", session.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("terminate removed a completed upload")
+	}
+}
+
+func TestCleanupExpiredUploadSessions(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	expired := newTestTusUpload(t, user, 1000)
+	active := newTestTusUpload(t, user, 1000)
+	config.DB.Model(expired).Update("expires_at", time.Now().Add(-time.Minute))
+
+	// Both sessions have been written to, so both have a lock
+	lockUploadSession(expired.ID)()
+	lockUploadSession(active.ID)()
+
+	cleanupExpiredUploadSessions()
+
+	var ids []string
+	config.DB.Model(&models.UploadSession{}).Pluck("id", &ids)
+	if len(ids) != 1 || ids[0] != active.ID {
+		t.Fatalf("sessions after cleanup = %v, want only %s", ids, active.ID)
+	}
+	if _, ok := uploadLocks.Load(expired.ID); ok {
+		t.Error("cleanup left the expired session's lock behind")
+	}
+	if _, err := os.Stat(expired.TempPath); !os.IsNotExist(err) {
+		t.Errorf("partial file of the expired session: %v", err)
+	}
+}
@@ -0,0 +1,529 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+// Two-factor authentication uses RFC 6238 TOTP codes from an authenticator
+// app. Users with it enabled sign in in two steps: Login checks the
+// password and returns a challenge token, and VerifyTwoFactorLogin trades
+// the token and a TOTP or recovery code for a session. Passkey logins skip
+// the second step, since a passkey is already a second factor.
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+const (
+	totpPeriod = 30 // seconds per code
+
+	// maxTwoFactorAttempts is how many wrong codes void a login challenge
+	maxTwoFactorAttempts = 5
+
+	recoveryCodeCount = 10
+)
+
+var totpOptions = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// startTwoFactorChallenge responds to a correct password of a user with
+// two-factor authentication enabled
+func startTwoFactorChallenge(c *gin.Context, user *models.User) {
+	token, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	challenge := models.TwoFactorChallenge{
+		ID:        hashRefreshToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(config.AppConfig.TOTP.ChallengeTTL),
+	}
+	if err := config.DB.Create(&challenge).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Two-factor authentication required",
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_in":          int(config.AppConfig.TOTP.ChallengeTTL.Seconds()),
+	})
+}
+
+// VerifyTwoFactorLogin finishes a login started by Login with a TOTP or
+// recovery code and responds like Login. Wrong codes count towards the
+// login lockout.
+func VerifyTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	ip := c.ClientIP()
+	if until := addressLockedUntil(ip); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+
+	var challenge models.TwoFactorChallenge
+	var user models.User
+	found := config.DB.Where("id = ? AND expires_at > ? AND attempts < ?", hashRefreshToken(req.ChallengeToken), time.Now(), maxTwoFactorAttempts).
+		First(&challenge).Error == nil &&
+		config.DB.Where("id = ? AND is_active = ?", challenge.UserID, true).First(&user).Error == nil
+	if !found {
+		recordLoginFailure(nil, ip)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+	if until := userLockedUntil(&user); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+
+	ok, err := useSecondFactor(user.ID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		config.DB.Model(&challenge).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+		recordLoginFailure(&user, ip)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	// Deleting the challenge lets only one of concurrent verifications win
+	result := config.DB.Where("id = ?", challenge.ID).Delete(&models.TwoFactorChallenge{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+	clearLoginFailures(&user)
+
+	completeLogin(c, &user)
+}
+
+// GetTwoFactorStatus reports whether the caller has two-factor
+// authentication enabled and whether their role requires it
+func GetTwoFactorStatus(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var remaining int64
+	if err := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled,
+		"required":                 middleware.TwoFactorRequired(user.Role),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor creates a new TOTP secret for the caller and returns it
+// as text, as an otpauth:// provisioning URI and as a QR code of the URI.
+// The secret is pending until confirmed with EnableTwoFactor.
+func SetupTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      config.AppConfig.TOTP.Issuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+		Digits:      totpOptions.Digits,
+		Algorithm:   totpOptions.Algorithm,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	var qr bytes.Buffer
+	img, err := key.Image(240, 240)
+	if err == nil {
+		err = png.Encode(&qr, img)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	// A new setup replaces any pending secret
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TOTPSecret{UserID: user.ID, Secret: key.Secret()}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      key.Secret(),
+		"otpauth_url": key.URL(),
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	})
+}
+
+// EnableTwoFactor confirms the pending secret with a code from the
+// authenticator app and returns the recovery codes. They are shown only
+// this once.
+func EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	var secret models.TOTPSecret
+	if err := config.DB.Where("user_id = ? AND confirmed_at IS NULL", user.ID).First(&secret).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+	step := matchTOTP(secret.Secret, req.Code, time.Now())
+	if step == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&secret).UpdateColumns(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).UpdateColumn("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	recordAudit(c, models.AuditTwoFactorEnable, nil, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off for the caller. It
+// needs the password and a current code, and is refused for roles that
+// require two-factor authentication.
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if middleware.TwoFactorRequired(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	ip := c.ClientIP()
+	if until := userLockedUntil(&user); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		recordLoginFailure(&user, ip)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if !checkSecondFactor(c, &user, req.Code) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return clearTwoFactor(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	recordAudit(c, models.AuditTwoFactorDisable, nil, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, used or
+// not, with new ones
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if until := userLockedUntil(&user); until != nil {
+		respondLocked(c, *until)
+		return
+	}
+	if !checkSecondFactor(c, &user, req.Code) {
+		return
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Recovery codes regenerated",
+		"recovery_codes": codes,
+	})
+}
+
+// ResetTwoFactor turns two-factor authentication off for a user who lost
+// their authenticator and recovery codes. Users whose role requires it
+// have to enroll again at their next login.
+func ResetTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if user.ID == principal.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot reset your own two-factor authentication"})
+		return
+	}
+	if !outranks(principal, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage users at or above your own role"})
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return clearTwoFactor(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	recordAudit(c, models.AuditTwoFactorReset, nil, user.Username)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// checkSecondFactor verifies a TOTP or recovery code of a signed in user
+// and responds when it is wrong. Wrong codes count towards the lockout.
+func checkSecondFactor(c *gin.Context, user *models.User, code string) bool {
+	ok, err := useSecondFactor(user.ID, code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return false
+	}
+	if !ok {
+		recordLoginFailure(user, c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return false
+	}
+	return true
+}
+
+// useSecondFactor accepts a six digit TOTP code or an unused recovery code
+// of a user. Either is consumed, so a code works only once.
+func useSecondFactor(userID uint, code string) (bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if !isTOTPCode(code) {
+		return useRecoveryCode(userID, code)
+	}
+
+	var secret models.TOTPSecret
+	err := config.DB.Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&secret).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	step := matchTOTP(secret.Secret, code, time.Now())
+	if step == 0 {
+		return false, nil
+	}
+	// Codes of the last accepted time step or earlier are replays
+	result := config.DB.Model(&models.TOTPSecret{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		UpdateColumn("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// useRecoveryCode marks an unused recovery code of a user as used
+func useRecoveryCode(userID uint, code string) (bool, error) {
+	result := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashOneTimeCode(code)).
+		UpdateColumn("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// matchTOTP returns the time step a code belongs to, allowing one step of
+// clock drift either way, or 0 when the code does not match
+func matchTOTP(secret, code string, now time.Time) int64 {
+	current := now.Unix() / totpPeriod
+	for step := current - 1; step <= current+1; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOptions)
+		if err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return 0
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRecoveryCodes discards a user's recovery codes and returns new
+// ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newOneTimeCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hashOneTimeCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// clearTwoFactor removes a user's TOTP secret, recovery codes and pending
+// login challenges and turns two-factor authentication off
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TOTPSecret{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorChallenge{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("two_factor_enabled", false).Error
+}
+
+func cleanupTwoFactorChallenges() {
+	result := config.DB.Where("expires_at < ?", time.Now()).Delete(&models.TwoFactorChallenge{})
+	if result.Error != nil {
+		log.Printf("Failed to remove expired two-factor challenges: %v", result.Error)
+	}
+}
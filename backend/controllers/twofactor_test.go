@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+func TestResetTwoFactorRequiresHigherRank(t *testing.T) {
+	setupTestDB(t)
+	supervisor := createTestUser(t, "supervisor", models.RoleSupervisor)
+	peer := createTestUser(t, "peer", models.RoleSupervisor)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	for _, user := range []*models.User{supervisor, peer, manager} {
+		config.DB.Model(user).UpdateColumn("two_factor_enabled", true)
+	}
+
+	tests := []struct {
+		name   string
+		caller *models.User
+		target *models.User
+		want   int
+	}{
+		{"supervisor resets peer", supervisor, peer, http.StatusForbidden},
+		{"manager resets supervisor", manager, supervisor, http.StatusForbidden},
+		{"supervisor resets self", supervisor, supervisor, http.StatusForbidden},
+		{"supervisor resets manager", supervisor, manager, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(t, tt.caller, http.MethodDelete, "/users/:id/2fa",
+				fmt.Sprintf("/users/%d/2fa", tt.target.ID), nil, ResetTwoFactor)
+			assertStatus(t, w, tt.want)
+
+			var target models.User
+			config.DB.First(&target, tt.target.ID)
+			if target.TwoFactorEnabled != (tt.want != http.StatusOK) {
+				t.Errorf("two_factor_enabled = %v after status %d", target.TwoFactorEnabled, w.Code)
+			}
+		})
+	}
+}
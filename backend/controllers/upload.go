@@ -0,0 +1,576 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateUploadSessionRequest struct {
+	RoomID      uint   `json:"room_id" binding:"required"`
+	Filename    string `json:"filename" binding:"required"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+	ContentType string `json:"content_type"`
+	ChunkSize   int64  `json:"chunk_size"`
+}
+
+// CreateUploadSession starts a resumable chunked upload
+func CreateUploadSession(c *gin.Context) {
+	userID := middleware.CurrentPrincipal(c).UserID
+
+	var req CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if req.Size > config.AppConfig.Upload.MaxFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
+		return
+	}
+
+	// Check if room exists
+	var room models.Room
+	if err := config.DB.First(&room, req.RoomID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room not found"})
+		return
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 || chunkSize > config.AppConfig.Upload.ChunkSize {
+		chunkSize = config.AppConfig.Upload.ChunkSize
+	}
+
+	session, err := newUploadSession(models.UploadProtocolChunked, userID, room.ID, req.Filename, req.ContentType, req.Size, chunkSize)
+	if err != nil {
+		log.Printf("Failed to create upload session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Upload session created",
+		"upload":       session,
+		"total_chunks": chunkCount(session),
+	})
+}
+
+// GetUploadSession reports which byte ranges of an upload have been received
+func GetUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolChunked)
+	if !ok {
+		return
+	}
+
+	var chunks []models.UploadChunk
+	if err := config.DB.Where("session_id = ?", session.ID).Order("byte_offset").Find(&chunks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload chunks"})
+		return
+	}
+
+	received := make(map[int]bool, len(chunks))
+	var receivedBytes int64
+	for _, chunk := range chunks {
+		received[chunk.Index] = true
+		receivedBytes += chunk.Size
+	}
+
+	missing := []int{}
+	for i := 0; i < chunkCount(session); i++ {
+		if !received[i] {
+			missing = append(missing, i)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload":          session,
+		"total_chunks":    chunkCount(session),
+		"received_bytes":  receivedBytes,
+		"received_ranges": receivedRanges(chunks),
+		"missing_chunks":  missing,
+	})
+}
+
+// UploadChunk writes one numbered chunk of a resumable upload. The chunk
+// body is the raw bytes; its offset is derived from the index and may be
+// confirmed with an "offset" query parameter or a Content-Range header.
+func UploadChunk(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolChunked)
+	if !ok {
+		return
+	}
+
+	defer lockUploadSession(session.ID)()
+	if !reloadUploadSession(c, session) {
+		return
+	}
+
+	if session.Status != models.UploadStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is no longer accepting chunks"})
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= chunkCount(session) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk index"})
+		return
+	}
+
+	offset := int64(index) * session.ChunkSize
+	size := session.ChunkSize
+	if offset+size > session.TotalSize {
+		size = session.TotalSize - offset
+	}
+
+	if !chunkOffsetMatches(c, offset, size, session.TotalSize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chunk offset does not match chunk index"})
+		return
+	}
+
+	written, err := writeUploadChunk(session.TempPath, offset, size, c.Request.Body)
+	if err != nil {
+		log.Printf("Failed to write chunk %d of upload %s: %v", index, session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chunk"})
+		return
+	}
+	if written != size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chunk must be exactly %d bytes", size)})
+		return
+	}
+
+	if index == 0 && rejectNonVideoUpload(c, session, written) {
+		return
+	}
+
+	chunk := models.UploadChunk{SessionID: session.ID, Index: index, Offset: offset, Size: written}
+	if err := saveUploadChunk(session, &chunk); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record chunk"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Chunk uploaded successfully",
+		"chunk":   chunk,
+	})
+}
+
+// CompleteUploadSession assembles a fully received upload into a video
+func CompleteUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolChunked)
+	if !ok {
+		return
+	}
+
+	// Chunk writes finish before assembly starts, and a second request
+	// waits for the first to finish
+	defer lockUploadSession(session.ID)()
+	if !reloadUploadSession(c, session) {
+		return
+	}
+
+	if session.Status == models.UploadStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is already complete"})
+		return
+	}
+
+	receivedBytes := uploadedBytes(session)
+	if receivedBytes != session.TotalSize {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "Upload is incomplete",
+			"received_bytes": receivedBytes,
+			"total_size":     session.TotalSize,
+		})
+		return
+	}
+
+	video, room, duplicateOf, err := finalizeUploadSession(session)
+	if err == errUploadNotPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is already being finalized"})
+		return
+	}
+	if errors.Is(err, media.ErrNotVideo) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported video format"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to finalize upload %s: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save video"})
+		return
+	}
+
+	c.JSON(http.StatusOK, videoUploadResponse(video, room, duplicateOf))
+}
+
+// CancelUploadSession aborts an upload and discards its partial file
+func CancelUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c, models.UploadProtocolChunked)
+	if !ok {
+		return
+	}
+
+	defer lockUploadSession(session.ID)()
+	if !reloadUploadSession(c, session) {
+		return
+	}
+
+	if session.Status == models.UploadStatusAssembling {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is being finalized"})
+		return
+	}
+
+	if err := removeUploadSession(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Upload cancelled successfully",
+	})
+}
+
+// StartUploadSessionCleanup resets uploads interrupted while being
+// finalized and periodically removes expired upload sessions together with
+// their partial files
+func StartUploadSessionCleanup(interval time.Duration) {
+	resetInterruptedUploadSessions()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupExpiredUploadSessions()
+			<-ticker.C
+		}
+	}()
+}
+
+// resetInterruptedUploadSessions returns sessions left assembling by a
+// crash or restart to pending, so their clients can complete them again
+func resetInterruptedUploadSessions() {
+	result := config.DB.Model(&models.UploadSession{}).
+		Where("status = ?", models.UploadStatusAssembling).
+		Update("status", models.UploadStatusPending)
+	if result.Error != nil {
+		log.Printf("Failed to reset interrupted upload sessions: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Reset %d upload sessions interrupted while being finalized", result.RowsAffected)
+	}
+}
+
+func cleanupExpiredUploadSessions() {
+	var sessions []models.UploadSession
+	if err := config.DB.Where("expires_at < ?", time.Now()).Find(&sessions).Error; err != nil {
+		log.Printf("Failed to query expired upload sessions: %v", err)
+		return
+	}
+
+	removed := 0
+	for i := range sessions {
+		ok, err := removeExpiredUploadSession(&sessions[i])
+		if err != nil {
+			log.Printf("Failed to remove expired upload session %s: %v", sessions[i].ID, err)
+		}
+		if ok {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d expired upload sessions", removed)
+	}
+}
+
+// removeExpiredUploadSession removes a session unless a write that held its
+// lock has extended it in the meantime. Finalizing also holds the lock, so
+// a session is never removed while it is being assembled. Removing it also
+// drops the lock.
+func removeExpiredUploadSession(session *models.UploadSession) (bool, error) {
+	defer lockUploadSession(session.ID)()
+
+	err := config.DB.Where("id = ? AND expires_at < ?", session.ID, time.Now()).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, removeUploadSession(session)
+}
+
+var errUploadNotPending = errors.New("upload session is not pending")
+
+// uploadLocks holds a mutex per upload session. Chunk writes, finalizing,
+// cancelling and cleanup all hold it, so none of them can change a session
+// while another is using it.
+var uploadLocks sync.Map
+
+// lockUploadSession holds the session's mutex until the returned func is called
+func lockUploadSession(id string) func() {
+	value, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	lock := value.(*sync.Mutex)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// newUploadSession creates the session row and its empty partial file
+func newUploadSession(protocol string, userID, roomID uint, filename, contentType string, size, chunkSize int64) (*models.UploadSession, error) {
+	if err := os.MkdirAll(config.AppConfig.Upload.TempDir, 0755); err != nil {
+		return nil, fmt.Errorf("create temp directory: %w", err)
+	}
+
+	id := uuid.NewString()
+	session := models.UploadSession{
+		ID:               id,
+		Protocol:         protocol,
+		UserID:           userID,
+		RoomID:           roomID,
+		OriginalFilename: filepath.Base(filename),
+		ContentType:      contentType,
+		TotalSize:        size,
+		ChunkSize:        chunkSize,
+		TempPath:         filepath.Join(config.AppConfig.Upload.TempDir, id+".part"),
+		Status:           models.UploadStatusPending,
+		ExpiresAt:        time.Now().Add(config.AppConfig.Upload.SessionTTL),
+	}
+
+	file, err := os.Create(session.TempPath)
+	if err != nil {
+		return nil, fmt.Errorf("create partial file: %w", err)
+	}
+	file.Close()
+
+	if err := config.DB.Create(&session).Error; err != nil {
+		os.Remove(session.TempPath)
+		return nil, fmt.Errorf("save upload session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// loadUploadSession fetches the session named in the URL, making sure it
+// belongs to the authenticated user. It writes the error response itself.
+func loadUploadSession(c *gin.Context, protocol string) (*models.UploadSession, bool) {
+	userID := middleware.CurrentPrincipal(c).UserID
+
+	var session models.UploadSession
+	err := config.DB.Where("id = ? AND user_id = ? AND protocol = ?", c.Param("upload_id"), userID, protocol).
+		First(&session).Error
+	if err != nil || session.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+		return nil, false
+	}
+
+	return &session, true
+}
+
+// reloadUploadSession re-reads a session once its lock is held, since a
+// request that held the lock before may have changed or removed it. It
+// writes the error response itself.
+func reloadUploadSession(c *gin.Context, session *models.UploadSession) bool {
+	if err := config.DB.Where("id = ?", session.ID).First(session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+		return false
+	}
+	return true
+}
+
+// saveUploadChunk records a written chunk and extends the session expiry
+func saveUploadChunk(session *models.UploadSession, chunk *models.UploadChunk) error {
+	var existing models.UploadChunk
+	err := config.DB.Where("session_id = ? AND chunk_index = ?", chunk.SessionID, chunk.Index).First(&existing).Error
+	if err == nil {
+		chunk.ID = existing.ID
+		chunk.CreatedAt = existing.CreatedAt
+	}
+
+	if err := config.DB.Save(chunk).Error; err != nil {
+		return err
+	}
+
+	return config.DB.Model(session).Update("expires_at", time.Now().Add(config.AppConfig.Upload.SessionTTL)).Error
+}
+
+// uploadedBytes sums the sizes of all chunks recorded for a session
+func uploadedBytes(session *models.UploadSession) int64 {
+	var total int64
+	config.DB.Model(&models.UploadChunk{}).Where("session_id = ?", session.ID).
+		Select("COALESCE(SUM(size), 0)").Scan(&total)
+	return total
+}
+
+// finalizeUploadSession turns a complete partial file into a video. The
+// session is claimed first so concurrent finalize calls cannot both succeed.
+func finalizeUploadSession(session *models.UploadSession) (*models.Video, models.Room, *models.Video, error) {
+	var room models.Room
+
+	claim := config.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND status = ?", session.ID, models.UploadStatusPending).
+		Update("status", models.UploadStatusAssembling)
+	if claim.Error != nil {
+		return nil, room, nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil, room, nil, errUploadNotPending
+	}
+
+	video, duplicateOf, err := assembleUploadSession(session, &room)
+	if errors.Is(err, media.ErrNotVideo) {
+		// Retrying cannot change the content, so the upload is discarded
+		removeUploadSession(session)
+		return nil, room, nil, err
+	}
+	if err != nil {
+		config.DB.Model(session).Update("status", models.UploadStatusPending)
+		return nil, room, nil, err
+	}
+
+	// The chunk rows stay until the session expires so a client that missed
+	// the final response still sees every byte as received
+	config.DB.Model(session).Updates(map[string]interface{}{
+		"status":   models.UploadStatusCompleted,
+		"video_id": video.ID,
+	})
+	os.Remove(session.TempPath)
+	uploadLocks.Delete(session.ID)
+
+	return video, room, duplicateOf, nil
+}
+
+func assembleUploadSession(session *models.UploadSession, room *models.Room) (*models.Video, *models.Video, error) {
+	if err := config.DB.First(room, session.RoomID).Error; err != nil {
+		return nil, nil, fmt.Errorf("load room: %w", err)
+	}
+
+	file, err := os.Open(session.TempPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open partial file: %w", err)
+	}
+	defer file.Close()
+
+	return createVideo(file, *room, session.OriginalFilename, session.UserID)
+}
+
+// rejectNonVideoUpload sniffs the leading bytes of a partial upload once
+// enough of them have arrived, so non-video files are refused before the
+// rest is transferred. The session is discarded and a 415 written when the
+// content is not a video.
+func rejectNonVideoUpload(c *gin.Context, session *models.UploadSession, leading int64) bool {
+	if leading < media.SniffLength && leading < session.TotalSize {
+		return false
+	}
+
+	file, err := os.Open(session.TempPath)
+	if err != nil {
+		log.Printf("Failed to open partial file %s: %v", session.TempPath, err)
+		return false
+	}
+	_, err = media.Sniff(file, leading)
+	file.Close()
+
+	if !errors.Is(err, media.ErrNotVideo) {
+		return false
+	}
+
+	removeUploadSession(session)
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported video format"})
+	return true
+}
+
+// removeUploadSession deletes a session, its chunk records and partial file
+func removeUploadSession(session *models.UploadSession) error {
+	if err := os.Remove(session.TempPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to delete partial file %s: %v", session.TempPath, err)
+	}
+
+	config.DB.Where("session_id = ?", session.ID).Delete(&models.UploadChunk{})
+	uploadLocks.Delete(session.ID)
+	return config.DB.Delete(session).Error
+}
+
+// writeUploadChunk copies at most size bytes from body into path at offset
+// and returns how many bytes the body actually held
+func writeUploadChunk(path string, offset, size int64, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(file, io.LimitReader(body, size))
+	if err != nil {
+		return written, err
+	}
+
+	// Anything left in the body means the chunk was too large
+	if n, _ := io.CopyN(io.Discard, body, 1); n > 0 {
+		return written + n, nil
+	}
+
+	return written, nil
+}
+
+// chunkOffsetMatches validates the optional offset hints sent with a chunk
+func chunkOffsetMatches(c *gin.Context, offset, size, total int64) bool {
+	if value := c.Query("offset"); value != "" {
+		queryOffset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || queryOffset != offset {
+			return false
+		}
+	}
+
+	if value := c.GetHeader("Content-Range"); value != "" {
+		expected := fmt.Sprintf("bytes %d-%d/%d", offset, offset+size-1, total)
+		if strings.TrimSpace(value) != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+func chunkCount(session *models.UploadSession) int {
+	return int((session.TotalSize + session.ChunkSize - 1) / session.ChunkSize)
+}
+
+// receivedRanges merges chunk records into inclusive [start, end] byte ranges
+func receivedRanges(chunks []models.UploadChunk) [][2]int64 {
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Offset < chunks[j].Offset })
+
+	ranges := [][2]int64{}
+	for _, chunk := range chunks {
+		end := chunk.Offset + chunk.Size - 1
+		if n := len(ranges); n > 0 && ranges[n-1][1]+1 >= chunk.Offset {
+			if end > ranges[n-1][1] {
+				ranges[n-1][1] = end
+			}
+			continue
+		}
+		ranges = append(ranges, [2]int64{chunk.Offset, end})
+	}
+
+	return ranges
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+func uploadChunk(user *models.User, session *models.UploadSession, index int, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/uploads/"+session.ID+"/chunks/"+strconv.Itoa(index), bytes.NewReader(body))
+	return serveRequest(user, "/uploads/:upload_id/chunks/:index", req, UploadChunk)
+}
+
+func completeUpload(user *models.User, session *models.UploadSession) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/uploads/"+session.ID+"/complete", nil)
+	return serveRequest(user, "/uploads/:upload_id/complete", req, CompleteUploadSession)
+}
+
+func uploadStatus(t *testing.T, session *models.UploadSession) string {
+	t.Helper()
+	var stored models.UploadSession
+	if err := config.DB.First(&stored, "id = ?", session.ID).Error; err != nil {
+		t.Fatalf("load upload session: %v", err)
+	}
+	return stored.Status
+}
+
+func TestUploadChunkWaitsForSessionLock(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestUploadSession(t, user, models.UploadProtocolChunked, 8192, 8192)
+
+	// Another request, such as a cancel, holds the session
+	unlock := lockUploadSession(session.ID)
+	done := make(chan int)
+	go func() {
+		done <- uploadChunk(user, session, 0, testMP4(8192)).Code
+	}()
+
+	select {
+	case code := <-done:
+		unlock()
+		t.Fatalf("chunk was written while the session was locked (status %d)", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	removeUploadSession(session)
+	unlock()
+
+	if code := <-done; code != http.StatusNotFound {
+		t.Errorf("chunk for a session removed while waiting: status %d, want %d", code, http.StatusNotFound)
+	}
+	var chunks int64
+	config.DB.Model(&models.UploadChunk{}).Where("session_id = ?", session.ID).Count(&chunks)
+	if chunks != 0 {
+		t.Errorf("%d chunks recorded for a removed session", chunks)
+	}
+}
+
+func TestCompleteUploadSessionOnce(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestUploadSession(t, user, models.UploadProtocolChunked, 8192, 4096)
+	content := testMP4(8192)
+
+	assertStatus(t, completeUpload(user, session), http.StatusConflict)
+	assertStatus(t, uploadChunk(user, session, 0, content[:4096]), http.StatusOK)
+	assertStatus(t, uploadChunk(user, session, 1, content[4096:]), http.StatusOK)
+
+	assertStatus(t, completeUpload(user, session), http.StatusOK)
+	assertStatus(t, completeUpload(user, session), http.StatusConflict)
+	assertStatus(t, uploadChunk(user, session, 1, content[4096:]), http.StatusConflict)
+
+	var videos int64
+	config.DB.Model(&models.Video{}).Count(&videos)
+	if videos != 1 {
+		t.Errorf("%d videos created, want 1", videos)
+	}
+}
+
+func TestResetInterruptedUploadSessions(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestUploadSession(t, user, models.UploadProtocolChunked, 8192, 8192)
+	assertStatus(t, uploadChunk(user, session, 0, testMP4(8192)), http.StatusOK)
+
+	// The server stopped while the upload was being finalized
+	config.DB.Model(session).Update("status", models.UploadStatusAssembling)
+	assertStatus(t, completeUpload(user, session), http.StatusConflict)
+
+	resetInterruptedUploadSessions()
+
+	if status := uploadStatus(t, session); status != models.UploadStatusPending {
+		t.Fatalf("status after reset = %q, want %q", status, models.UploadStatusPending)
+	}
+	assertStatus(t, completeUpload(user, session), http.StatusOK)
+}
+
+func TestCleanupRemovesExpiredAssemblingSession(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	session := newTestUploadSession(t, user, models.UploadProtocolChunked, 8192, 8192)
+	config.DB.Model(session).Updates(map[string]interface{}{
+		"status":     models.UploadStatusAssembling,
+		"expires_at": time.Now().Add(-time.Minute),
+	})
+
+	cleanupExpiredUploadSessions()
+
+	var count int64
+	config.DB.Model(&models.UploadSession{}).Where("id = ?", session.ID).Count(&count)
+	if count != 0 {
+		t.Error("cleanup kept an expired session stuck assembling")
+	}
+}
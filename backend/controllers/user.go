@@ -1,29 +1,41 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
 	"trialuploadhk/backend/models"
 	"trialuploadhk/backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=user manager supervisor"`
+	Username string   `json:"username" binding:"required"`
+	Email    string   `json:"email" binding:"required,email"`
+	Password string   `json:"password" binding:"required"`
+	Role     string   `json:"role" binding:"required"`
+	RoomIDs  []uint   `json:"room_ids"`                     // rooms whose videos the user can see
+	Floors   []string `json:"floors" binding:"dive,max=20"` // floors whose videos a manager can see
 }
 
 type UpdateUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Role     string `json:"role" binding:"required,oneof=user manager supervisor"`
-	IsActive bool   `json:"is_active"`
+	Username string    `json:"username" binding:"required"`
+	Email    string    `json:"email" binding:"required,email"`
+	Role     string    `json:"role" binding:"required"`
+	IsActive bool      `json:"is_active"`
+	RoomIDs  *[]uint   `json:"room_ids"` // unchanged when omitted
+	Floors   *[]string `json:"floors" binding:"omitempty,dive,max=20"`
 }
 
+var (
+	errUnknownRoom    = errors.New("unknown room")
+	errLastSupervisor = errors.New("last active supervisor")
+)
+
 // GetUsers returns list of users
 func GetUsers(c *gin.Context) {
 	var users []models.User
@@ -31,6 +43,10 @@ func GetUsers(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
+	if err := loadAssignments(users); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
 
 	// Remove password hashes from response
 	for i := range users {
@@ -49,6 +65,21 @@ func CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	// Users can only be created below the creator's own rank
+	if !outranks(middleware.CurrentPrincipal(c), req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role at or above your own"})
+		return
+	}
+
+	if err := passwordPolicy().Validate(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Check if username already exists
 	var existingUser models.User
@@ -76,12 +107,32 @@ func CreateUser(c *gin.Context) {
 		PasswordHash: passwordHash,
 		Role:         req.Role,
 		IsActive:     true,
+
+		// The password is known to whoever created the account
+		MustChangePassword: true,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := recordPasswordHistory(tx, user.ID, passwordHash); err != nil {
+			return err
+		}
+		if err := replaceUserRooms(tx, &user, req.RoomIDs); err != nil {
+			return err
+		}
+		return replaceUserFloors(tx, &user, req.Floors)
+	})
+	if errors.Is(err, errUnknownRoom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown room in room_ids"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	loadUserAssignments(&user)
 
 	// Remove password hash from response
 	user.PasswordHash = ""
@@ -101,6 +152,10 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
 
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
@@ -108,6 +163,39 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Users may edit their own username and email but not their role,
+	// status or visibility assignments; anyone else must rank below the
+	// editor both before and after the change
+	principal := middleware.CurrentPrincipal(c)
+	if user.ID == principal.UserID {
+		if err := loadUserAssignments(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+		if req.Role != user.Role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change your own role"})
+			return
+		}
+		if req.IsActive != user.IsActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change your own status"})
+			return
+		}
+		if assignmentsChanged(&user, req.RoomIDs, req.Floors) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change your own room or floor assignments"})
+			return
+		}
+	} else {
+		if !outranks(principal, user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage users at or above your own role"})
+			return
+		}
+		if !outranks(principal, req.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role at or above your own"})
+			return
+		}
+	}
+	removesSupervisor := isActiveSupervisor(&user) && (req.Role != models.RoleSupervisor || !req.IsActive)
+
 	// Check if new username conflicts with existing user
 	var existingUser models.User
 	if err := config.DB.Where("username = ? AND id != ?", req.Username, userID).First(&existingUser).Error; err == nil {
@@ -126,7 +214,46 @@ func UpdateUser(c *gin.Context) {
 	user.Role = req.Role
 	user.IsActive = req.IsActive
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if removesSupervisor {
+			if err := ensureOtherSupervisor(tx, &user); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// Deactivated users are signed out everywhere
+		if !user.IsActive {
+			if err := revokeUserSessions(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if req.RoomIDs != nil {
+			if err := replaceUserRooms(tx, &user, *req.RoomIDs); err != nil {
+				return err
+			}
+		}
+		if req.Floors != nil {
+			if err := replaceUserFloors(tx, &user, *req.Floors); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errUnknownRoom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown room in room_ids"})
+		return
+	}
+	if errors.Is(err, errLastSupervisor) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot deactivate or demote the last active supervisor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if err := loadUserAssignments(&user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
@@ -150,6 +277,16 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	principal := middleware.CurrentPrincipal(c)
+	if user.ID == principal.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete your own account"})
+		return
+	}
+	if !outranks(principal, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage users at or above your own role"})
+		return
+	}
+
 	// Check if user has videos
 	var videoCount int64
 	config.DB.Model(&models.Video{}).Where("uploaded_by = ?", userID).Count(&videoCount)
@@ -158,12 +295,197 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&user).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if isActiveSupervisor(&user) {
+			if err := ensureOtherSupervisor(tx, &user); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordReset{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.TOTPSecret{}, &models.RecoveryCode{}, &models.TwoFactorChallenge{}} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error
+	})
+	if errors.Is(err, errLastSupervisor) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the last active supervisor"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	config.DB.Where("user_id = ?", user.ID).Delete(&models.UserRoom{})
+	config.DB.Where("user_id = ?", user.ID).Delete(&models.UserFloor{})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User deleted successfully",
 	})
 }
+
+// assignmentsChanged reports whether the requested rooms or floors differ
+// from those assigned to user; omitted lists are left unchanged
+func assignmentsChanged(user *models.User, roomIDs *[]uint, floors *[]string) bool {
+	if roomIDs != nil {
+		requested := make(map[uint]bool)
+		for _, id := range *roomIDs {
+			requested[id] = true
+		}
+		if len(requested) != len(user.RoomIDs) {
+			return true
+		}
+		for _, id := range user.RoomIDs {
+			if !requested[id] {
+				return true
+			}
+		}
+	}
+	if floors != nil {
+		requested := make(map[string]bool)
+		for _, floor := range *floors {
+			if floor != "" {
+				requested[floor] = true
+			}
+		}
+		if len(requested) != len(user.Floors) {
+			return true
+		}
+		for _, floor := range user.Floors {
+			if !requested[floor] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// outranks reports whether the principal's role is strictly above role
+func outranks(principal *middleware.Principal, role string) bool {
+	return models.RoleRank(principal.Role) > models.RoleRank(role)
+}
+
+func isActiveSupervisor(user *models.User) bool {
+	return user.IsActive && user.Role == models.RoleSupervisor
+}
+
+// ensureOtherSupervisor fails with errLastSupervisor unless an active
+// supervisor other than user exists
+func ensureOtherSupervisor(tx *gorm.DB, user *models.User) error {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id != ?", models.RoleSupervisor, true, user.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errLastSupervisor
+	}
+	return nil
+}
+
+// replaceUserRooms sets the rooms assigned to a user
+func replaceUserRooms(tx *gorm.DB, user *models.User, roomIDs []uint) error {
+	unique := make(map[uint]bool)
+	rows := []models.UserRoom{}
+	for _, id := range roomIDs {
+		if !unique[id] {
+			unique[id] = true
+			rows = append(rows, models.UserRoom{UserID: user.ID, RoomID: id})
+		}
+	}
+
+	var found int64
+	if err := tx.Model(&models.Room{}).Where("id IN ?", roomIDs).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(rows) {
+		return errUnknownRoom
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRoom{}).Error; err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceUserFloors sets the floors assigned to a user
+func replaceUserFloors(tx *gorm.DB, user *models.User, floors []string) error {
+	unique := make(map[string]bool)
+	rows := []models.UserFloor{}
+	for _, floor := range floors {
+		if floor != "" && !unique[floor] {
+			unique[floor] = true
+			rows = append(rows, models.UserFloor{UserID: user.ID, Floor: floor})
+		}
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserFloor{}).Error; err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadUserAssignments fills in the assigned rooms and floors of one user
+func loadUserAssignments(user *models.User) error {
+	users := []models.User{*user}
+	if err := loadAssignments(users); err != nil {
+		return err
+	}
+	user.RoomIDs, user.Floors = users[0].RoomIDs, users[0].Floors
+	return nil
+}
+
+// loadAssignments fills in the assigned rooms and floors of users
+func loadAssignments(users []models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	index := make(map[uint]*models.User, len(users))
+	ids := make([]uint, 0, len(users))
+	for i := range users {
+		index[users[i].ID] = &users[i]
+		ids = append(ids, users[i].ID)
+	}
+
+	var rooms []models.UserRoom
+	if err := config.DB.Where("user_id IN ?", ids).Order("room_id").Find(&rooms).Error; err != nil {
+		return err
+	}
+	for _, row := range rooms {
+		index[row.UserID].RoomIDs = append(index[row.UserID].RoomIDs, row.RoomID)
+	}
+
+	var floors []models.UserFloor
+	if err := config.DB.Where("user_id IN ?", ids).Order("floor").Find(&floors).Error; err != nil {
+		return err
+	}
+	for _, row := range floors {
+		index[row.UserID].Floors = append(index[row.UserID].Floors, row.Floor)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+func TestUpdateUserSelfEdit(t *testing.T) {
+	setupTestDB(t)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	config.DB.Create(&models.UserFloor{UserID: manager.ID, Floor: "1"})
+	room := models.Room{RoomNumber: "101", Floor: "1"}
+	config.DB.Create(&room)
+
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"username":  "manager",
+			"email":     "manager@example.com",
+			"role":      models.RoleManager,
+			"is_active": true,
+			"floors":    []string{"1"},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(map[string]interface{})
+		want   int
+	}{
+		{"username and email", func(r map[string]interface{}) {
+			r["email"] = "new@example.com"
+		}, http.StatusOK},
+		{"role", func(r map[string]interface{}) { r["role"] = models.RoleSupervisor }, http.StatusForbidden},
+		{"deactivate", func(r map[string]interface{}) { r["is_active"] = false }, http.StatusForbidden},
+		{"add floor", func(r map[string]interface{}) { r["floors"] = []string{"1", "2"} }, http.StatusForbidden},
+		{"rooms", func(r map[string]interface{}) { r["room_ids"] = []uint{room.ID} }, http.StatusForbidden},
+		{"omitted assignments", func(r map[string]interface{}) { delete(r, "floors") }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := base()
+			tt.change(body)
+			w := performRequest(t, manager, http.MethodPut, "/users/:id",
+				fmt.Sprintf("/users/%d", manager.ID), body, UpdateUser)
+			assertStatus(t, w, tt.want)
+		})
+	}
+
+	var floors []string
+	config.DB.Model(&models.UserFloor{}).Where("user_id = ?", manager.ID).Pluck("floor", &floors)
+	if len(floors) != 1 || floors[0] != "1" {
+		t.Errorf("floors = %v, want [1]", floors)
+	}
+	var rooms int64
+	config.DB.Model(&models.UserRoom{}).Where("user_id = ?", manager.ID).Count(&rooms)
+	if rooms != 0 {
+		t.Errorf("%d rooms assigned, want none", rooms)
+	}
+}
+
+func TestUpdateUserOtherUserNeedsHigherRank(t *testing.T) {
+	setupTestDB(t)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	peer := createTestUser(t, "peer", models.RoleManager)
+	user := createTestUser(t, "user", models.RoleUser)
+
+	body := func(target *models.User, role string) map[string]interface{} {
+		return map[string]interface{}{
+			"username":  target.Username,
+			"email":     target.Email,
+			"role":      role,
+			"is_active": false,
+		}
+	}
+
+	w := performRequest(t, manager, http.MethodPut, "/users/:id", fmt.Sprintf("/users/%d", peer.ID),
+		body(peer, models.RoleManager), UpdateUser)
+	assertStatus(t, w, http.StatusForbidden)
+
+	w = performRequest(t, manager, http.MethodPut, "/users/:id", fmt.Sprintf("/users/%d", user.ID),
+		body(user, models.RoleManager), UpdateUser)
+	assertStatus(t, w, http.StatusForbidden)
+
+	w = performRequest(t, manager, http.MethodPut, "/users/:id", fmt.Sprintf("/users/%d", user.ID),
+		body(user, models.RoleUser), UpdateUser)
+	assertStatus(t, w, http.StatusOK)
+}
@@ -1,23 +1,29 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/media"
+	"trialuploadhk/backend/middleware"
 	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // UploadVideo handles video upload
 func UploadVideo(c *gin.Context) {
-	userID := c.GetUint("user_id")
+	userID := middleware.CurrentPrincipal(c).UserID
 
 	// Parse multipart form
 	if err := c.Request.ParseMultipartForm(config.AppConfig.Upload.MaxFileSize); err != nil {
@@ -53,57 +59,124 @@ func UploadVideo(c *gin.Context) {
 		return
 	}
 
-	// Create upload directory structure
-	now := time.Now()
-	year := strconv.Itoa(now.Year())
-	month := fmt.Sprintf("%02d", now.Month())
-	uploadPath := filepath.Join(config.AppConfig.Upload.Dir, year, month, fmt.Sprintf("room_%s", room.RoomNumber))
-
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+	video, duplicateOf, err := createVideo(file, room, header.Filename, userID)
+	if errors.Is(err, media.ErrNotVideo) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported video format"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save uploaded video: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save video"})
 		return
 	}
 
-	// Generate unique filename
-	timestamp := now.Format("20060102_150405")
-	filename := fmt.Sprintf("video_%s_%s%s", timestamp, room.RoomNumber, filepath.Ext(header.Filename))
-	filePath := filepath.Join(uploadPath, filename)
+	c.JSON(http.StatusOK, videoUploadResponse(video, room, duplicateOf))
+}
 
-	// Create file
-	dst, err := os.Create(filePath)
+// createVideo hashes src, stores it as a content-addressed blob (reusing
+// an existing blob for identical content) and records the resulting video.
+// When the same content was already uploaded for this room the earlier
+// video is returned as duplicateOf. Content that is not a video is rejected
+// with media.ErrNotVideo before anything is stored.
+func createVideo(src io.Reader, room models.Room, originalFilename string, userID uint) (video *models.Video, duplicateOf *models.Video, err error) {
+	spool, err := spoolContent(src)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
-		return
+		return nil, nil, err
 	}
-	defer dst.Close()
+	defer spool.Close()
 
-	// Copy file content
-	fileSize, err := io.Copy(dst, file)
+	// The type is taken from the content; browsers often label recordings
+	// with a type or extension that does not match the container
+	contentType, err := media.Sniff(spool.File, spool.Size)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
+		return nil, nil, err
+	}
+
+	metadata, err := probeMetadata(spool, room.RoomNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blob, err := acquireBlob(spool)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var existing models.Video
+	if err := config.DB.Where("blob_id = ? AND room_id = ?", blob.ID, room.ID).Order("id").First(&existing).Error; err == nil {
+		duplicateOf = &existing
 	}
 
+	// Generate unique filename
+	now := time.Now()
+	timestamp := now.Format("20060102_150405")
+	filename := fmt.Sprintf("video_%s_%s%s", timestamp, room.RoomNumber, media.Extension(contentType))
+
 	// Save video record to database
-	video := models.Video{
+	video = &models.Video{
 		Filename:         filename,
-		OriginalFilename: header.Filename,
-		FilePath:         filePath,
-		FileSize:         fileSize,
+		OriginalFilename: originalFilename,
+		FilePath:         blob.StorageKey,
+		FileSize:         blob.Size,
+		ContentType:      contentType,
+		ContentHash:      blob.Hash,
+		BlobID:           &blob.ID,
 		RoomID:           &room.ID,
 		UploadedBy:       userID,
 		UploadDate:       now,
-		Metadata:         fmt.Sprintf(`{"content_type": "%s", "room_number": "%s"}`, header.Header.Get("Content-Type"), room.RoomNumber),
+		Metadata:         metadata,
+	}
+	if metadata.Duration > 0 {
+		seconds := int(math.Round(metadata.Duration))
+		video.Duration = &seconds
 	}
 
-	if err := config.DB.Create(&video).Error; err != nil {
-		// Clean up file if database save fails
-		os.Remove(filePath)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save video record"})
-		return
+	if err := config.DB.Create(video).Error; err != nil {
+		// Give back the reference taken above
+		if released, removed, releaseErr := releaseBlob(config.DB, blob.ID); releaseErr == nil && removed {
+			deleteBlobContent(released)
+		}
+		return nil, nil, fmt.Errorf("save video record: %w", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	// Thumbnails and renditions are produced without holding up the upload
+	enqueueProcessing(video)
+
+	return video, duplicateOf, nil
+}
+
+// probeMetadata reads the container headers of spooled upload content. A
+// file that cannot be probed is still accepted; the failure is recorded in
+// the metadata instead. Files that probe without a video track are rejected.
+func probeMetadata(spool *spooledFile, roomNumber string) (models.VideoMetadata, error) {
+	metadata := models.VideoMetadata{RoomNumber: roomNumber}
+
+	info, err := media.Probe(spool.File, spool.Size)
+	if err != nil {
+		log.Printf("Failed to probe upload %s: %v", spool.Hash, err)
+		metadata.ProbeError = err.Error()
+		return metadata, nil
+	}
+	if info.VideoCodec == "" {
+		return metadata, fmt.Errorf("%w: %s file has no video track", media.ErrNotVideo, info.Container)
+	}
+
+	metadata.Container = info.Container
+	metadata.Duration = info.Duration
+	metadata.Width = info.Width
+	metadata.Height = info.Height
+	metadata.VideoCodec = info.VideoCodec
+	metadata.AudioCodec = info.AudioCodec
+	metadata.FrameRate = info.FrameRate
+	metadata.Rotation = info.Rotation
+	metadata.CreationTime = info.CreationTime
+	return metadata, nil
+}
+
+// videoUploadResponse builds the response body returned once an upload has
+// been turned into a video
+func videoUploadResponse(video *models.Video, room models.Room, duplicateOf *models.Video) gin.H {
+	response := gin.H{
 		"message": "Video uploaded successfully",
 		"video": gin.H{
 			"id":       video.ID,
@@ -111,95 +184,144 @@ func UploadVideo(c *gin.Context) {
 			"size":     video.FileSize,
 			"room":     room.RoomNumber,
 		},
-	})
+		"duplicate": duplicateOf != nil,
+	}
+
+	if duplicateOf != nil {
+		response["message"] = "Video uploaded successfully (duplicate of an existing video for this room)"
+		response["duplicate_of"] = duplicateOf.ID
+	}
+
+	return response
 }
 
-// GetVideos returns all videos for the authenticated user
+// GetVideos returns a page of videos matching the query filters, with
+// the total number of matches. Pages are chained through next_cursor.
 func GetVideos(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	userRole := c.GetString("user_role")
+	principal := middleware.CurrentPrincipal(c)
+
+	filters, err := parseVideoFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Debug logging - VERY PROMINENT
-	fmt.Println("=== DEBUG: GetVideos ===")
-	fmt.Printf("User ID: %d, Role: %s\n", userID, userRole)
-	fmt.Println("========================")
+	sortName := c.DefaultQuery("sort", "upload_date")
+	sort, ok := videoSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort"})
+		return
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order"})
+		return
+	}
+	desc := order == "desc"
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if parsed > 200 {
+			parsed = 200
+		}
+		limit = parsed
+	}
 
-	var videos []models.Video
-	query := config.DB
+	var total int64
+	if err := filters.apply(config.DB.Model(&models.Video{}).Scopes(visibleVideos(c))).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
+		return
+	}
 
-	// Show all videos for all users - no filtering
-	fmt.Printf("SHOWING ALL: All videos for all users\n")
+	query := filters.apply(config.DB.Preload("Room").Preload("User").Scopes(visibleVideos(c)))
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeVideoCursor(value)
+		if err == nil && (cursor.Sort != sortName || cursor.Desc != desc) {
+			err = errors.New("cursor issued for another sort order")
+		}
+		if err == nil {
+			query, err = sort.after(query, cursor)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
 
-	if err := query.Find(&videos).Error; err != nil {
+	// Fetch one extra row to learn whether another page follows
+	var videos []models.Video
+	if err := query.Order(sort.order(desc)).Limit(limit + 1).Find(&videos).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
 		return
 	}
 
-	fmt.Printf("FOUND: %d videos\n", len(videos))
+	nextCursor := ""
+	if len(videos) > limit {
+		videos = videos[:limit]
+		last := &videos[limit-1]
+		nextCursor = encodeVideoCursor(videoCursor{Sort: sortName, Desc: desc, Value: sort.value(last), ID: last.ID})
+	}
 
-	// Manually load room information for each video
+	expires := time.Now().Add(config.AppConfig.Stream.URLTTL)
 	for i := range videos {
-		if videos[i].RoomID != nil {
-			var room models.Room
-			if err := config.DB.Where("id = ?", *videos[i].RoomID).First(&room).Error; err == nil {
-				videos[i].Room = &room
-			}
-		}
-		// Manually load Uploader information
-		var uploader models.User
-		if err := config.DB.Where("id = ?", videos[i].UploadedBy).First(&uploader).Error; err == nil {
-			videos[i].User = uploader
-		}
+		signMediaURLs(&videos[i], principal, expires)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"videos": videos,
+		"videos":      videos,
+		"total":       total,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
 	})
 }
 
 // GetVideo returns specific video details
 func GetVideo(c *gin.Context) {
-	videoID := c.Param("id")
-
 	var video models.Video
-	query := config.DB.Where("id = ?", videoID)
-
-	// No filtering - allow access to all videos
-	if err := query.First(&video).Error; err != nil {
+	err := config.DB.Preload("Room").Preload("User").
+		Preload("Renditions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Scopes(visibleVideos(c)).Where("videos.id = ?", c.Param("id")).First(&video).Error
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
 		return
 	}
-
-	// Manually load room information
-	if video.RoomID != nil {
-		var room models.Room
-		if err := config.DB.Where("id = ?", *video.RoomID).First(&room).Error; err == nil {
-			video.Room = &room
-		}
-	}
-	// Manually load uploader information
-	var uploader models.User
-	if err := config.DB.Where("id = ?", video.UploadedBy).First(&uploader).Error; err == nil {
-		video.User = uploader
-	}
+	signMediaURLs(&video, middleware.CurrentPrincipal(c), time.Now().Add(config.AppConfig.Stream.URLTTL))
 
 	c.JSON(http.StatusOK, gin.H{
 		"video": video,
 	})
 }
 
+// signMediaURLs fills in the stream, thumbnail and HLS URLs of a video,
+// signed for the principal's login session until expires
+func signMediaURLs(video *models.Video, principal *middleware.Principal, expires time.Time) {
+	base := fmt.Sprintf("/api/videos/%d", video.ID)
+	query := middleware.SignMediaQuery(video.ID, principal.UserID, principal.SessionID, expires)
+
+	video.StreamURL = base + "/stream?" + query
+	if video.ThumbnailPath != "" {
+		video.ThumbnailURL = base + "/thumbnail?" + query
+	}
+	if video.HLSPath != "" {
+		video.HLSURL = base + "/hls/" + media.HLSPlaylist + "?" + query
+	}
+}
+
 // DeleteVideo deletes a video
 func DeleteVideo(c *gin.Context) {
-	videoID := c.Param("id")
-	userID := c.GetUint("user_id")
-	userRole := c.GetString("user_role")
+	principal := middleware.CurrentPrincipal(c)
 
 	var video models.Video
-	query := config.DB.Where("id = ?", videoID)
+	query := config.DB.Scopes(visibleVideos(c)).Where("videos.id = ?", c.Param("id"))
 
-	// If user is not supervisor or manager, only allow deletion of their own videos
-	if userRole != "supervisor" && userRole != "manager" {
-		query = query.Where("uploaded_by = ?", userID)
+	// Without videos.delete.any, only allow deletion of their own videos
+	if !principal.Can(models.PermVideosDeleteAny) {
+		query = query.Where("videos.uploaded_by = ?", principal.UserID)
 	}
 
 	if err := query.First(&video).Error; err != nil {
@@ -207,18 +329,40 @@ func DeleteVideo(c *gin.Context) {
 		return
 	}
 
-	// Delete file from filesystem
-	if err := os.Remove(video.FilePath); err != nil {
-		// Log error but continue with database deletion
-		fmt.Printf("Failed to delete file %s: %v\n", video.FilePath, err)
-	}
+	// Delete from database, releasing the shared blob
+	var released *models.Blob
+	var removed bool
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&video).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("video_id = ?", video.ID).Delete(&models.Rendition{}).Error; err != nil {
+			return err
+		}
+		if video.BlobID == nil {
+			return nil
+		}
 
-	// Delete from database
-	if err := config.DB.Delete(&video).Error; err != nil {
+		var err error
+		released, removed, err = releaseBlob(tx, *video.BlobID)
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete video"})
 		return
 	}
 
+	// Delete file from storage once no other video references it
+	if removed {
+		deleteBlobContent(released)
+	} else if video.BlobID == nil {
+		if err := storage.Service.Delete(video.FilePath); err != nil {
+			// Log error; the database record is already gone
+			log.Printf("Failed to delete file %s: %v", video.FilePath, err)
+		}
+		deleteDerivedContent(derivedPrefix(&video))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Video deleted successfully",
 	})
@@ -226,34 +370,99 @@ func DeleteVideo(c *gin.Context) {
 
 // StreamVideo streams video content
 func StreamVideo(c *gin.Context) {
-	videoID := c.Param("id")
-
 	var video models.Video
-	if err := config.DB.Where("id = ?", videoID).First(&video).Error; err != nil {
+	if err := findVisibleVideo(c, &video); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(video.FilePath); os.IsNotExist(err) {
+	// Pick the original upload or a transcoded rendition
+	key, contentType := video.FilePath, video.ContentType
+	etag := contentETag(video.ContentHash, "")
+	quality := c.DefaultQuery("quality", models.QualityOriginal)
+	if quality != models.QualityOriginal {
+		rendition, err := findRendition(video.ID, quality)
+		if errors.Is(err, errUnknownQuality) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quality"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rendition not available"})
+			return
+		}
+		if rendition != nil && rendition.StorageKey != video.FilePath {
+			key, contentType = rendition.StorageKey, rendition.ContentType
+			etag = contentETag(video.ContentHash, rendition.Quality)
+		}
+	}
+
+	// Open file from storage
+	file, info, err := storage.Service.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Video file not found"})
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to open video %d: %v", video.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open video file"})
+		return
+	}
+	defer file.Close()
+
+	if contentType == "" {
+		contentType = sniffStoredVideo(&video, file)
+	}
 
-	// Set appropriate headers for video streaming
-	c.Header("Content-Type", "video/mp4")
+	// Revalidate on every use; the ETag makes that a cheap 304
+	c.Header("Cache-Control", "private, no-cache")
+	serveVideoContent(c, file, info.ModTime, contentType, etag)
+}
+
+// serveVideoContent writes a stored video in response to GET or HEAD.
+// Single and multiple byte ranges (206, or 416 when unsatisfiable),
+// If-Range and the If-Match/If-None-Match/If-(Un)Modified-Since
+// preconditions are all evaluated against etag and the object's
+// modification time.
+func serveVideoContent(c *gin.Context, file storage.Object, modTime time.Time, contentType, etag string) {
+	c.Header("Content-Type", contentType)
 	c.Header("Accept-Ranges", "bytes")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
-	c.Header("Access-Control-Allow-Headers", "Range, Content-Range, Accept-Ranges")
-
-	// Handle preflight requests
-	if c.Request.Method == "OPTIONS" {
-		c.Status(http.StatusOK)
-		return
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+
+	http.ServeContent(c.Writer, c.Request, "", modTime, file)
+}
+
+// contentETag is the strong ETag of stored content: the SHA-256 of the
+// original upload, qualified by the rendition for transcoded copies.
+// Videos uploaded before hashing have none and fall back to
+// Last-Modified.
+func contentETag(hash, rendition string) string {
+	if hash == "" {
+		return ""
+	}
+	if rendition != "" {
+		return fmt.Sprintf("\"%s-%s\"", hash, rendition)
+	}
+	return fmt.Sprintf("\"%s\"", hash)
+}
+
+// sniffStoredVideo detects the type of a video uploaded before content
+// types were recorded and saves it on the record. The file is rewound for
+// streaming afterwards.
+func sniffStoredVideo(video *models.Video, file storage.Object) string {
+	header := make([]byte, media.SniffLength)
+	n, _ := io.ReadFull(file, header)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Failed to rewind video %d: %v", video.ID, err)
+	}
+
+	contentType, err := media.Sniff(bytes.NewReader(header[:n]), int64(n))
+	if err != nil {
+		// Keep the historical default for files that cannot be identified
+		return media.TypeMP4
 	}
 
-	// Stream video file
-	c.File(video.FilePath)
+	config.DB.Model(video).Update("content_type", contentType)
+	return contentType
 }
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+)
+
+func deleteVideo(t *testing.T, user *models.User, video *models.Video) int {
+	t.Helper()
+	target := fmt.Sprintf("/videos/%d", video.ID)
+	return performRequest(t, user, http.MethodDelete, "/videos/:id", target, nil, DeleteVideo).Code
+}
+
+// videoExists reports whether video is still stored and not deleted
+func videoExists(t *testing.T, video *models.Video) bool {
+	t.Helper()
+	var count int64
+	config.DB.Model(&models.Video{}).Where("id = ?", video.ID).Count(&count)
+
+	_, _, err := storage.Service.Open(video.FilePath)
+	if err == nil && count == 0 {
+		t.Errorf("video %d was deleted but its file is still stored", video.ID)
+	}
+	if errors.Is(err, storage.ErrNotFound) && count > 0 {
+		t.Errorf("video %d still exists but its file is gone", video.ID)
+	}
+	return count > 0
+}
+
+func TestDeleteVideoByRole(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+
+	room := models.Room{RoomNumber: "101", Floor: "1"}
+	otherRoom := models.Room{RoomNumber: "201", Floor: "2"}
+	config.DB.Create(&room)
+	config.DB.Create(&otherRoom)
+
+	uploader := createTestUser(t, "uploader", models.RoleUser)
+	colleague := createTestUser(t, "colleague", models.RoleUser)
+	manager := createTestUser(t, "manager", models.RoleManager)
+	supervisor := createTestUser(t, "supervisor", models.RoleSupervisor)
+	config.DB.Create(&models.UserRoom{UserID: uploader.ID, RoomID: room.ID})
+	config.DB.Create(&models.UserRoom{UserID: colleague.ID, RoomID: room.ID})
+	config.DB.Create(&models.UserFloor{UserID: manager.ID, Floor: "1"})
+
+	tests := []struct {
+		name string
+		user *models.User
+		room *models.Room
+		want int
+	}{
+		{"user deletes their own upload", uploader, &room, http.StatusOK},
+		{"user cannot delete a colleague's upload they can see", colleague, &room, http.StatusNotFound},
+		{"manager deletes an upload on their floor", manager, &room, http.StatusOK},
+		{"manager cannot delete an upload on another floor", manager, &otherRoom, http.StatusNotFound},
+		{"supervisor deletes any upload", supervisor, &otherRoom, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video, _ := newTestVideo(t, uploader, tt.room, 100)
+
+			if got := deleteVideo(t, tt.user, video); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+			if exists := videoExists(t, video); exists != (tt.want != http.StatusOK) {
+				t.Errorf("video exists = %v after status %d", exists, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// videoFilters narrow down the video list
+type videoFilters struct {
+	RoomIDs     []uint
+	UploadedBy  *uint
+	StartDate   *time.Time // inclusive
+	EndDate     *time.Time // exclusive
+	MinDuration *int       // in seconds
+	MaxDuration *int
+	MinSize     *int64 // in bytes
+	MaxSize     *int64
+}
+
+// parseVideoFilters reads the list filters from the query string:
+// room_id (repeatable or comma separated), uploaded_by, start_date and
+// end_date (YYYY-MM-DD, inclusive), min/max_duration and min/max_size
+func parseVideoFilters(c *gin.Context) (videoFilters, error) {
+	var filters videoFilters
+
+	for _, value := range c.QueryArray("room_id") {
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return filters, errors.New("Invalid room_id")
+			}
+			filters.RoomIDs = append(filters.RoomIDs, uint(id))
+		}
+	}
+
+	if value := c.Query("uploaded_by"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filters, errors.New("Invalid uploaded_by")
+		}
+		uploader := uint(id)
+		filters.UploadedBy = &uploader
+	}
+
+	var err error
+	filters.StartDate, filters.EndDate, err = parseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return filters, err
+	}
+
+	for name, target := range map[string]**int{"min_duration": &filters.MinDuration, "max_duration": &filters.MaxDuration} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return filters, errors.New("Invalid " + name)
+			}
+			*target = &parsed
+		}
+	}
+	for name, target := range map[string]**int64{"min_size": &filters.MinSize, "max_size": &filters.MaxSize} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				return filters, errors.New("Invalid " + name)
+			}
+			*target = &parsed
+		}
+	}
+
+	return filters, nil
+}
+
+// apply adds the filters to a video query
+func (f videoFilters) apply(query *gorm.DB) *gorm.DB {
+	if len(f.RoomIDs) > 0 {
+		query = query.Where("room_id IN ?", f.RoomIDs)
+	}
+	if f.UploadedBy != nil {
+		query = query.Where("uploaded_by = ?", *f.UploadedBy)
+	}
+	if f.StartDate != nil {
+		query = query.Where("upload_date >= ?", *f.StartDate)
+	}
+	if f.EndDate != nil {
+		query = query.Where("upload_date < ?", *f.EndDate)
+	}
+	if f.MinDuration != nil {
+		query = query.Where("duration >= ?", *f.MinDuration)
+	}
+	if f.MaxDuration != nil {
+		query = query.Where("duration <= ?", *f.MaxDuration)
+	}
+	if f.MinSize != nil {
+		query = query.Where("file_size >= ?", *f.MinSize)
+	}
+	if f.MaxSize != nil {
+		query = query.Where("file_size <= ?", *f.MaxSize)
+	}
+	return query
+}
+
+// parseDateRange parses inclusive YYYY-MM-DD bounds in local time. The end
+// is returned as the exclusive start of the following day.
+func parseDateRange(startDate, endDate string) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+
+	if startDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("Invalid start_date, expected YYYY-MM-DD")
+		}
+		start = &parsed
+	}
+	if endDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("Invalid end_date, expected YYYY-MM-DD")
+		}
+		parsed = parsed.AddDate(0, 0, 1)
+		end = &parsed
+	}
+	if start != nil && end != nil && !start.Before(*end) {
+		return nil, nil, errors.New("start_date must not be after end_date")
+	}
+
+	return start, end, nil
+}
+
+// videoSort is a sort order offered by the video list. Ties are broken by
+// id so every position has a unique cursor.
+type videoSort struct {
+	column string                     // SQL expression sorted on
+	value  func(*models.Video) string // cursor value of a row
+	parse  func(string) (interface{}, error)
+}
+
+var videoSorts = map[string]videoSort{
+	"upload_date": {
+		column: "upload_date",
+		value:  func(v *models.Video) string { return v.UploadDate.Format(time.RFC3339Nano) },
+		parse: func(s string) (interface{}, error) {
+			return time.Parse(time.RFC3339Nano, s)
+		},
+	},
+	"file_size": {
+		column: "file_size",
+		value:  func(v *models.Video) string { return strconv.FormatInt(v.FileSize, 10) },
+		parse: func(s string) (interface{}, error) {
+			return strconv.ParseInt(s, 10, 64)
+		},
+	},
+	"duration": {
+		column: "COALESCE(duration, 0)",
+		value: func(v *models.Video) string {
+			if v.Duration == nil {
+				return "0"
+			}
+			return strconv.Itoa(*v.Duration)
+		},
+		parse: func(s string) (interface{}, error) {
+			return strconv.Atoi(s)
+		},
+	},
+	"filename": {
+		column: "original_filename",
+		value:  func(v *models.Video) string { return v.OriginalFilename },
+		parse:  func(s string) (interface{}, error) { return s, nil },
+	},
+}
+
+// videoCursor marks the last row of a page. It records the sort it was
+// issued for so it cannot be replayed against a different order.
+type videoCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeVideoCursor(cursor videoCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeVideoCursor(s string) (videoCursor, error) {
+	var cursor videoCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// after restricts query to the rows that follow cursor in the sort order
+func (s videoSort) after(query *gorm.DB, cursor videoCursor) (*gorm.DB, error) {
+	value, err := s.parse(cursor.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	op := ">"
+	if cursor.Desc {
+		op = "<"
+	}
+	return query.Where(
+		"(("+s.column+" "+op+" ?) OR ("+s.column+" = ? AND id "+op+" ?))",
+		value, value, cursor.ID,
+	), nil
+}
+
+// order returns the ORDER BY clause for the sort
+func (s videoSort) order(desc bool) string {
+	if desc {
+		return s.column + " DESC, id DESC"
+	}
+	return s.column + " ASC, id ASC"
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+)
+
+// videoPage is the body of a GET /videos response
+type videoPage struct {
+	Videos     []models.Video `json:"videos"`
+	Total      int64          `json:"total"`
+	NextCursor string         `json:"next_cursor"`
+	HasMore    bool           `json:"has_more"`
+}
+
+func listVideos(t *testing.T, user *models.User, query url.Values) (*videoPage, int) {
+	t.Helper()
+	w := performRequest(t, user, "GET", "/videos", "/videos?"+query.Encode(), nil, GetVideos)
+	if w.Code != http.StatusOK {
+		return nil, w.Code
+	}
+
+	var page videoPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode page: %v", err)
+	}
+	return &page, w.Code
+}
+
+// listAllVideos follows next_cursor through every page and returns the IDs
+// in the order they were listed
+func listAllVideos(t *testing.T, user *models.User, query url.Values) []uint {
+	t.Helper()
+	var ids []uint
+	for pages := 0; pages < 20; pages++ {
+		page, code := listVideos(t, user, query)
+		if code != http.StatusOK {
+			t.Fatalf("list videos with %s: status %d", query.Encode(), code)
+		}
+		for _, video := range page.Videos {
+			ids = append(ids, video.ID)
+		}
+		if page.HasMore != (page.NextCursor != "") {
+			t.Fatalf("has_more = %v with next_cursor %q", page.HasMore, page.NextCursor)
+		}
+		if !page.HasMore {
+			return ids
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	t.Fatal("pagination did not end")
+	return nil
+}
+
+// addListedVideo stores video metadata only; the list never reads content
+func addListedVideo(t *testing.T, user *models.User, filename string, size int64, duration *int, uploaded time.Time) *models.Video {
+	t.Helper()
+	video := models.Video{
+		Filename:         filename,
+		OriginalFilename: filename,
+		FilePath:         "videos/" + filename,
+		FileSize:         size,
+		Duration:         duration,
+		UploadedBy:       user.ID,
+		UploadDate:       uploaded,
+	}
+	if err := config.DB.Create(&video).Error; err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	return &video
+}
+
+func seconds(n int) *int {
+	return &n
+}
+
+func TestGetVideosCursorPagination(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	videos := []*models.Video{
+		addListedVideo(t, user, "c.mp4", 300, seconds(60), base.Add(3*time.Hour+123*time.Millisecond)),
+		addListedVideo(t, user, "a.mp4", 100, nil, base.Add(time.Hour)),
+		addListedVideo(t, user, "e.mp4", 300, seconds(60), base.Add(5*time.Hour)),
+		addListedVideo(t, user, "b.mp4", 500, nil, base.Add(2*time.Hour+456*time.Microsecond)),
+		addListedVideo(t, user, "d.mp4", 200, seconds(0), base.Add(4*time.Hour)),
+		addListedVideo(t, user, "f.mp4", 400, seconds(30), base),
+	}
+
+	// Expected orders, ties broken by id; a missing duration sorts as zero
+	duration := func(v *models.Video) int {
+		if v.Duration == nil {
+			return 0
+		}
+		return *v.Duration
+	}
+	less := map[string]func(a, b *models.Video) bool{
+		"upload_date": func(a, b *models.Video) bool { return a.UploadDate.Before(b.UploadDate) },
+		"file_size":   func(a, b *models.Video) bool { return a.FileSize < b.FileSize },
+		"duration":    func(a, b *models.Video) bool { return duration(a) < duration(b) },
+		"filename":    func(a, b *models.Video) bool { return a.OriginalFilename < b.OriginalFilename },
+	}
+
+	for sortName, before := range less {
+		for _, order := range []string{"asc", "desc"} {
+			t.Run(sortName+" "+order, func(t *testing.T) {
+				expected := append([]*models.Video(nil), videos...)
+				sort.SliceStable(expected, func(i, j int) bool {
+					a, b := expected[i], expected[j]
+					if order == "desc" {
+						a, b = b, a
+					}
+					if before(a, b) {
+						return true
+					}
+					if before(b, a) {
+						return false
+					}
+					return a.ID < b.ID
+				})
+
+				got := listAllVideos(t, user, url.Values{"sort": {sortName}, "order": {order}, "limit": {"2"}})
+
+				if len(got) != len(expected) {
+					t.Fatalf("listed %v, want %d videos", got, len(expected))
+				}
+				for i, video := range expected {
+					if got[i] != video.ID {
+						t.Fatalf("listed %v, want video %d at position %d", got, video.ID, i)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGetVideosFilters(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	short := addListedVideo(t, user, "short.mp4", 100, seconds(10), day)
+	long := addListedVideo(t, user, "long.mp4", 5000, seconds(600), day.AddDate(0, 0, 1))
+	unprobed := addListedVideo(t, user, "unprobed.mp4", 300, nil, day.AddDate(0, 0, 3))
+
+	tests := []struct {
+		query url.Values
+		want  []uint
+	}{
+		{url.Values{"min_duration": {"60"}}, []uint{long.ID}},
+		{url.Values{"max_duration": {"60"}}, []uint{short.ID}},
+		{url.Values{"min_size": {"200"}, "max_size": {"1000"}}, []uint{unprobed.ID}},
+		{url.Values{"start_date": {"2024-05-01"}, "end_date": {"2024-05-02"}}, []uint{short.ID, long.ID}},
+		{url.Values{"start_date": {"2024-05-02"}, "end_date": {"2024-05-02"}}, []uint{long.ID}},
+		{url.Values{"uploaded_by": {"999"}}, nil},
+	}
+
+	for _, tt := range tests {
+		tt.query.Set("order", "asc")
+		page, code := listVideos(t, user, tt.query)
+		if code != http.StatusOK {
+			t.Errorf("%s: status %d", tt.query.Encode(), code)
+			continue
+		}
+		var ids []uint
+		for _, video := range page.Videos {
+			ids = append(ids, video.ID)
+		}
+		if len(ids) != len(tt.want) || page.Total != int64(len(tt.want)) {
+			t.Errorf("%s: listed %v (total %d), want %v", tt.query.Encode(), ids, page.Total, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: listed %v, want %v", tt.query.Encode(), ids, tt.want)
+			}
+		}
+	}
+}
+
+func TestGetVideosRejectsInvalidQueries(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	for i := 0; i < 3; i++ {
+		addListedVideo(t, user, "clip.mp4", 100, nil, time.Now())
+	}
+
+	page, _ := listVideos(t, user, url.Values{"sort": {"file_size"}, "limit": {"1"}})
+	cursor := page.NextCursor
+
+	tests := []url.Values{
+		{"room_id": {"1,abc"}},
+		{"uploaded_by": {"someone"}},
+		{"start_date": {"2024-13-01"}},
+		{"end_date": {"01/05/2024"}},
+		{"start_date": {"2024-05-02"}, "end_date": {"2024-05-01"}},
+		{"min_duration": {"-1"}},
+		{"max_duration": {"1.5"}},
+		{"min_size": {"big"}},
+		{"max_size": {"-10"}},
+		{"sort": {"uploader"}},
+		{"order": {"up"}},
+		{"limit": {"0"}},
+		{"cursor": {"not base64!"}},
+		{"cursor": {"bm90IGpzb24"}},
+		// A cursor only continues the sort order it was issued for
+		{"sort": {"file_size"}, "order": {"asc"}, "cursor": {cursor}},
+		{"sort": {"upload_date"}, "cursor": {cursor}},
+		{"sort": {"file_size"}, "cursor": {encodeVideoCursor(videoCursor{Sort: "file_size", Desc: true, Value: "big", ID: 1})}},
+	}
+
+	for _, query := range tests {
+		if _, code := listVideos(t, user, query); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query.Encode(), code, http.StatusBadRequest)
+		}
+	}
+
+	if _, code := listVideos(t, user, url.Values{"sort": {"file_size"}, "cursor": {cursor}}); code != http.StatusOK {
+		t.Errorf("cursor for its own sort order: status %d", code)
+	}
+}
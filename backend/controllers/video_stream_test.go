@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+	"trialuploadhk/backend/storage"
+)
+
+// useTestStorage points storage.Service at an empty local directory
+func useTestStorage(t *testing.T) {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	storage.Service = store
+}
+
+// newTestVideo stores size bytes of content and a video uploaded by user,
+// in room when one is given
+func newTestVideo(t *testing.T, user *models.User, room *models.Room, size int) (*models.Video, []byte) {
+	t.Helper()
+	var count int64
+	config.DB.Unscoped().Model(&models.Video{}).Count(&count)
+
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	key := fmt.Sprintf("videos/clip_%d.mp4", count+1)
+	if _, err := storage.Service.Upload(key, bytes.NewReader(content)); err != nil {
+		t.Fatalf("store video: %v", err)
+	}
+
+	video := models.Video{
+		Filename:         path.Base(key),
+		OriginalFilename: "clip.mp4",
+		FilePath:         key,
+		FileSize:         int64(size),
+		ContentType:      "video/mp4",
+		ContentHash:      "abc123",
+		UploadedBy:       user.ID,
+	}
+	if room != nil {
+		video.RoomID = &room.ID
+	}
+	if err := config.DB.Create(&video).Error; err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	return &video, content
+}
+
+func streamVideo(user *models.User, video *models.Video, method string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, fmt.Sprintf("/videos/%d/stream", video.ID), nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	return serveRequest(user, "/videos/:id/stream", req, StreamVideo)
+}
+
+func TestStreamVideo(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	video, content := newTestVideo(t, user, nil, 1000)
+	etag := `"abc123"`
+
+	tests := []struct {
+		name         string
+		method       string
+		header       map[string]string
+		status       int
+		contentRange string
+		body         []byte
+	}{
+		{name: "whole file", method: http.MethodGet, status: http.StatusOK, body: content},
+		{name: "single range", method: http.MethodGet, header: map[string]string{"Range": "bytes=100-199"},
+			status: http.StatusPartialContent, contentRange: "bytes 100-199/1000", body: content[100:200]},
+		{name: "suffix range", method: http.MethodGet, header: map[string]string{"Range": "bytes=-10"},
+			status: http.StatusPartialContent, contentRange: "bytes 990-999/1000", body: content[990:]},
+		{name: "unsatisfiable range", method: http.MethodGet, header: map[string]string{"Range": "bytes=5000-"},
+			status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */1000"},
+		{name: "matching If-None-Match", method: http.MethodGet, header: map[string]string{"If-None-Match": etag},
+			status: http.StatusNotModified},
+		{name: "stale If-None-Match", method: http.MethodGet, header: map[string]string{"If-None-Match": `"old"`},
+			status: http.StatusOK, body: content},
+		{name: "matching If-Range", method: http.MethodGet, header: map[string]string{"Range": "bytes=0-9", "If-Range": etag},
+			status: http.StatusPartialContent, contentRange: "bytes 0-9/1000", body: content[:10]},
+		{name: "stale If-Range", method: http.MethodGet, header: map[string]string{"Range": "bytes=0-9", "If-Range": `"old"`},
+			status: http.StatusOK, body: content},
+		{name: "HEAD", method: http.MethodHead, status: http.StatusOK},
+		{name: "HEAD with range", method: http.MethodHead, header: map[string]string{"Range": "bytes=0-9"},
+			status: http.StatusPartialContent, contentRange: "bytes 0-9/1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := streamVideo(user, video, tt.method, tt.header)
+			assertStatus(t, w, tt.status)
+
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if got := w.Header().Get("ETag"); got != etag && tt.status != http.StatusRequestedRangeNotSatisfiable {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if tt.status != http.StatusNotModified && tt.status != http.StatusRequestedRangeNotSatisfiable {
+				if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+					t.Errorf("Accept-Ranges = %q, want bytes", got)
+				}
+			}
+			if tt.body != nil && !bytes.Equal(w.Body.Bytes(), tt.body) {
+				t.Errorf("body has %d bytes, want %d", w.Body.Len(), len(tt.body))
+			}
+			if tt.method == http.MethodHead && w.Body.Len() != 0 {
+				t.Errorf("HEAD response has a %d byte body", w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestStreamVideoMultipleRanges(t *testing.T) {
+	setupTestDB(t)
+	useTestStorage(t)
+	user := createTestUser(t, "uploader", models.RoleUser)
+	video, content := newTestVideo(t, user, nil, 1000)
+
+	w := streamVideo(user, video, http.MethodGet, map[string]string{"Range": "bytes=0-9,500-509"})
+	assertStatus(t, w, http.StatusPartialContent)
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "multipart/byteranges; boundary=") {
+		t.Fatalf("Content-Type = %q, want multipart/byteranges", got)
+	}
+	body := w.Body.String()
+	for _, part := range []string{"Content-Range: bytes 0-9/1000", "Content-Range: bytes 500-509/1000", "Content-Type: video/mp4"} {
+		if !strings.Contains(body, part) {
+			t.Errorf("multipart body lacks %q", part)
+		}
+	}
+	if !bytes.Contains(w.Body.Bytes(), content[500:510]) {
+		t.Error("multipart body lacks the second range")
+	}
+}
+
+func TestStreamVideoHiddenFromOtherUsers(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "uploader", models.RoleUser)
+	other := createTestUser(t, "other", models.RoleUser)
+	useTestStorage(t)
+	video, _ := newTestVideo(t, owner, nil, 1000)
+
+	w := streamVideo(other, video, http.MethodGet, nil)
+	assertStatus(t, w, http.StatusNotFound)
+}
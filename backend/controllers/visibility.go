@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// videoVisibility restricts a video query to the videos a user may see:
+// everything with videos.view.all, otherwise their own uploads plus the
+// rooms on their floors with videos.view.floor or their assigned rooms
+func videoVisibility(userID uint, role string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case middleware.RoleHasPermission(role, models.PermVideosViewAll):
+			return db
+		case middleware.RoleHasPermission(role, models.PermVideosViewFloor):
+			return db.Where("videos.uploaded_by = ? OR videos.room_id IN (?)", userID,
+				config.DB.Model(&models.Room{}).Select("rooms.id").
+					Joins("JOIN user_floors ON user_floors.floor = rooms.floor").
+					Where("user_floors.user_id = ? AND rooms.floor != ''", userID))
+		default:
+			return db.Where("videos.uploaded_by = ? OR videos.room_id IN (?)", userID,
+				config.DB.Model(&models.UserRoom{}).Select("room_id").Where("user_id = ?", userID))
+		}
+	}
+}
+
+// visibleVideos is videoVisibility for the authenticated user
+func visibleVideos(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	principal := middleware.CurrentPrincipal(c)
+	return videoVisibility(principal.UserID, principal.Role)
+}
+
+// findVisibleVideo loads the video named by the :id route parameter if the
+// authenticated user may see it
+func findVisibleVideo(c *gin.Context, video *models.Video) error {
+	return config.DB.Scopes(visibleVideos(c)).Where("videos.id = ?", c.Param("id")).First(video).Error
+}
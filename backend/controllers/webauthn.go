@@ -0,0 +1,445 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnRegisterFinishRequest struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	DeviceName  string          `json:"device_name" binding:"max=100"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
+
+type WebAuthnLoginBeginRequest struct {
+	Username string `json:"username"` // empty for a discoverable (username-less) login
+}
+
+type WebAuthnLoginFinishRequest struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
+
+// challengeTTL bounds a ceremony when the library sets no deadline
+const challengeTTL = 5 * time.Minute
+
+var webAuthn *webauthn.WebAuthn
+
+var errChallengeInvalid = errors.New("invalid or expired challenge")
+
+// InitWebAuthn configures the relying party from the WebAuthn settings
+func InitWebAuthn() error {
+	var err error
+	webAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          config.AppConfig.WebAuthn.RPID,
+		RPDisplayName: config.AppConfig.WebAuthn.RPName,
+		RPOrigins:     config.AppConfig.WebAuthn.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	return err
+}
+
+// webAuthnUser adapts a user and their passkeys to the webauthn library
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// webAuthnUserHandle is the opaque user handle stored with a passkey
+func webAuthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// loadWebAuthnUser loads the passkeys of user
+func loadWebAuthnUser(user *models.User) (*webAuthnUser, error) {
+	var stored []models.WebAuthnCredential
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, credential := range stored {
+		id, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+		if err != nil {
+			continue
+		}
+		transports := make([]protocol.AuthenticatorTransport, len(credential.Transports))
+		for i, transport := range credential.Transports {
+			transports[i] = protocol.AuthenticatorTransport(transport)
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: credential.BackupEligible,
+				BackupState:    credential.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    credential.AAGUID,
+				SignCount: credential.SignCount,
+			},
+		})
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// BeginWebAuthnRegistration starts registering a passkey for the
+// authenticated user on the current device
+func BeginWebAuthnRegistration(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, middleware.CurrentPrincipal(c).UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	account, err := loadWebAuthnUser(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	// Devices that already hold a passkey for the user are excluded
+	exclusions := webauthn.Credentials(account.credentials).CredentialDescriptors()
+	options, session, err := webAuthn.BeginRegistration(account, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		log.Printf("Failed to begin passkey registration for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin registration"})
+		return
+	}
+
+	challengeID, err := saveChallenge(models.WebAuthnRegistration, &user.ID, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      options,
+	})
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's response and
+// stores the new passkey
+func FinishWebAuthnRegistration(c *gin.Context) {
+	var req WebAuthnRegisterFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	challenge, err := takeChallenge(req.ChallengeID, models.WebAuthnRegistration)
+	if err != nil || challenge.UserID == nil || *challenge.UserID != principal.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, principal.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	account, err := loadWebAuthnUser(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential"})
+		return
+	}
+	credential, err := webAuthn.CreateCredential(account, challenge.SessionData, parsed)
+	if err != nil {
+		log.Printf("Passkey registration failed for user %d: %v", user.ID, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey verification failed"})
+		return
+	}
+
+	deviceName := strings.TrimSpace(req.DeviceName)
+	if deviceName == "" {
+		deviceName = "Passkey"
+	}
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+	stored := models.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		SignCount:       credential.Authenticator.SignCount,
+		DeviceName:      deviceName,
+	}
+	if err := config.DB.Create(&stored).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Passkey is already registered"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Passkey registered successfully",
+		"credential": stored,
+	})
+}
+
+// BeginWebAuthnLogin starts a passkey login. With a username the login is
+// limited to that user's passkeys, which suits devices shared by several
+// staff; without one the authenticator offers any passkey it holds.
+func BeginWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var options *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var userID *uint
+	var err error
+
+	if req.Username != "" {
+		var user models.User
+		if err := config.DB.Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No passkeys registered for this user"})
+			return
+		}
+		account, loadErr := loadWebAuthnUser(&user)
+		if loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+			return
+		}
+		if len(account.credentials) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No passkeys registered for this user"})
+			return
+		}
+		options, session, err = webAuthn.BeginLogin(account)
+		userID = &user.ID
+	} else {
+		options, session, err = webAuthn.BeginDiscoverableLogin()
+	}
+	if err != nil {
+		log.Printf("Failed to begin passkey login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin login"})
+		return
+	}
+
+	challengeID, err := saveChallenge(models.WebAuthnLogin, userID, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      options,
+	})
+}
+
+// FinishWebAuthnLogin verifies a passkey assertion and signs the user in
+// with the same response as Login
+func FinishWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	challenge, err := takeChallenge(req.ChallengeID, models.WebAuthnLogin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential"})
+		return
+	}
+
+	var user models.User
+	var credential *webauthn.Credential
+	if challenge.UserID != nil {
+		if err := config.DB.Where("id = ? AND is_active = ?", *challenge.UserID, true).First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		account, loadErr := loadWebAuthnUser(&user)
+		if loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load passkeys"})
+			return
+		}
+		credential, err = webAuthn.ValidateLogin(account, challenge.SessionData, parsed)
+	} else {
+		// The authenticator names the user through the handle stored with
+		// the passkey
+		_, credential, err = webAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			if len(userHandle) != 8 {
+				return nil, errors.New("unknown user handle")
+			}
+			id := binary.BigEndian.Uint64(userHandle)
+			if err := config.DB.Where("id = ? AND is_active = ?", id, true).First(&user).Error; err != nil {
+				return nil, err
+			}
+			account, err := loadWebAuthnUser(&user)
+			if err != nil {
+				return nil, err
+			}
+			return account, nil
+		}, challenge.SessionData, parsed)
+	}
+	if err != nil {
+		log.Printf("Passkey login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	// A signature counter that went backwards suggests a cloned authenticator
+	if credential.Authenticator.CloneWarning {
+		log.Printf("Passkey of user %d failed the signature counter check", user.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	now := time.Now()
+	err = config.DB.Model(&models.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", user.ID, base64.RawURLEncoding.EncodeToString(credential.ID)).
+		Updates(map[string]interface{}{
+			"sign_count":   credential.Authenticator.SignCount,
+			"backup_state": credential.Flags.BackupState,
+			"last_used_at": now,
+		}).Error
+	if err != nil {
+		log.Printf("Failed to update passkey of user %d: %v", user.ID, err)
+	}
+
+	completeLogin(c, &user)
+}
+
+// GetWebAuthnCredentials lists the authenticated user's passkeys
+func GetWebAuthnCredentials(c *gin.Context) {
+	var credentials []models.WebAuthnCredential
+	err := config.DB.Where("user_id = ?", middleware.CurrentPrincipal(c).UserID).
+		Order("created_at ASC").Find(&credentials).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+	})
+}
+
+// DeleteWebAuthnCredential removes one of the authenticated user's passkeys
+func DeleteWebAuthnCredential(c *gin.Context) {
+	result := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), middleware.CurrentPrincipal(c).UserID).
+		Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Passkey deleted successfully",
+	})
+}
+
+// saveChallenge stores the server side of a ceremony and returns its ID
+func saveChallenge(purpose string, userID *uint, session *webauthn.SessionData) (string, error) {
+	expires := session.Expires
+	if expires.IsZero() {
+		expires = time.Now().Add(challengeTTL)
+	}
+
+	challenge := models.WebAuthnChallenge{
+		ID:          uuid.NewString(),
+		Purpose:     purpose,
+		UserID:      userID,
+		SessionData: *session,
+		ExpiresAt:   expires,
+	}
+	if err := config.DB.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return challenge.ID, nil
+}
+
+// takeChallenge loads and deletes a pending ceremony, so every challenge
+// can be answered once
+func takeChallenge(id, purpose string) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND purpose = ? AND expires_at > ?", id, purpose, time.Now()).
+			First(&challenge).Error; err != nil {
+			return errChallengeInvalid
+		}
+		result := tx.Delete(&challenge)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errChallengeInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func cleanupExpiredWebAuthnChallenges() {
+	if err := config.DB.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+		log.Printf("Failed to remove expired WebAuthn challenges: %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Minimal CBOR encoding, enough for attestation objects and COSE keys
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	default:
+		return []byte{major<<5 | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(v int64) []byte {
+	if v < 0 {
+		return cborHead(1, uint64(-1-v))
+	}
+	return cborHead(0, uint64(v))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+// cborMap encodes alternating keys and values
+func cborMap(items ...[]byte) []byte {
+	out := cborHead(5, uint64(len(items)/2))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// testAuthenticator is a software passkey with an ES256 key
+type testAuthenticator struct {
+	key       *ecdsa.PrivateKey
+	id        []byte
+	signCount uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &testAuthenticator{key: key, id: id}
+}
+
+// authenticatorData builds the data the authenticator signs; the user is
+// present and verified
+func (a *testAuthenticator) authenticatorData(attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(config.AppConfig.WebAuthn.RPID))
+	flags := byte(0x01 | 0x04)
+	if attested != nil {
+		flags |= 0x40
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *testAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, _ := json.Marshal(gin.H{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    config.AppConfig.WebAuthn.RPOrigins[0],
+	})
+	return data
+}
+
+// create answers registration options with a "none" attestation
+func (a *testAuthenticator) create(challenge string) json.RawMessage {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+	publicKey := cborMap(
+		cborInt(1), cborInt(2), // kty: EC2
+		cborInt(3), cborInt(-7), // alg: ES256
+		cborInt(-1), cborInt(1), // crv: P-256
+		cborInt(-2), cborBytes(x),
+		cborInt(-3), cborBytes(y),
+	)
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, publicKey...)
+
+	attestation := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authenticatorData(attested)),
+	)
+
+	return a.credential(gin.H{
+		"clientDataJSON":    a.encode(a.clientData("webauthn.create", challenge)),
+		"attestationObject": a.encode(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// get signs login options, naming the user for discoverable logins
+func (a *testAuthenticator) get(t *testing.T, challenge string, userHandle []byte) json.RawMessage {
+	t.Helper()
+	a.signCount++
+	authData := a.authenticatorData(nil)
+	clientData := a.clientData("webauthn.get", challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(gin.H{
+		"clientDataJSON":    a.encode(clientData),
+		"authenticatorData": a.encode(authData),
+		"signature":         a.encode(signature),
+		"userHandle":        a.encode(userHandle),
+	})
+}
+
+func (a *testAuthenticator) credential(response gin.H) json.RawMessage {
+	data, _ := json.Marshal(gin.H{
+		"id":       a.encode(a.id),
+		"rawId":    a.encode(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	return data
+}
+
+func (a *testAuthenticator) encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ceremony is the body of a begin response
+type ceremony struct {
+	ChallengeID string `json:"challenge_id"`
+	Options     struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+func decodeCeremony(t *testing.T, w *httptest.ResponseRecorder) ceremony {
+	t.Helper()
+	assertStatus(t, w, http.StatusOK)
+	var begun ceremony
+	if err := json.Unmarshal(w.Body.Bytes(), &begun); err != nil {
+		t.Fatal(err)
+	}
+	if begun.ChallengeID == "" || begun.Options.PublicKey.Challenge == "" {
+		t.Fatalf("begin returned %s", w.Body.String())
+	}
+	return begun
+}
+
+func setupWebAuthn(t *testing.T) {
+	t.Helper()
+	setupTestDB(t)
+	if err := InitWebAuthn(); err != nil {
+		t.Fatalf("init webauthn: %v", err)
+	}
+}
+
+func beginRegistration(t *testing.T, user *models.User) ceremony {
+	t.Helper()
+	return decodeCeremony(t, performRequest(t, user, "POST", "/register/begin", "/register/begin", nil, BeginWebAuthnRegistration))
+}
+
+func finishRegistration(t *testing.T, user *models.User, challengeID string, credential json.RawMessage) *httptest.ResponseRecorder {
+	t.Helper()
+	body := gin.H{"challenge_id": challengeID, "device_name": "Front desk", "credential": credential}
+	return performRequest(t, user, "POST", "/register/finish", "/register/finish", body, FinishWebAuthnRegistration)
+}
+
+// registerPasskey registers a new software passkey for user
+func registerPasskey(t *testing.T, user *models.User) *testAuthenticator {
+	t.Helper()
+	authenticator := newTestAuthenticator(t)
+	begun := beginRegistration(t, user)
+	assertStatus(t, finishRegistration(t, user, begun.ChallengeID, authenticator.create(begun.Options.PublicKey.Challenge)), http.StatusCreated)
+	return authenticator
+}
+
+func beginLogin(t *testing.T, username string) *httptest.ResponseRecorder {
+	t.Helper()
+	return performRequest(t, nil, "POST", "/authenticate/begin", "/authenticate/begin", gin.H{"username": username}, BeginWebAuthnLogin)
+}
+
+func finishLogin(t *testing.T, challengeID string, credential json.RawMessage) *httptest.ResponseRecorder {
+	t.Helper()
+	body := gin.H{"challenge_id": challengeID, "credential": credential}
+	return performRequest(t, nil, "POST", "/authenticate/finish", "/authenticate/finish", body, FinishWebAuthnLogin)
+}
+
+func storedPasskey(t *testing.T, user *models.User) models.WebAuthnCredential {
+	t.Helper()
+	var credential models.WebAuthnCredential
+	if err := config.DB.Where("user_id = ?", user.ID).First(&credential).Error; err != nil {
+		t.Fatalf("load passkey: %v", err)
+	}
+	return credential
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	setupWebAuthn(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	authenticator := registerPasskey(t, user)
+
+	stored := storedPasskey(t, user)
+	if stored.CredentialID != authenticator.encode(authenticator.id) || stored.DeviceName != "Front desk" {
+		t.Errorf("stored passkey %+v", stored)
+	}
+
+	t.Run("with username", func(t *testing.T) {
+		begun := decodeCeremony(t, beginLogin(t, "alice"))
+		w := finishLogin(t, begun.ChallengeID, authenticator.get(t, begun.Options.PublicKey.Challenge, nil))
+		assertStatus(t, w, http.StatusOK)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w := authorized(body.Token, "GET", noContent); w.Code != http.StatusNoContent {
+			t.Errorf("passkey login token: status %d", w.Code)
+		}
+	})
+
+	t.Run("discoverable", func(t *testing.T) {
+		begun := decodeCeremony(t, beginLogin(t, ""))
+		credential := authenticator.get(t, begun.Options.PublicKey.Challenge, webAuthnUserHandle(user.ID))
+		assertStatus(t, finishLogin(t, begun.ChallengeID, credential), http.StatusOK)
+	})
+
+	stored = storedPasskey(t, user)
+	if stored.SignCount != authenticator.signCount || stored.LastUsedAt == nil {
+		t.Errorf("after login sign_count = %d, last_used_at = %v; want %d and a time", stored.SignCount, stored.LastUsedAt, authenticator.signCount)
+	}
+}
+
+func TestWebAuthnChallengeIsSingleUse(t *testing.T) {
+	setupWebAuthn(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	authenticator := registerPasskey(t, user)
+
+	begun := decodeCeremony(t, beginLogin(t, "alice"))
+	credential := authenticator.get(t, begun.Options.PublicKey.Challenge, nil)
+	assertStatus(t, finishLogin(t, begun.ChallengeID, credential), http.StatusOK)
+
+	// A captured assertion cannot be replayed against the same challenge...
+	assertStatus(t, finishLogin(t, begun.ChallengeID, credential), http.StatusBadRequest)
+
+	// ...nor answer a challenge it was not signed for
+	other := decodeCeremony(t, beginLogin(t, "alice"))
+	assertStatus(t, finishLogin(t, other.ChallengeID, credential), http.StatusUnauthorized)
+}
+
+func TestWebAuthnLoginRejectsChallenge(t *testing.T) {
+	tests := []struct {
+		name  string
+		begin func(t *testing.T, user *models.User) ceremony
+	}{
+		{"expired", func(t *testing.T, user *models.User) ceremony {
+			begun := decodeCeremony(t, beginLogin(t, user.Username))
+			config.DB.Model(&models.WebAuthnChallenge{}).Where("id = ?", begun.ChallengeID).
+				Update("expires_at", time.Now().Add(-time.Second))
+			return begun
+		}},
+		{"issued for registration", func(t *testing.T, user *models.User) ceremony {
+			return beginRegistration(t, user)
+		}},
+		{"unknown", func(t *testing.T, user *models.User) ceremony {
+			begun := decodeCeremony(t, beginLogin(t, user.Username))
+			begun.ChallengeID = "00000000-0000-0000-0000-000000000000"
+			return begun
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupWebAuthn(t)
+			user := createTestUser(t, "alice", models.RoleUser)
+			authenticator := registerPasskey(t, user)
+
+			begun := tt.begin(t, user)
+			credential := authenticator.get(t, begun.Options.PublicKey.Challenge, webAuthnUserHandle(user.ID))
+			assertStatus(t, finishLogin(t, begun.ChallengeID, credential), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestWebAuthnRegistrationChallengeBelongsToUser(t *testing.T) {
+	setupWebAuthn(t)
+	alice := createTestUser(t, "alice", models.RoleUser)
+	mallory := createTestUser(t, "mallory", models.RoleUser)
+
+	begun := beginRegistration(t, alice)
+	credential := newTestAuthenticator(t).create(begun.Options.PublicKey.Challenge)
+	assertStatus(t, finishRegistration(t, mallory, begun.ChallengeID, credential), http.StatusBadRequest)
+
+	var count int64
+	config.DB.Model(&models.WebAuthnCredential{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d passkeys stored from another user's challenge", count)
+	}
+}
+
+func TestWebAuthnLoginRejectsClonedAuthenticator(t *testing.T) {
+	setupWebAuthn(t)
+	user := createTestUser(t, "alice", models.RoleUser)
+	authenticator := registerPasskey(t, user)
+
+	authenticator.signCount = 10
+	begun := decodeCeremony(t, beginLogin(t, "alice"))
+	assertStatus(t, finishLogin(t, begun.ChallengeID, authenticator.get(t, begun.Options.PublicKey.Challenge, nil)), http.StatusOK)
+
+	// A copy of the key still counting from an earlier state
+	authenticator.signCount = 5
+	begun = decodeCeremony(t, beginLogin(t, "alice"))
+	assertStatus(t, finishLogin(t, begun.ChallengeID, authenticator.get(t, begun.Options.PublicKey.Challenge, nil)), http.StatusUnauthorized)
+}
+
+func TestBeginWebAuthnLoginWithoutPasskeys(t *testing.T) {
+	setupWebAuthn(t)
+	createTestUser(t, "alice", models.RoleUser)
+	inactive := createTestUser(t, "bob", models.RoleUser)
+	registerPasskey(t, inactive)
+	config.DB.Model(inactive).Update("is_active", false)
+
+	for _, username := range []string{"alice", "bob", "nobody"} {
+		if w := beginLogin(t, username); w.Code != http.StatusBadRequest {
+			t.Errorf("begin login as %s: status %d, want %d", username, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	var count int64
+	config.DB.Model(&models.WebAuthnChallenge{}).Where("purpose = ?", models.WebAuthnLogin).Count(&count)
+	if count != 0 {
+		t.Errorf("%d login challenges stored for refused logins", count)
+	}
+}
+
+func TestCleanupExpiredWebAuthnChallenges(t *testing.T) {
+	setupWebAuthn(t)
+	expired := decodeCeremony(t, beginLogin(t, ""))
+	pending := decodeCeremony(t, beginLogin(t, ""))
+	config.DB.Model(&models.WebAuthnChallenge{}).Where("id = ?", expired.ChallengeID).
+		Update("expires_at", time.Now().Add(-time.Second))
+
+	cleanupExpiredWebAuthnChallenges()
+
+	var ids []string
+	config.DB.Model(&models.WebAuthnChallenge{}).Pluck("id", &ids)
+	if len(ids) != 1 || ids[0] != pending.ChallengeID {
+		t.Errorf("challenges after cleanup = %v, want only %s", ids, pending.ChallengeID)
+	}
+}
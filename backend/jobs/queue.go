@@ -0,0 +1,287 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Handler processes one job. Returning an error schedules a retry unless
+// the error is wrapped with Permanent or the job is out of attempts.
+type Handler func(ctx context.Context, job *models.Job) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+
+	// wake nudges idle workers when a job is enqueued
+	wake = make(chan struct{}, 1)
+)
+
+// Backoff bounds for retries: 5s, 10s, 20s, ... up to 10 minutes
+const (
+	baseBackoff = 5 * time.Second
+	maxBackoff  = 10 * time.Minute
+)
+
+// jobTimeout bounds a single attempt
+const jobTimeout = 30 * time.Minute
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix, such as a deleted video
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Register installs the handler for a job type
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Enqueue persists a job to run as soon as a worker is free. Jobs for a
+// video mark it as processing until they finish.
+func Enqueue(jobType string, videoID *uint, payload string) (*models.Job, error) {
+	job := &models.Job{
+		Type:        jobType,
+		VideoID:     videoID,
+		Payload:     payload,
+		Status:      models.JobStatusQueued,
+		MaxAttempts: config.AppConfig.Jobs.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = 1
+	}
+
+	// Mark the video first; a worker may finish the job before Create returns
+	if videoID != nil {
+		config.DB.Model(&models.Video{}).Where("id = ?", *videoID).
+			Update("processing_status", models.ProcessingStatusProcessing)
+	}
+
+	if err := config.DB.Create(job).Error; err != nil {
+		if videoID != nil {
+			RefreshVideoStatus(*videoID)
+		}
+		return nil, fmt.Errorf("enqueue %s job: %w", jobType, err)
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// StartWorkers requeues jobs interrupted by a restart and starts the
+// configured number of workers
+func StartWorkers() {
+	cfg := config.AppConfig.Jobs
+
+	requeueInterrupted()
+
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go work(cfg.PollInterval)
+	}
+
+	log.Printf("Job queue started with %d workers", workers)
+}
+
+// requeueInterrupted makes jobs left running by a crash or restart due
+// again. The interrupted attempt still counts towards MaxAttempts.
+func requeueInterrupted() {
+	result := config.DB.Model(&models.Job{}).
+		Where("status = ?", models.JobStatusRunning).
+		Updates(map[string]interface{}{"status": models.JobStatusQueued, "run_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Failed to requeue interrupted jobs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Requeued %d interrupted jobs", result.RowsAffected)
+	}
+}
+
+func work(pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+
+	for {
+		job, err := claimNext()
+		if err != nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+		if job != nil {
+			run(job)
+			continue
+		}
+
+		select {
+		case <-wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// claimNext marks the oldest due job as running. The conditional update
+// makes sure only one worker wins a job.
+func claimNext() (*models.Job, error) {
+	// Polling runs every few seconds per worker; keep it out of the SQL log
+	quiet := config.DB.Session(&gorm.Session{Logger: config.DB.Logger.LogMode(logger.Warn)})
+
+	for {
+		var job models.Job
+		err := quiet.Where("status = ? AND run_at <= ?", models.JobStatusQueued, time.Now()).
+			Order("run_at, id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		claim := config.DB.Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, models.JobStatusQueued).
+			Updates(map[string]interface{}{
+				"status":     models.JobStatusRunning,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"started_at": now,
+			})
+		if claim.Error != nil {
+			return nil, claim.Error
+		}
+		if claim.RowsAffected == 1 {
+			job.Status = models.JobStatusRunning
+			job.Attempts++
+			job.StartedAt = &now
+			return &job, nil
+		}
+		// Another worker claimed it first
+	}
+}
+
+func run(job *models.Job) {
+	handlersMu.RLock()
+	handler, ok := handlers[job.Type]
+	handlersMu.RUnlock()
+
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	} else {
+		err = safeRun(handler, job)
+	}
+
+	finish(job, err)
+}
+
+// safeRun keeps a panicking handler from taking the worker down
+func safeRun(handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	return handler(ctx, job)
+}
+
+// finish records the outcome of an attempt and schedules a retry if needed
+func finish(job *models.Job, err error) {
+	now := time.Now()
+	updates := map[string]interface{}{}
+
+	var permanent permanentError
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["last_error"] = ""
+		updates["finished_at"] = now
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed: %v", job.ID, job.Type, err)
+		updates["status"] = models.JobStatusFailed
+		updates["last_error"] = err.Error()
+		updates["finished_at"] = now
+	default:
+		delay := backoff(job.Attempts)
+		log.Printf("Job %d (%s) attempt %d failed, retrying in %s: %v", job.ID, job.Type, job.Attempts, delay, err)
+		updates["status"] = models.JobStatusQueued
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now.Add(delay)
+	}
+
+	if err := config.DB.Model(job).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update job %d: %v", job.ID, err)
+	}
+
+	if job.VideoID != nil {
+		RefreshVideoStatus(*job.VideoID)
+	}
+}
+
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// VideoStatus derives a video's processing status from its jobs: processing
+// while any job is pending, failed if any job gave up, otherwise ready
+func VideoStatus(videoID uint) (string, error) {
+	var statuses []string
+	err := config.DB.Model(&models.Job{}).Where("video_id = ?", videoID).
+		Distinct().Pluck("status", &statuses).Error
+	if err != nil {
+		return "", err
+	}
+
+	status := models.ProcessingStatusReady
+	for _, s := range statuses {
+		switch s {
+		case models.JobStatusQueued, models.JobStatusRunning:
+			return models.ProcessingStatusProcessing, nil
+		case models.JobStatusFailed:
+			status = models.ProcessingStatusFailed
+		}
+	}
+	return status, nil
+}
+
+// RefreshVideoStatus stores the derived processing status on the video
+func RefreshVideoStatus(videoID uint) {
+	status, err := VideoStatus(videoID)
+	if err != nil {
+		log.Printf("Failed to load jobs for video %d: %v", videoID, err)
+		return
+	}
+
+	config.DB.Model(&models.Video{}).Where("id = ?", videoID).Update("processing_status", status)
+}
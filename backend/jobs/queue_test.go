@@ -0,0 +1,350 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB points config.DB at an empty database and allows three
+// attempts per job
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	config.LoadConfig()
+	config.AppConfig.Jobs.MaxAttempts = 3
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	config.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := config.MigrateDatabase(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+}
+
+// registerTestHandler installs handler under a job type unique to the test
+func registerTestHandler(t *testing.T, handler Handler) string {
+	t.Helper()
+	jobType := "test_" + t.Name()
+	Register(jobType, handler)
+	t.Cleanup(func() {
+		handlersMu.Lock()
+		delete(handlers, jobType)
+		handlersMu.Unlock()
+	})
+	return jobType
+}
+
+func newTestVideoID(t *testing.T) *uint {
+	t.Helper()
+	user := models.User{Username: "uploader", Email: "uploader@example.com", PasswordHash: "unused", Role: models.RoleUser}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	video := models.Video{Filename: "clip.mp4", FilePath: "videos/clip.mp4", UploadedBy: user.ID}
+	if err := config.DB.Create(&video).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &video.ID
+}
+
+func enqueue(t *testing.T, jobType string, videoID *uint) *models.Job {
+	t.Helper()
+	job, err := Enqueue(jobType, videoID, "")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return job
+}
+
+func reload(t *testing.T, job *models.Job) models.Job {
+	t.Helper()
+	var stored models.Job
+	if err := config.DB.First(&stored, job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// claimAndRun runs the next due job as a worker would
+func claimAndRun(t *testing.T) *models.Job {
+	t.Helper()
+	job, err := claimNext()
+	if err != nil {
+		t.Fatalf("claimNext: %v", err)
+	}
+	if job == nil {
+		t.Fatal("no job was due")
+	}
+	run(job)
+	return job
+}
+
+// makeDue moves a job's retry time into the past
+func makeDue(t *testing.T, job *models.Job) {
+	t.Helper()
+	config.DB.Model(&models.Job{}).Where("id = ?", job.ID).Update("run_at", time.Now().Add(-time.Second))
+}
+
+func videoStatus(t *testing.T, videoID *uint) string {
+	t.Helper()
+	var video models.Video
+	if err := config.DB.First(&video, *videoID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return video.ProcessingStatus
+}
+
+func TestClaimNextTakesDueJobsInOrder(t *testing.T) {
+	setupTestDB(t)
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error { return nil })
+
+	first := enqueue(t, jobType, nil)
+	second := enqueue(t, jobType, nil)
+	later := enqueue(t, jobType, nil)
+	config.DB.Model(later).Update("run_at", time.Now().Add(time.Hour))
+
+	for _, want := range []*models.Job{first, second} {
+		job, err := claimNext()
+		if err != nil || job == nil {
+			t.Fatalf("claimNext = %v, %v", job, err)
+		}
+		if job.ID != want.ID {
+			t.Errorf("claimed job %d, want %d", job.ID, want.ID)
+		}
+		stored := reload(t, job)
+		if stored.Status != models.JobStatusRunning || stored.Attempts != 1 || stored.StartedAt == nil {
+			t.Errorf("claimed job stored as %s with %d attempts", stored.Status, stored.Attempts)
+		}
+	}
+
+	if job, err := claimNext(); err != nil || job != nil {
+		t.Errorf("claimNext before the job is due = %v, %v; want nothing", job, err)
+	}
+}
+
+func TestClaimNextHandsOutEachJobOnce(t *testing.T) {
+	setupTestDB(t)
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error { return nil })
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		enqueue(t, jobType, nil)
+	}
+
+	var mu sync.Mutex
+	claimed := map[uint]int{}
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := claimNext()
+				if err != nil {
+					t.Errorf("claimNext: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != total {
+		t.Errorf("claimed %d distinct jobs, want %d", len(claimed), total)
+	}
+	for id, count := range claimed {
+		if count != 1 {
+			t.Errorf("job %d was claimed %d times", id, count)
+		}
+	}
+}
+
+func TestRunSucceeds(t *testing.T) {
+	setupTestDB(t)
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error { return nil })
+	videoID := newTestVideoID(t)
+
+	job := enqueue(t, jobType, videoID)
+	if status := videoStatus(t, videoID); status != models.ProcessingStatusProcessing {
+		t.Errorf("video status while queued = %q", status)
+	}
+
+	claimAndRun(t)
+
+	stored := reload(t, job)
+	if stored.Status != models.JobStatusSucceeded || stored.FinishedAt == nil {
+		t.Errorf("job stored as %s, finished %v", stored.Status, stored.FinishedAt)
+	}
+	if status := videoStatus(t, videoID); status != models.ProcessingStatusReady {
+		t.Errorf("video status = %q, want %q", status, models.ProcessingStatusReady)
+	}
+}
+
+func TestRunRetriesWithBackoffUntilMaxAttempts(t *testing.T) {
+	setupTestDB(t)
+	calls := 0
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error {
+		calls++
+		return fmt.Errorf("attempt %d failed", calls)
+	})
+	videoID := newTestVideoID(t)
+	job := enqueue(t, jobType, videoID)
+
+	for attempt, delay := range []time.Duration{5 * time.Second, 10 * time.Second} {
+		before := time.Now()
+		claimAndRun(t)
+
+		stored := reload(t, job)
+		if stored.Status != models.JobStatusQueued || stored.Attempts != attempt+1 {
+			t.Fatalf("after attempt %d: status %s, attempts %d", attempt+1, stored.Status, stored.Attempts)
+		}
+		if retry := stored.RunAt.Sub(before); retry < delay || retry > delay+time.Second {
+			t.Errorf("after attempt %d: retry in %s, want %s", attempt+1, retry, delay)
+		}
+		if stored.LastError != fmt.Sprintf("attempt %d failed", attempt+1) {
+			t.Errorf("after attempt %d: last error %q", attempt+1, stored.LastError)
+		}
+		if status := videoStatus(t, videoID); status != models.ProcessingStatusProcessing {
+			t.Errorf("after attempt %d: video status %q", attempt+1, status)
+		}
+
+		if next, _ := claimNext(); next != nil {
+			t.Fatalf("job %d was claimed before its retry was due", next.ID)
+		}
+		makeDue(t, job)
+	}
+
+	claimAndRun(t)
+
+	stored := reload(t, job)
+	if stored.Status != models.JobStatusFailed || stored.Attempts != 3 || stored.FinishedAt == nil {
+		t.Errorf("after the last attempt: status %s, attempts %d", stored.Status, stored.Attempts)
+	}
+	if status := videoStatus(t, videoID); status != models.ProcessingStatusFailed {
+		t.Errorf("video status = %q, want %q", status, models.ProcessingStatusFailed)
+	}
+	if next, _ := claimNext(); next != nil {
+		t.Errorf("failed job %d was claimed again", next.ID)
+	}
+}
+
+func TestRunFailsPermanently(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+	}{
+		{"permanent error", func(ctx context.Context, job *models.Job) error {
+			return Permanent(errors.New("video was deleted"))
+		}},
+		{"missing handler", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			jobType := "test_unregistered"
+			if tt.handler != nil {
+				jobType = registerTestHandler(t, tt.handler)
+			}
+			job := enqueue(t, jobType, nil)
+
+			claimAndRun(t)
+
+			stored := reload(t, job)
+			if stored.Status != models.JobStatusFailed || stored.Attempts != 1 || stored.LastError == "" {
+				t.Errorf("job stored as %s after %d attempts, error %q", stored.Status, stored.Attempts, stored.LastError)
+			}
+		})
+	}
+}
+
+func TestRunRecoversFromPanic(t *testing.T) {
+	setupTestDB(t)
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error {
+		panic("boom")
+	})
+	job := enqueue(t, jobType, nil)
+
+	claimAndRun(t)
+
+	stored := reload(t, job)
+	if stored.Status != models.JobStatusQueued || stored.LastError != "panic: boom" {
+		t.Errorf("job stored as %s, error %q; want a retry", stored.Status, stored.LastError)
+	}
+}
+
+func TestRequeueInterrupted(t *testing.T) {
+	setupTestDB(t)
+	jobType := registerTestHandler(t, func(ctx context.Context, job *models.Job) error { return nil })
+
+	interrupted := enqueue(t, jobType, nil)
+	finished := enqueue(t, jobType, nil)
+	// A worker claims the first job and the server dies before it finishes
+	if job, err := claimNext(); err != nil || job == nil || job.ID != interrupted.ID {
+		t.Fatalf("claimNext = %v, %v", job, err)
+	}
+	claimAndRun(t)
+
+	requeueInterrupted()
+
+	stored := reload(t, interrupted)
+	if stored.Status != models.JobStatusQueued || stored.RunAt.After(time.Now()) {
+		t.Fatalf("interrupted job stored as %s, due %s", stored.Status, stored.RunAt)
+	}
+	if stored := reload(t, finished); stored.Status != models.JobStatusSucceeded {
+		t.Errorf("finished job was requeued as %s", stored.Status)
+	}
+
+	job := claimAndRun(t)
+	if job.ID != interrupted.ID || job.Attempts != 2 {
+		t.Errorf("reran job %d at attempt %d, want job %d at attempt 2", job.ID, job.Attempts, interrupted.ID)
+	}
+	if stored := reload(t, interrupted); stored.Status != models.JobStatusSucceeded {
+		t.Errorf("interrupted job ended as %s", stored.Status)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{8, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
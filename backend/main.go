@@ -4,10 +4,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/controllers"
+	"trialuploadhk/backend/jobs"
+	"trialuploadhk/backend/media"
 	"trialuploadhk/backend/middleware"
+	"trialuploadhk/backend/notify"
 	"trialuploadhk/backend/routes"
+	"trialuploadhk/backend/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,17 +25,50 @@ func main() {
 	// Initialize database
 	config.InitDatabase()
 
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(config.AppConfig.Upload.Dir, 0755); err != nil {
-		log.Fatal("Failed to create upload directory:", err)
+	// Load the permissions granted to each role
+	if err := middleware.LoadPermissions(); err != nil {
+		log.Fatal("Failed to load permissions:", err)
 	}
 
+	// Configure passkey login
+	if err := controllers.InitWebAuthn(); err != nil {
+		log.Fatal("Failed to configure WebAuthn:", err)
+	}
+
+	// Initialize video storage (creates the upload directory for local disk)
+	storage.InitStorage()
+
+	// Deliver password reset codes (or show them to the supervisor)
+	notify.InitNotifier()
+
+	// Initialize the ffmpeg adapters used for thumbnails
+	media.InitMedia()
+
+	// Process uploaded videos in the background
+	controllers.RegisterJobHandlers()
+	jobs.StartWorkers()
+
+	// Remove abandoned resumable uploads in the background
+	controllers.StartUploadSessionCleanup(time.Hour)
+
+	// Remove expired export archives in the background
+	controllers.StartExportCleanup(time.Hour)
+
+	// Remove expired login sessions and passkey challenges in the background
+	controllers.StartSessionCleanup(time.Hour)
+
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
 	// Create router
 	router := gin.Default()
 
+	// Only trust forwarding headers from configured proxies, so clients cannot
+	// pick the address that login lockouts and audit entries are keyed on
+	if err := router.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// Apply middleware
 	router.Use(middleware.CORSMiddleware())
 
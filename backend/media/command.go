@@ -0,0 +1,58 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// ErrUnavailable is returned when a command cannot be started at all, e.g.
+// because ffmpeg is not installed
+var ErrUnavailable = errors.New("media: command unavailable")
+
+// Runner runs an external command. The ffmpeg adapters go through it so a
+// fake can record invocations instead of running a real transcoder.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) error
+}
+
+// unavailableRunner stands in for a command that is not installed
+type unavailableRunner struct {
+	err error
+}
+
+func (r unavailableRunner) Run(ctx context.Context, name string, args ...string) error {
+	return fmt.Errorf("%w: %v", ErrUnavailable, r.err)
+}
+
+// ExecRunner runs commands with os/exec
+type ExecRunner struct{}
+
+// maxStderr bounds how much command output is kept for error messages
+const maxStderr = 4096
+
+// Run executes name and includes the tail of its stderr in the error
+func (ExecRunner) Run(ctx context.Context, name string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) && (errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission)) {
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+
+		output := stderr.Bytes()
+		if len(output) > maxStderr {
+			output = output[len(output)-maxStderr:]
+		}
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
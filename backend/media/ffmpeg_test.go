@@ -0,0 +1,160 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"trialuploadhk/backend/config"
+)
+
+// fakeRunner records the commands it is asked to run
+type fakeRunner struct {
+	calls [][]string
+	err   error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) error {
+	r.calls = append(r.calls, append([]string{name}, args...))
+	return r.err
+}
+
+// command returns the only recorded command line
+func (r *fakeRunner) command(t *testing.T) string {
+	t.Helper()
+	if len(r.calls) != 1 {
+		t.Fatalf("ran %d commands, want 1", len(r.calls))
+	}
+	return strings.Join(r.calls[0], " ")
+}
+
+func assertArgs(t *testing.T, command string, want ...string) {
+	t.Helper()
+	for _, part := range want {
+		if !strings.Contains(command, part) {
+			t.Errorf("command %q lacks %q", command, part)
+		}
+	}
+}
+
+func TestFFmpegExtractorPoster(t *testing.T) {
+	runner := &fakeRunner{}
+	extractor := NewFFmpegExtractor("/opt/ffmpeg", runner)
+
+	if err := extractor.Poster(context.Background(), "in.mp4", "poster.jpg", 2.5, 320); err != nil {
+		t.Fatalf("Poster: %v", err)
+	}
+	command := runner.command(t)
+	if !strings.HasPrefix(command, "/opt/ffmpeg ") || !strings.HasSuffix(command, " poster.jpg") {
+		t.Errorf("command %q does not run the binary and write the poster last", command)
+	}
+	assertArgs(t, command, "-ss 2.500 -i in.mp4", "-frames:v 1", "-vf scale=320:-2")
+}
+
+func TestFFmpegExtractorSprite(t *testing.T) {
+	runner := &fakeRunner{}
+	extractor := NewFFmpegExtractor("ffmpeg", runner)
+
+	if err := extractor.Sprite(context.Background(), "in.mp4", "sprite.jpg", 20, 10, 160); err != nil {
+		t.Fatalf("Sprite: %v", err)
+	}
+	assertArgs(t, runner.command(t), "-vf fps=0.500,scale=160:-2,tile=10x1", "sprite.jpg")
+
+	if err := extractor.Sprite(context.Background(), "in.mp4", "sprite.jpg", 0, 10, 160); err == nil {
+		t.Error("Sprite without a duration succeeded")
+	}
+	if len(runner.calls) != 1 {
+		t.Error("Sprite without a duration ran ffmpeg")
+	}
+}
+
+func TestFFmpegEncoderTranscode(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		{"normalized", []string{"scale=-2:'min(1080,ih)'", "-crf 23", "-b:a 128k"}},
+		{"preview", []string{"scale=-2:'min(360,ih)'", "-b:v 400k -maxrate 400k -bufsize 800k", "-b:a 64k"}},
+	}
+
+	for _, tt := range tests {
+		profile, ok := LookupProfile(tt.profile)
+		if !ok {
+			t.Fatalf("no %s profile", tt.profile)
+		}
+		runner := &fakeRunner{}
+		if err := NewFFmpegEncoder("ffmpeg", runner).Transcode(context.Background(), "in.mp4", "out.mp4", profile); err != nil {
+			t.Fatalf("Transcode %s: %v", tt.profile, err)
+		}
+		command := runner.command(t)
+		assertArgs(t, command, tt.want...)
+		assertArgs(t, command, "-i in.mp4", "-movflags +faststart", "-f mp4 out.mp4")
+	}
+}
+
+func TestFFmpegEncoderPackageVariant(t *testing.T) {
+	runner := &fakeRunner{}
+	dir := filepath.Join("work", "720p")
+
+	if err := NewFFmpegEncoder("ffmpeg", runner).PackageVariant(context.Background(), "in.mp4", dir, HLSVariants[1]); err != nil {
+		t.Fatalf("PackageVariant: %v", err)
+	}
+	command := runner.command(t)
+	assertArgs(t, command, "-b:v 2800k", "-f hls", "-hls_time 6",
+		"-hls_segment_filename "+filepath.Join(dir, "seg_%04d.ts"))
+	if !strings.HasSuffix(command, " "+filepath.Join(dir, HLSPlaylist)) {
+		t.Errorf("command %q does not write the playlist last", command)
+	}
+}
+
+func TestFFmpegAdaptersReturnRunnerErrors(t *testing.T) {
+	failure := errors.New("exit status 1")
+	runner := &fakeRunner{err: failure}
+
+	if err := NewFFmpegExtractor("ffmpeg", runner).Poster(context.Background(), "in.mp4", "out.jpg", 0, 320); !errors.Is(err, failure) {
+		t.Errorf("Poster error = %v, want %v", err, failure)
+	}
+	if err := NewFFmpegEncoder("ffmpeg", runner).Transcode(context.Background(), "in.mp4", "out.mp4", Profiles[0]); !errors.Is(err, failure) {
+		t.Errorf("Transcode error = %v, want %v", err, failure)
+	}
+}
+
+func TestInitMediaWithoutFFmpeg(t *testing.T) {
+	config.AppConfig = &config.Config{Media: config.MediaConfig{
+		FFmpegPath: filepath.Join(t.TempDir(), "ffmpeg"),
+	}}
+
+	InitMedia()
+	if Available {
+		t.Fatal("Available with a missing ffmpeg")
+	}
+
+	err := Frames.Poster(context.Background(), "in.mp4", "out.jpg", 0, 320)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Poster error = %v, want ErrUnavailable", err)
+	}
+	err = Transcoder.Transcode(context.Background(), "in.mp4", "out.mp4", Profiles[0])
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Transcode error = %v, want ErrUnavailable", err)
+	}
+	err = HLS.PackageVariant(context.Background(), "in.mp4", "out", HLSVariants[0])
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("PackageVariant error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestInitMediaFindsFFmpeg(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatalf("write fake ffmpeg: %v", err)
+	}
+	config.AppConfig = &config.Config{Media: config.MediaConfig{FFmpegPath: binary}}
+
+	InitMedia()
+	if !Available {
+		t.Fatalf("ffmpeg at %s was not found", binary)
+	}
+}
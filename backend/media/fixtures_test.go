@@ -0,0 +1,333 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateFixtures = flag.Bool("update", false, "rewrite the media fixtures in testdata")
+
+// fixtureCreated is the creation time stored in the fixtures
+var fixtureCreated = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// mediaFixtures builds the files kept under testdata. They are written by
+// hand rather than by an encoder so that every header value is known.
+func mediaFixtures() map[string][]byte {
+	return map[string][]byte{
+		"h264_rotated.mp4":   h264RotatedMP4(),
+		"hevc.mov":           hevcMOV(),
+		"fragmented.mp4":     fragmentedMP4(),
+		"vp9.webm":           vp9WebM(),
+		"mediarecorder.webm": mediaRecorderWebM(3, 100),
+	}
+}
+
+// TestFixtures keeps testdata in step with the builders below; run
+// go test ./media -run TestFixtures -update to regenerate the files
+func TestFixtures(t *testing.T) {
+	for name, data := range mediaFixtures() {
+		path := filepath.Join("testdata", name)
+		if *updateFixtures {
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		stored, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stored, data) {
+			t.Errorf("%s is out of date; run go test ./media -run TestFixtures -update", path)
+		}
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// ISO base media boxes
+
+func box(boxType string, parts ...[]byte) []byte {
+	payload := concat(parts...)
+	return concat(u32(uint32(8+len(payload))), []byte(boxType), payload)
+}
+
+// fullBox prefixes the payload with a version and zero flags
+func fullBox(boxType string, version byte, parts ...[]byte) []byte {
+	return box(boxType, append([]byte{version, 0, 0, 0}, concat(parts...)...))
+}
+
+func ftyp(major string, compatible ...string) []byte {
+	parts := [][]byte{[]byte(major), u32(0)}
+	for _, brand := range compatible {
+		parts = append(parts, []byte(brand))
+	}
+	return box("ftyp", parts...)
+}
+
+func mp4Seconds(t time.Time) uint64 {
+	return uint64(t.Sub(mp4Epoch) / time.Second)
+}
+
+func mvhd(version byte, timescale uint32, duration uint64) []byte {
+	created := mp4Seconds(fixtureCreated)
+	tail := concat(u32(0x00010000), u16(0x0100), make([]byte, 10), identityMatrix(), make([]byte, 24), u32(3))
+	if version == 1 {
+		return fullBox("mvhd", 1, u64(created), u64(created), u32(timescale), u64(duration), tail)
+	}
+	return fullBox("mvhd", 0, u32(uint32(created)), u32(uint32(created)), u32(timescale), u32(uint32(duration)), tail)
+}
+
+func identityMatrix() []byte {
+	return rotationMatrix(0)
+}
+
+// rotationMatrix is the display matrix for a clockwise rotation
+func rotationMatrix(degrees int) []byte {
+	radians := float64(degrees) * math.Pi / 180
+	fixed := func(v float64) []byte { return u32(uint32(int32(math.Round(v * 65536)))) }
+	cos, sin := math.Cos(radians), math.Sin(radians)
+	return concat(fixed(cos), fixed(sin), u32(0), fixed(-sin), fixed(cos), u32(0), u32(0), u32(0), u32(0x40000000))
+}
+
+func tkhd(version byte, trackID uint32, rotation, width, height int) []byte {
+	var times []byte
+	if version == 1 {
+		times = concat(u64(0), u64(0), u32(trackID), u32(0), u64(0))
+	} else {
+		times = concat(u32(0), u32(0), u32(trackID), u32(0), u32(0))
+	}
+	return fullBox("tkhd", version, times, make([]byte, 8), u16(0), u16(0), u16(0), u16(0),
+		rotationMatrix(rotation), u32(uint32(width)<<16), u32(uint32(height)<<16))
+}
+
+func mdhd(version byte, timescale uint32, duration uint64) []byte {
+	if version == 1 {
+		return fullBox("mdhd", 1, u64(0), u64(0), u32(timescale), u64(duration), u16(0x55C4), u16(0))
+	}
+	return fullBox("mdhd", 0, u32(0), u32(0), u32(timescale), u32(uint32(duration)), u16(0x55C4), u16(0))
+}
+
+func hdlr(handler string) []byte {
+	return fullBox("hdlr", 0, u32(0), []byte(handler), make([]byte, 12), []byte("fixture\x00"))
+}
+
+// trak builds a track with a single sample entry and one stts run
+func trak(header []byte, mediaHeader []byte, handler, fourcc string, samples, delta uint32) []byte {
+	stbl := box("stbl",
+		fullBox("stsd", 0, u32(1), box(fourcc, make([]byte, 78))),
+		fullBox("stts", 0, u32(1), u32(samples), u32(delta)),
+	)
+	return box("trak", header, box("mdia", mediaHeader, hdlr(handler), box("minf", stbl)))
+}
+
+// h264RotatedMP4 is a two second 1920x1080 portrait phone recording at
+// 30 fps with AAC audio
+func h264RotatedMP4() []byte {
+	return concat(
+		ftyp("isom", "isom", "iso2", "avc1", "mp41"),
+		box("moov",
+			mvhd(0, 1000, 2000),
+			trak(tkhd(0, 1, 90, 1920, 1080), mdhd(0, 30000, 60000), "vide", "avc1", 60, 1000),
+			trak(tkhd(0, 2, 0, 0, 0), mdhd(0, 48000, 96000), "soun", "mp4a", 94, 1024),
+		),
+		box("mdat", make([]byte, 256)),
+	)
+}
+
+// hevcMOV is a five second 1280x720 QuickTime file at 25 fps using the
+// 64-bit header versions and no audio
+func hevcMOV() []byte {
+	return concat(
+		ftyp("qt  ", "qt  "),
+		box("moov",
+			mvhd(1, 600, 3000),
+			trak(tkhd(1, 1, 180, 1280, 720), mdhd(1, 600, 3000), "vide", "hvc1", 125, 24),
+		),
+		box("mdat", make([]byte, 256)),
+	)
+}
+
+// fragmentedMP4 keeps its duration in mvex/mehd and has no samples in moov
+func fragmentedMP4() []byte {
+	return concat(
+		ftyp("iso6", "iso6", "dash"),
+		box("moov",
+			mvhd(0, 1000, 0),
+			box("mvex", fullBox("mehd", 0, u32(7500))),
+			trak(tkhd(0, 1, 270, 640, 480), mdhd(0, 90000, 0), "vide", "vp09", 0, 0),
+			trak(tkhd(0, 2, 0, 0, 0), mdhd(0, 48000, 0), "soun", "Opus", 0, 0),
+		),
+		box("moof", box("mfhd", u32(0), u32(1))),
+		box("mdat", make([]byte, 256)),
+	)
+}
+
+// Matroska elements
+
+func ebmlID(id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return u32(id)
+	case id > 0xFFFF:
+		return u32(id)[1:]
+	case id > 0xFF:
+		return u16(uint16(id))
+	}
+	return []byte{byte(id)}
+}
+
+func ebmlSize(size int) []byte {
+	switch {
+	case size < 0x7F:
+		return []byte{0x80 | byte(size)}
+	case size < 0x3FFF:
+		return []byte{0x40 | byte(size>>8), byte(size)}
+	case size < 0x1FFFFF:
+		return []byte{0x20 | byte(size>>16), byte(size >> 8), byte(size)}
+	}
+	return u32(0x10000000 | uint32(size))
+}
+
+// unknownSize marks a master element whose end is not known, as written by
+// streaming muxers such as the browser's MediaRecorder
+var unknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+func element(id uint32, parts ...[]byte) []byte {
+	payload := concat(parts...)
+	return concat(ebmlID(id), ebmlSize(len(payload)), payload)
+}
+
+func unknownElement(id uint32, parts ...[]byte) []byte {
+	return concat(ebmlID(id), unknownSize, concat(parts...))
+}
+
+func ebmlUint(v uint64) []byte {
+	data := u64(v)
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	return data
+}
+
+func ebmlFloat(v float64) []byte {
+	return u64(math.Float64bits(v))
+}
+
+func ebmlHeader(docType string) []byte {
+	return element(idEBML,
+		element(0x4286, ebmlUint(1)),
+		element(0x42F7, ebmlUint(1)),
+		element(0x42F2, ebmlUint(4)),
+		element(0x42F3, ebmlUint(8)),
+		element(idDocType, []byte(docType)),
+		element(0x4287, ebmlUint(4)),
+		element(0x4285, ebmlUint(2)),
+	)
+}
+
+func simpleBlock(track byte, timecode int16, payload int) []byte {
+	return element(idSimpleBlock, []byte{0x80 | track}, u16(uint16(timecode)), []byte{0x80}, make([]byte, payload))
+}
+
+// vp9WebM is a 4.5 second 640x360 file at 30 fps whose header carries the
+// duration and frame rate, with a projection rolled a quarter turn
+func vp9WebM() []byte {
+	created := uint64(fixtureCreated.Sub(matroskaEpoch))
+	return concat(
+		ebmlHeader("webm"),
+		element(idSegment,
+			element(idInfo,
+				element(idTimecodeScale, ebmlUint(1000000)),
+				element(idDuration, ebmlFloat(4500)),
+				element(idDateUTC, u64(created)),
+			),
+			element(idTracks,
+				element(idTrackEntry,
+					element(idTrackNumber, ebmlUint(1)),
+					element(idTrackType, ebmlUint(1)),
+					element(idCodecID, []byte("V_VP9")),
+					element(idDefaultDuration, ebmlUint(33333333)),
+					element(idVideo,
+						element(idPixelWidth, ebmlUint(640)),
+						element(idPixelHeight, ebmlUint(360)),
+						element(idProjection, element(idProjectionRoll, ebmlFloat(-90))),
+					),
+				),
+				element(idTrackEntry,
+					element(idTrackNumber, ebmlUint(2)),
+					element(idTrackType, ebmlUint(2)),
+					element(idCodecID, []byte("A_OPUS")),
+				),
+			),
+			element(idCluster,
+				element(idTimecode, ebmlUint(0)),
+				simpleBlock(1, 0, 64),
+				simpleBlock(2, 0, 16),
+			),
+		),
+	)
+}
+
+// mediaRecorderWebM mimics a browser recording: unknown-size segment and
+// clusters, no duration or default frame duration, one second clusters of
+// 25 fps VP8 video with frames of the given size and an Opus block each
+func mediaRecorderWebM(seconds, frameSize int) []byte {
+	var clusters [][]byte
+	for second := 0; second < seconds; second++ {
+		blocks := [][]byte{element(idTimecode, ebmlUint(uint64(second*1000))), simpleBlock(2, 0, 16)}
+		for frame := 0; frame < 25; frame++ {
+			blocks = append(blocks, simpleBlock(1, int16(frame*40), frameSize))
+		}
+		clusters = append(clusters, unknownElement(idCluster, blocks...))
+	}
+
+	return concat(
+		ebmlHeader("webm"),
+		unknownElement(idSegment,
+			element(idInfo,
+				element(idTimecodeScale, ebmlUint(1000000)),
+				element(0x4D80, []byte("Chrome")),
+			),
+			element(idTracks,
+				element(idTrackEntry,
+					element(idTrackNumber, ebmlUint(1)),
+					element(idTrackType, ebmlUint(1)),
+					element(idCodecID, []byte("V_VP8")),
+					element(idVideo,
+						element(idPixelWidth, ebmlUint(1280)),
+						element(idPixelHeight, ebmlUint(720)),
+					),
+				),
+				element(idTrackEntry,
+					element(idTrackNumber, ebmlUint(2)),
+					element(idTrackType, ebmlUint(2)),
+					element(idCodecID, []byte("A_OPUS")),
+				),
+			),
+			concat(clusters...),
+		),
+	)
+}
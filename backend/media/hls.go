@@ -0,0 +1,138 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HLS content types
+const (
+	TypeHLSPlaylist = "application/vnd.apple.mpegurl"
+	TypeHLSSegment  = "video/mp2t"
+)
+
+// HLSPlaylist is the name of every playlist in an HLS package
+const HLSPlaylist = "index.m3u8"
+
+// hlsSegmentSeconds is the target segment length; short segments make
+// seeking on mobile connections quick
+const hlsSegmentSeconds = 6
+
+// HLSVariants are the bitrate ladder offered for adaptive streaming
+var HLSVariants = []Profile{
+	{Name: "360p", MaxHeight: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+	{Name: "720p", MaxHeight: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+	{Name: "1080p", MaxHeight: 1080, VideoBitrate: "5000k", AudioBitrate: "192k"},
+}
+
+// Packager segments a video into an HLS variant stream
+type Packager interface {
+	// PackageVariant writes variant's playlist (HLSPlaylist) and MPEG-TS
+	// segments into dir
+	PackageVariant(ctx context.Context, input, dir string, variant Profile) error
+}
+
+// HLS is the packager used by the application
+var HLS Packager
+
+func (e *FFmpegEncoder) PackageVariant(ctx context.Context, input, dir string, variant Profile) error {
+	return e.Runner.Run(ctx, e.Binary,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", variant.MaxHeight),
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		"-b:v", variant.VideoBitrate, "-maxrate", variant.VideoBitrate, "-bufsize", doubleRate(variant.VideoBitrate),
+		// Fixed two second GOPs so every segment starts on a keyframe
+		"-force_key_frames", "expr:gte(t,n_forced*2)", "-sc_threshold", "0",
+		"-c:a", "aac", "-b:a", variant.AudioBitrate, "-ac", "2",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(dir, "seg_%04d.ts"),
+		filepath.Join(dir, HLSPlaylist),
+	)
+}
+
+// HLSLadder picks the variants worth producing for a source of the given
+// upright height: every rung up to the source height, and always the
+// lowest one
+func HLSLadder(height int) []Profile {
+	ladder := []Profile{HLSVariants[0]}
+	for _, variant := range HLSVariants[1:] {
+		if height <= 0 || variant.MaxHeight > height {
+			break
+		}
+		ladder = append(ladder, variant)
+	}
+	return ladder
+}
+
+// HLSStream describes one variant listed in a master playlist
+type HLSStream struct {
+	Profile Profile
+	Width   int
+	Height  int
+}
+
+// MasterPlaylist builds the master playlist referencing each variant's
+// playlist at "<name>/index.m3u8"
+func MasterPlaylist(streams []HLSStream) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+
+	for _, stream := range streams {
+		bandwidth := bitsPerSecond(stream.Profile.VideoBitrate) + bitsPerSecond(stream.Profile.AudioBitrate)
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", bandwidth)
+		if stream.Width > 0 && stream.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", stream.Width, stream.Height)
+		}
+		b.WriteString(",CODECS=\"avc1.640028,mp4a.40.2\"\n")
+		fmt.Fprintf(&b, "%s/%s\n", stream.Profile.Name, HLSPlaylist)
+	}
+
+	return b.String()
+}
+
+// SignPlaylist appends query to every URI in a playlist
+func SignPlaylist(playlist, query string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		uri := strings.TrimSpace(line)
+		if uri == "" || strings.HasPrefix(uri, "#") {
+			continue
+		}
+		separator := "?"
+		if strings.Contains(uri, "?") {
+			separator = "&"
+		}
+		lines[i] = uri + separator + query
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bitsPerSecond parses rates such as "800k" or "5M"
+func bitsPerSecond(rate string) int {
+	if rate == "" {
+		return 0
+	}
+
+	multiplier := 1
+	switch rate[len(rate)-1] {
+	case 'k', 'K':
+		multiplier = 1000
+		rate = rate[:len(rate)-1]
+	case 'm', 'M':
+		multiplier = 1000000
+		rate = rate[:len(rate)-1]
+	}
+
+	value, err := strconv.Atoi(rate)
+	if err != nil {
+		return 0
+	}
+	return value * multiplier
+}
@@ -0,0 +1,401 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// maxMoovSize bounds how much of an MP4 movie header is read into memory
+const maxMoovSize = 64 << 20
+
+// mp4Epoch is the reference time for ISO base media timestamps
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var errMalformedMP4 = errors.New("media: malformed mp4 box")
+
+var isoBoxTypes = map[string]bool{
+	"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true, "pnot": true,
+}
+
+func isISOBoxType(boxType []byte) bool {
+	return isoBoxTypes[string(boxType)]
+}
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264", "avc3": "h264",
+	"hvc1": "hevc", "hev1": "hevc",
+	"vp08": "vp8", "vp09": "vp9",
+	"av01": "av1",
+	"mp4v": "mpeg4",
+	"s263": "h263",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"ac-3": "ac3", "ec-3": "eac3",
+	"samr": "amr_nb", "sawb": "amr_wb",
+	"alac": "alac",
+	"lpcm": "pcm", "sowt": "pcm", "twos": "pcm",
+}
+
+type mp4Track struct {
+	handler     string
+	codec       string
+	width       int
+	height      int
+	rotation    int
+	timescale   uint32
+	duration    uint64
+	sampleCount uint64
+}
+
+// probeMP4 walks the top-level boxes to find ftyp and moov
+func probeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Container: "mp4"}
+	var moov []byte
+
+	var offset int64
+	for offset+8 <= size {
+		header := make([]byte, 16)
+		n, err := r.ReadAt(header, offset)
+		if n < 8 {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if n < 16 {
+				return nil, errMalformedMP4
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > size {
+			// Truncated trailing box (e.g. a recording that was cut off)
+			if boxType != "moov" {
+				break
+			}
+			return nil, errMalformedMP4
+		}
+
+		switch boxType {
+		case "ftyp":
+			brand := make([]byte, 4)
+			if _, err := r.ReadAt(brand, offset+headerSize); err == nil && string(brand) == "qt  " {
+				info.Container = "quicktime"
+			}
+		case "moov":
+			if boxSize-headerSize > maxMoovSize {
+				return nil, errMalformedMP4
+			}
+			moov = make([]byte, boxSize-headerSize)
+			if _, err := r.ReadAt(moov, offset+headerSize); err != nil {
+				return nil, err
+			}
+		}
+
+		offset += boxSize
+	}
+
+	if moov == nil {
+		return nil, errors.New("media: mp4 has no moov box")
+	}
+
+	if err := parseMoov(moov, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// mp4Box is a child box inside an in-memory parent
+type mp4Box struct {
+	boxType string
+	data    []byte
+}
+
+// mp4Children splits data into its child boxes
+func mp4Children(data []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		boxType := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, errMalformedMP4
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return nil, errMalformedMP4
+		}
+
+		boxes = append(boxes, mp4Box{boxType: boxType, data: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func parseMoov(moov []byte, info *Info) error {
+	boxes, err := mp4Children(moov)
+	if err != nil {
+		return err
+	}
+
+	var tracks []mp4Track
+	var timescale uint32
+	for _, box := range boxes {
+		switch box.boxType {
+		case "mvhd":
+			timescale = parseMvhd(box.data, info)
+		case "mvex":
+			// Fragmented files keep the overall duration in mvex/mehd
+			if info.Duration == 0 && timescale > 0 {
+				parseMvex(box.data, timescale, info)
+			}
+		case "trak":
+			track, err := parseTrak(box.data)
+			if err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+		}
+	}
+
+	for _, track := range tracks {
+		switch track.handler {
+		case "vide":
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = track.codec
+			info.Width = track.width
+			info.Height = track.height
+			info.Rotation = track.rotation
+			if track.timescale > 0 && track.duration > 0 && track.sampleCount > 0 {
+				seconds := float64(track.duration) / float64(track.timescale)
+				info.FrameRate = roundTo(float64(track.sampleCount)/seconds, 3)
+				if info.Duration == 0 {
+					info.Duration = roundTo(seconds, 3)
+				}
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = track.codec
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseMvhd reads the movie header and returns the movie timescale
+func parseMvhd(data []byte, info *Info) uint32 {
+	if len(data) < 4 {
+		return 0
+	}
+
+	var creation, duration uint64
+	var timescale uint32
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0
+		}
+		creation = binary.BigEndian.Uint64(data[4:12])
+		timescale = binary.BigEndian.Uint32(data[20:24])
+		duration = binary.BigEndian.Uint64(data[24:32])
+	} else {
+		if len(data) < 20 {
+			return 0
+		}
+		creation = uint64(binary.BigEndian.Uint32(data[4:8]))
+		timescale = binary.BigEndian.Uint32(data[12:16])
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	}
+
+	if creation > 0 {
+		created := mp4Epoch.Add(time.Duration(creation) * time.Second)
+		info.CreationTime = &created
+	}
+	if timescale > 0 {
+		info.Duration = roundTo(float64(duration)/float64(timescale), 3)
+	}
+	return timescale
+}
+
+func parseMvex(data []byte, timescale uint32, info *Info) {
+	boxes, err := mp4Children(data)
+	if err != nil {
+		return
+	}
+
+	for _, box := range boxes {
+		if box.boxType != "mehd" || len(box.data) < 8 {
+			continue
+		}
+
+		duration := uint64(binary.BigEndian.Uint32(box.data[4:8]))
+		if box.data[0] == 1 && len(box.data) >= 12 {
+			duration = binary.BigEndian.Uint64(box.data[4:12])
+		}
+		info.Duration = roundTo(float64(duration)/float64(timescale), 3)
+	}
+}
+
+func parseTrak(data []byte) (mp4Track, error) {
+	var track mp4Track
+
+	boxes, err := mp4Children(data)
+	if err != nil {
+		return track, err
+	}
+
+	for _, box := range boxes {
+		switch box.boxType {
+		case "tkhd":
+			parseTkhd(box.data, &track)
+		case "mdia":
+			if err := parseMdia(box.data, &track); err != nil {
+				return track, err
+			}
+		}
+	}
+
+	return track, nil
+}
+
+func parseTkhd(data []byte, track *mp4Track) {
+	// version+flags, times, track ID, reserved and duration precede the
+	// fixed 52 byte tail holding the matrix and dimensions
+	offset := 4 + 20
+	if len(data) > 0 && data[0] == 1 {
+		offset = 4 + 32
+	}
+	offset += 8 + 2 + 2 + 2 + 2 // reserved, layer, alternate group, volume, reserved
+	if len(data) < offset+36+8 {
+		return
+	}
+
+	matrix := data[offset : offset+36]
+	a := float64(int32(binary.BigEndian.Uint32(matrix[0:4]))) / 65536
+	b := float64(int32(binary.BigEndian.Uint32(matrix[4:8]))) / 65536
+	track.rotation = normalizeRotation(math.Atan2(b, a) * 180 / math.Pi)
+
+	track.width = int(binary.BigEndian.Uint32(data[offset+36:offset+40]) >> 16)
+	track.height = int(binary.BigEndian.Uint32(data[offset+40:offset+44]) >> 16)
+}
+
+func parseMdia(data []byte, track *mp4Track) error {
+	boxes, err := mp4Children(data)
+	if err != nil {
+		return err
+	}
+
+	for _, box := range boxes {
+		switch box.boxType {
+		case "mdhd":
+			parseMdhd(box.data, track)
+		case "hdlr":
+			if len(box.data) >= 12 {
+				track.handler = string(box.data[8:12])
+			}
+		case "minf":
+			minf, err := mp4Children(box.data)
+			if err != nil {
+				return err
+			}
+			for _, child := range minf {
+				if child.boxType == "stbl" {
+					if err := parseStbl(child.data, track); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseMdhd(data []byte, track *mp4Track) {
+	if len(data) < 4 {
+		return
+	}
+
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return
+		}
+		track.timescale = binary.BigEndian.Uint32(data[20:24])
+		track.duration = binary.BigEndian.Uint64(data[24:32])
+		return
+	}
+
+	if len(data) < 20 {
+		return
+	}
+	track.timescale = binary.BigEndian.Uint32(data[12:16])
+	track.duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+}
+
+func parseStbl(data []byte, track *mp4Track) error {
+	boxes, err := mp4Children(data)
+	if err != nil {
+		return err
+	}
+
+	for _, box := range boxes {
+		switch box.boxType {
+		case "stsd":
+			// version+flags and entry count, then the first sample entry
+			if len(box.data) < 16 {
+				continue
+			}
+			fourcc := string(box.data[12:16])
+			if codec, ok := mp4Codecs[fourcc]; ok {
+				track.codec = codec
+			} else {
+				track.codec = fourcc
+			}
+		case "stts":
+			if len(box.data) < 8 {
+				continue
+			}
+			entries := binary.BigEndian.Uint32(box.data[4:8])
+			for i := uint32(0); i < entries; i++ {
+				start := 8 + int(i)*8
+				if start+8 > len(box.data) {
+					break
+				}
+				track.sampleCount += uint64(binary.BigEndian.Uint32(box.data[start : start+4]))
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizeRotation snaps an angle to the nearest quarter turn in [0, 360)
+func normalizeRotation(degrees float64) int {
+	rotation := int(math.Round(degrees/90)) * 90
+	return ((rotation % 360) + 360) % 360
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,100 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsupported is returned for content that is not a recognised container
+var ErrUnsupported = errors.New("media: unsupported container format")
+
+// Info describes a probed video container
+type Info struct {
+	Container    string     // "mp4", "quicktime", "webm" or "matroska"
+	Duration     float64    // in seconds
+	Width        int        // display width before rotation
+	Height       int        // display height before rotation
+	VideoCodec   string     // e.g. "h264", "vp9"
+	AudioCodec   string     // e.g. "aac", "opus"
+	FrameRate    float64    // frames per second
+	Rotation     int        // clockwise degrees: 0, 90, 180 or 270
+	CreationTime *time.Time // when the recording was made, if stored
+}
+
+// Probe reads container headers from r and extracts duration, resolution,
+// codecs, frame rate, creation time and rotation. It understands ISO base
+// media files (MP4/MOV/3GP) and Matroska/WebM.
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	header := make([]byte, 12)
+	n, err := r.ReadAt(header, 0)
+	if n < len(header) {
+		if err == nil || err == io.EOF {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(header[:4], ebmlMagic):
+		return probeMatroska(r, size)
+	case isISOBoxType(header[4:8]):
+		return probeMP4(r, size)
+	}
+
+	return nil, ErrUnsupported
+}
+
+// windowReader serves small reads from a ReaderAt through a cached window,
+// which keeps sequential header scans from issuing a syscall per element
+type windowReader struct {
+	r      io.ReaderAt
+	size   int64
+	buf    []byte
+	bufOff int64
+}
+
+func newWindowReader(r io.ReaderAt, size int64) *windowReader {
+	return &windowReader{r: r, size: size, bufOff: -1}
+}
+
+// bytesAt returns n bytes at off, or fewer at the end of the input
+func (w *windowReader) bytesAt(off int64, n int) ([]byte, error) {
+	if off >= w.size {
+		return nil, io.EOF
+	}
+	if off+int64(n) > w.size {
+		n = int(w.size - off)
+	}
+
+	if w.bufOff >= 0 && off >= w.bufOff && off+int64(n) <= w.bufOff+int64(len(w.buf)) {
+		start := off - w.bufOff
+		return w.buf[start : start+int64(n)], nil
+	}
+
+	window := 64 * 1024
+	if n > window {
+		window = n
+	}
+	if off+int64(window) > w.size {
+		window = int(w.size - off)
+	}
+
+	if cap(w.buf) < window {
+		w.buf = make([]byte, window)
+	}
+	w.buf = w.buf[:window]
+
+	read, err := w.r.ReadAt(w.buf, off)
+	if read < n {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	w.buf = w.buf[:read]
+	w.bufOff = off
+
+	return w.buf[:n], nil
+}
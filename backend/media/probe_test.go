@@ -0,0 +1,166 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func probeBytes(data []byte) (*Info, error) {
+	return Probe(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Info
+		created bool
+	}{
+		{
+			fixture: "h264_rotated.mp4",
+			want: Info{Container: "mp4", Duration: 2, Width: 1920, Height: 1080, VideoCodec: "h264",
+				AudioCodec: "aac", FrameRate: 30, Rotation: 90},
+			created: true,
+		},
+		{
+			fixture: "hevc.mov",
+			want: Info{Container: "quicktime", Duration: 5, Width: 1280, Height: 720, VideoCodec: "hevc",
+				FrameRate: 25, Rotation: 180},
+			created: true,
+		},
+		{
+			fixture: "fragmented.mp4",
+			want: Info{Container: "mp4", Duration: 7.5, Width: 640, Height: 480, VideoCodec: "vp9",
+				AudioCodec: "opus", Rotation: 270},
+			created: true,
+		},
+		{
+			fixture: "vp9.webm",
+			want: Info{Container: "webm", Duration: 4.5, Width: 640, Height: 360, VideoCodec: "vp9",
+				AudioCodec: "opus", FrameRate: 30, Rotation: 90},
+			created: true,
+		},
+		{
+			// No header duration or frame rate: both come from block timecodes
+			fixture: "mediarecorder.webm",
+			want: Info{Container: "webm", Duration: 3, Width: 1280, Height: 720, VideoCodec: "vp8",
+				AudioCodec: "opus", FrameRate: 25},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			info, err := probeBytes(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+
+			if tt.created {
+				if info.CreationTime == nil || !info.CreationTime.Equal(fixtureCreated) {
+					t.Errorf("creation time = %v, want %v", info.CreationTime, fixtureCreated)
+				}
+			} else if info.CreationTime != nil {
+				t.Errorf("creation time = %v, want none", info.CreationTime)
+			}
+
+			got := *info
+			got.CreationTime = nil
+			if got != tt.want {
+				t.Errorf("Probe = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeTruncated(t *testing.T) {
+	mp4 := readFixture(t, "h264_rotated.mp4")
+	webm := readFixture(t, "mediarecorder.webm")
+
+	// A recording cut off in mdat still has its complete moov
+	info, err := probeBytes(mp4[:len(mp4)-100])
+	if err != nil {
+		t.Fatalf("Probe with truncated mdat: %v", err)
+	}
+	if info.Duration != 2 || info.VideoCodec != "h264" {
+		t.Errorf("Probe with truncated mdat = %+v", info)
+	}
+
+	// Without the end of moov there is nothing to go on
+	moovEnd := bytes.Index(mp4, []byte("mdat")) - 4
+	if _, err := probeBytes(mp4[:moovEnd-10]); err == nil {
+		t.Error("Probe accepted an mp4 with a truncated moov")
+	}
+
+	// An interrupted browser recording is measured up to its last whole block
+	info, err = probeBytes(webm[:len(webm)-30])
+	if err != nil {
+		t.Fatalf("Probe with truncated cluster: %v", err)
+	}
+	if info.Duration != 2.96 || info.FrameRate != 25 {
+		t.Errorf("Probe with truncated cluster: duration %v fps %v, want 2.96 and 25", info.Duration, info.FrameRate)
+	}
+
+	for _, data := range [][]byte{mp4[:8], webm[:4], webm[:40]} {
+		if _, err := probeBytes(data); err == nil {
+			t.Errorf("Probe accepted %d leading bytes", len(data))
+		}
+	}
+}
+
+func TestProbeCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrUnsupported},
+		{"text", []byte("this is not a video, just some text"), ErrUnsupported},
+		{"bad moov child size", concat(ftyp("isom"), box("moov", u32(3), []byte("mvhd"))), errMalformedMP4},
+		{"oversized moov", concat(ftyp("isom"), u32(0xFFFFFFF0), []byte("moov"), make([]byte, 64)), errMalformedMP4},
+		{"no moov", concat(ftyp("isom"), box("mdat", make([]byte, 64))), nil},
+		{"bad ebml element", concat(ebmlHeader("webm"), []byte{0x00, 0x00, 0x00, 0x00}), nil},
+		{"unknown size leaf", concat(ebmlHeader("webm"), unknownElement(idSegment, unknownElement(idTracks))), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := probeBytes(tt.data)
+			if err == nil {
+				t.Fatalf("Probe = %+v, want an error", info)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Probe error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// countingReader tallies how many bytes are read through it
+type countingReader struct {
+	r    *bytes.Reader
+	read atomic.Int64
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func TestProbeLongRecordingSkipsToTail(t *testing.T) {
+	// 24 seconds of 40 KB frames is about 24 MB of clusters
+	data := mediaRecorderWebM(24, 40<<10)
+	reader := &countingReader{r: bytes.NewReader(data)}
+
+	info, err := Probe(reader, int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if info.Duration != 24 || info.FrameRate != 25 {
+		t.Errorf("duration %v fps %v, want 24 and 25", info.Duration, info.FrameRate)
+	}
+	if read := reader.read.Load(); read > int64(len(data))/2 {
+		t.Errorf("read %d of %d bytes, want the cluster scan capped", read, len(data))
+	}
+}
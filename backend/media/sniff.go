@@ -0,0 +1,159 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotVideo is returned for content that is not a recognised video file
+var ErrNotVideo = errors.New("media: content is not a supported video")
+
+// SniffLength is how much leading content Sniff needs to decide on a type
+const SniffLength = 4096
+
+// Content types detected by Sniff
+const (
+	TypeMP4       = "video/mp4"
+	TypeQuickTime = "video/quicktime"
+	Type3GPP      = "video/3gpp"
+	Type3GPP2     = "video/3gpp2"
+	TypeWebM      = "video/webm"
+	TypeMatroska  = "video/x-matroska"
+	TypeAVI       = "video/x-msvideo"
+	TypeMPEGTS    = "video/mp2t"
+	TypeMPEG      = "video/mpeg"
+)
+
+var extensions = map[string]string{
+	TypeMP4:       ".mp4",
+	TypeQuickTime: ".mov",
+	Type3GPP:      ".3gp",
+	Type3GPP2:     ".3g2",
+	TypeWebM:      ".webm",
+	TypeMatroska:  ".mkv",
+	TypeAVI:       ".avi",
+	TypeMPEGTS:    ".ts",
+	TypeMPEG:      ".mpg",
+}
+
+// Extension returns the file extension for a content type detected by
+// Sniff, or "" for anything else
+func Extension(contentType string) string {
+	return extensions[contentType]
+}
+
+// ISO base media brands that identify audio-only or still image files
+var nonVideoBrands = map[string]bool{
+	"M4A ": true, "M4B ": true, "M4P ": true, "F4A ": true, "F4B ": true,
+	"heic": true, "heix": true, "mif1": true, "msf1": true, "avif": true,
+}
+
+// Sniff identifies a video container from its leading magic bytes and
+// returns its MIME type. Content that is not a video container, including
+// audio-only MP4 and image formats sharing the ISO box layout, yields
+// ErrNotVideo.
+func Sniff(r io.ReaderAt, size int64) (string, error) {
+	reader := newWindowReader(r, size)
+	header, err := reader.bytesAt(0, SniffLength)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	switch {
+	case len(header) >= 4 && bytes.Equal(header[:4], ebmlMagic):
+		return sniffMatroska(reader, size)
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return sniffFtyp(header)
+	case len(header) >= 8 && isISOBoxType(header[4:8]):
+		// QuickTime files written before ftyp existed start with moov/mdat
+		return TypeQuickTime, nil
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return TypeAVI, nil
+	case len(header) >= 4 && bytes.Equal(header[:4], []byte{0x00, 0x00, 0x01, 0xBA}):
+		return TypeMPEG, nil
+	case len(header) > 376 && header[0] == 0x47 && header[188] == 0x47 && header[376] == 0x47:
+		return TypeMPEGTS, nil
+	}
+
+	return "", ErrNotVideo
+}
+
+// sniffFtyp picks a type from the ftyp major brand, falling back to the
+// compatible brands when the major brand is not decisive
+func sniffFtyp(header []byte) (string, error) {
+	boxSize := int(uint32(header[0])<<24 | uint32(header[1])<<16 | uint32(header[2])<<8 | uint32(header[3]))
+	if boxSize < 16 || boxSize > len(header) {
+		boxSize = len(header)
+	}
+
+	major := string(header[8:12])
+	if nonVideoBrands[major] {
+		return "", fmt.Errorf("%w: %q brand", ErrNotVideo, strings.TrimSpace(major))
+	}
+
+	brands := []string{major}
+	for offset := 16; offset+4 <= boxSize; offset += 4 {
+		brands = append(brands, string(header[offset:offset+4]))
+	}
+
+	for _, brand := range brands {
+		switch {
+		case brand == "qt  ":
+			return TypeQuickTime, nil
+		case strings.HasPrefix(brand, "3gp"):
+			return Type3GPP, nil
+		case strings.HasPrefix(brand, "3g2"):
+			return Type3GPP2, nil
+		}
+	}
+
+	return TypeMP4, nil
+}
+
+// sniffMatroska reads the DocType from the EBML header
+func sniffMatroska(reader *windowReader, size int64) (string, error) {
+	headerSize, sizeLen, _, err := readElementSize(reader, 4)
+	if err != nil {
+		return "", ErrNotVideo
+	}
+
+	offset := int64(4 + sizeLen)
+	end := offset + int64(headerSize)
+	if end > size {
+		end = size
+	}
+
+	for offset < end {
+		id, idLen, err := readElementID(reader, offset)
+		if err != nil {
+			break
+		}
+		dataSize, dataLen, _, err := readElementSize(reader, offset+int64(idLen))
+		if err != nil {
+			break
+		}
+		dataStart := offset + int64(idLen+dataLen)
+
+		if id == idDocType && dataSize <= 64 {
+			docType, err := reader.bytesAt(dataStart, int(dataSize))
+			if err != nil {
+				break
+			}
+			switch strings.TrimRight(string(docType), "\x00") {
+			case "webm":
+				return TypeWebM, nil
+			case "matroska":
+				return TypeMatroska, nil
+			}
+			return "", fmt.Errorf("%w: %q document", ErrNotVideo, docType)
+		}
+
+		offset = dataStart + int64(dataSize)
+	}
+
+	// Matroska is the default when the DocType is missing
+	return TypeMatroska, nil
+}
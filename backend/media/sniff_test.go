@@ -0,0 +1,81 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	transportStream := make([]byte, 188*3)
+	for i := 0; i < len(transportStream); i += 188 {
+		transportStream[i] = 0x47
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"mp4 fixture", readFixture(t, "h264_rotated.mp4"), TypeMP4},
+		{"mov fixture", readFixture(t, "hevc.mov"), TypeQuickTime},
+		{"fragmented mp4 fixture", readFixture(t, "fragmented.mp4"), TypeMP4},
+		{"webm fixture", readFixture(t, "vp9.webm"), TypeWebM},
+		{"mediarecorder fixture", readFixture(t, "mediarecorder.webm"), TypeWebM},
+		{"quicktime compatible brand", ftyp("M4V ", "M4V ", "qt  "), TypeQuickTime},
+		{"3gpp", ftyp("3gp4", "3gp4", "isom"), Type3GPP},
+		{"3gpp2", ftyp("3g2a", "3g2a"), Type3GPP2},
+		{"quicktime without ftyp", box("moov", make([]byte, 32)), TypeQuickTime},
+		{"matroska", concat(ebmlHeader("matroska"), element(idSegment)), TypeMatroska},
+		{"matroska without doctype", element(idEBML, element(0x4286, ebmlUint(1))), TypeMatroska},
+		{"avi", concat([]byte("RIFF"), u32(0), []byte("AVI LIST")), TypeAVI},
+		{"mpeg program stream", []byte{0x00, 0x00, 0x01, 0xBA, 0x44, 0x00}, TypeMPEG},
+		{"mpeg transport stream", append(transportStream, 0x47), TypeMPEGTS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sniff(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Sniff: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Sniff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffRejectsNonVideo(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("hello, this is a text file")},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}},
+		{"m4a audio", ftyp("M4A ", "M4A ", "mp42", "isom")},
+		{"heic image", ftyp("heic", "mif1", "heic")},
+		{"other ebml document", concat(ebmlHeader("notwebm"), element(idSegment))},
+		{"truncated ebml header", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x00}},
+		{"wav audio", concat([]byte("RIFF"), u32(0), []byte("WAVEfmt "))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sniff(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, ErrNotVideo) {
+				t.Errorf("Sniff = %q, %v; want ErrNotVideo", got, err)
+			}
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	if got := Extension(TypeWebM); got != ".webm" {
+		t.Errorf("Extension(webm) = %q", got)
+	}
+	if got := Extension("text/plain"); got != "" {
+		t.Errorf("Extension(text/plain) = %q, want none", got)
+	}
+}
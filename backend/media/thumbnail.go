@@ -0,0 +1,105 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+
+	"trialuploadhk/backend/config"
+)
+
+// Extractor grabs still frames from a video file on local disk
+type Extractor interface {
+	// Poster writes the frame at the given offset in seconds to output as
+	// a JPEG scaled to width
+	Poster(ctx context.Context, input, output string, at float64, width int) error
+	// Sprite writes frames spread evenly over duration to output as a
+	// single horizontal JPEG strip, each frame scaled to width
+	Sprite(ctx context.Context, input, output string, duration float64, frames, width int) error
+}
+
+// Frames is the extractor used by the application
+var Frames Extractor
+
+// Available reports whether InitMedia found ffmpeg. Without it no
+// thumbnail, transcode or HLS jobs are queued.
+var Available bool
+
+// InitMedia sets up the ffmpeg adapters from config.MediaConfig
+func InitMedia() {
+	binary := config.AppConfig.Media.FFmpegPath
+	var runner Runner = ExecRunner{}
+
+	_, err := exec.LookPath(binary)
+	Available = err == nil
+	if !Available {
+		// Jobs queued before ffmpeg went missing fail instead of retrying
+		runner = unavailableRunner{err: err}
+		log.Printf("ffmpeg not found (%v); thumbnails, transcoding and HLS packaging are disabled", err)
+	}
+
+	Frames = NewFFmpegExtractor(binary, runner)
+	encoder := NewFFmpegEncoder(binary, runner)
+	Transcoder = encoder
+	HLS = encoder
+
+	if Available {
+		log.Printf("Media processing initialized: %s", binary)
+	}
+}
+
+// FFmpegExtractor extracts frames by invoking ffmpeg
+type FFmpegExtractor struct {
+	Binary string
+	Runner Runner
+}
+
+// NewFFmpegExtractor returns an extractor that runs binary through runner
+func NewFFmpegExtractor(binary string, runner Runner) *FFmpegExtractor {
+	return &FFmpegExtractor{Binary: binary, Runner: runner}
+}
+
+func (e *FFmpegExtractor) Poster(ctx context.Context, input, output string, at float64, width int) error {
+	return e.Runner.Run(ctx, e.Binary,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-ss", formatSeconds(at),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", width),
+		"-q:v", "3",
+		output,
+	)
+}
+
+func (e *FFmpegExtractor) Sprite(ctx context.Context, input, output string, duration float64, frames, width int) error {
+	if duration <= 0 || frames <= 0 {
+		return fmt.Errorf("media: cannot build a sprite of %d frames over %.3fs", frames, duration)
+	}
+
+	rate := float64(frames) / duration
+	return e.Runner.Run(ctx, e.Binary,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=%s,scale=%d:-2,tile=%dx1", formatSeconds(rate), width, frames),
+		"-frames:v", "1",
+		"-q:v", "4",
+		output,
+	)
+}
+
+// PosterOffset picks the poster frame time: a tenth of the way in, capped
+// at three seconds, which skips black lead-in frames without landing deep
+// into long recordings
+func PosterOffset(duration float64) float64 {
+	offset := duration / 10
+	if offset > 3 {
+		offset = 3
+	}
+	return offset
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
@@ -0,0 +1,133 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Profile describes an H.264/AAC MP4 rendition
+type Profile struct {
+	Name         string
+	MaxHeight    int    // never upscaled
+	CRF          int    // constant quality when VideoBitrate is empty
+	VideoBitrate string // e.g. "400k"; caps the rate for small previews
+	AudioBitrate string
+}
+
+// Profiles are the renditions produced for every video
+var Profiles = []Profile{
+	{Name: "normalized", MaxHeight: 1080, CRF: 23, AudioBitrate: "128k"},
+	{Name: "preview", MaxHeight: 360, VideoBitrate: "400k", AudioBitrate: "64k"},
+}
+
+// LookupProfile returns the profile with the given name
+func LookupProfile(name string) (Profile, bool) {
+	for _, profile := range Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Compatible reports whether a probed source can be served as the profile
+// without re-encoding: an H.264 MP4 with AAC or no audio, no taller than
+// the profile allows
+func (p Profile) Compatible(info *Info) bool {
+	// Bitrate-capped profiles always need encoding
+	if p.VideoBitrate != "" {
+		return false
+	}
+	if info == nil || info.Container != "mp4" || info.VideoCodec != "h264" {
+		return false
+	}
+	if info.AudioCodec != "" && info.AudioCodec != "aac" {
+		return false
+	}
+
+	_, height := UprightSize(info.Width, info.Height, info.Rotation)
+	return height > 0 && height <= p.MaxHeight
+}
+
+// OutputSize returns the upright dimensions of a rendition of a source
+// with the given display size and rotation, matching ffmpeg's scale=-2
+func (p Profile) OutputSize(width, height, rotation int) (int, int) {
+	width, height = UprightSize(width, height, rotation)
+	if width <= 0 || height <= 0 || height <= p.MaxHeight {
+		return width, height
+	}
+
+	scaled := int(math.Round(float64(width)*float64(p.MaxHeight)/float64(height)/2)) * 2
+	return scaled, p.MaxHeight
+}
+
+// UprightSize returns the dimensions of a picture once rotation is applied
+func UprightSize(width, height, rotation int) (int, int) {
+	if rotation == 90 || rotation == 270 {
+		return height, width
+	}
+	return width, height
+}
+
+// Encoder transcodes a video file on local disk
+type Encoder interface {
+	// Transcode writes input re-encoded with profile to output as MP4
+	Transcode(ctx context.Context, input, output string, profile Profile) error
+}
+
+// Transcoder is the encoder used by the application
+var Transcoder Encoder
+
+// FFmpegEncoder encodes by invoking ffmpeg
+type FFmpegEncoder struct {
+	Binary string
+	Runner Runner
+}
+
+// NewFFmpegEncoder returns an encoder that runs binary through runner
+func NewFFmpegEncoder(binary string, runner Runner) *FFmpegEncoder {
+	return &FFmpegEncoder{Binary: binary, Runner: runner}
+}
+
+func (e *FFmpegEncoder) Transcode(ctx context.Context, input, output string, profile Profile) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		// ffmpeg applies the rotation tag while decoding, so the height
+		// limit applies to the upright picture
+		"-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", profile.MaxHeight),
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+	}
+
+	if profile.VideoBitrate != "" {
+		args = append(args, "-b:v", profile.VideoBitrate, "-maxrate", profile.VideoBitrate, "-bufsize", doubleRate(profile.VideoBitrate))
+	} else {
+		args = append(args, "-crf", strconv.Itoa(profile.CRF))
+	}
+
+	args = append(args,
+		"-c:a", "aac", "-b:a", profile.AudioBitrate, "-ac", "2",
+		// Put the index first so playback starts before the download ends
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		output,
+	)
+
+	return e.Runner.Run(ctx, e.Binary, args...)
+}
+
+// doubleRate turns a bitrate such as "400k" into twice its value for the
+// rate control buffer
+func doubleRate(rate string) string {
+	if len(rate) < 2 {
+		return rate
+	}
+	value, err := strconv.Atoi(rate[:len(rate)-1])
+	if err != nil {
+		return rate
+	}
+	return strconv.Itoa(value*2) + rate[len(rate)-1:]
+}
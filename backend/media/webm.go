@@ -0,0 +1,436 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+var ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+// matroskaEpoch is the reference time for Matroska DateUTC values
+var matroskaEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var errMalformedEBML = errors.New("media: malformed ebml element")
+
+// Files without a header duration are measured from their block timecodes.
+// Only the first maxClusterScan bytes of clusters are walked; after that the
+// prober jumps to the last cluster, searching at most maxClusterScan bytes
+// back from the end, so a long browser recording is never read in full.
+const (
+	maxClusterScan   = 8 << 20
+	matroskaTailScan = 1 << 20
+)
+
+// Matroska element IDs used by the prober
+const (
+	idEBML            = 0x1A45DFA3
+	idDocType         = 0x4282
+	idSegment         = 0x18538067
+	idInfo            = 0x1549A966
+	idTimecodeScale   = 0x2AD7B1
+	idDuration        = 0x4489
+	idDateUTC         = 0x4461
+	idTracks          = 0x1654AE6B
+	idTrackEntry      = 0xAE
+	idTrackNumber     = 0xD7
+	idTrackType       = 0x83
+	idCodecID         = 0x86
+	idDefaultDuration = 0x23E383
+	idVideo           = 0xE0
+	idPixelWidth      = 0xB0
+	idPixelHeight     = 0xBA
+	idProjection      = 0x7670
+	idProjectionRoll  = 0x7675
+	idCluster         = 0x1F43B675
+	idTimecode        = 0xE7
+	idBlockGroup      = 0xA0
+	idBlock           = 0xA1
+	idSimpleBlock     = 0xA3
+)
+
+// Master elements whose children are walked in place. Everything else is
+// skipped by size, which also makes unknown-size segments and clusters
+// (as written by browser MediaRecorder) work without special casing.
+var matroskaMasters = map[uint32]bool{
+	idEBML: true, idSegment: true, idInfo: true, idTracks: true, idTrackEntry: true,
+	idVideo: true, idProjection: true, idCluster: true, idBlockGroup: true,
+}
+
+var matroskaCodecs = map[string]string{
+	"V_VP8": "vp8", "V_VP9": "vp9", "V_AV1": "av1",
+	"V_MPEG4/ISO/AVC": "h264", "V_MPEGH/ISO/HEVC": "hevc",
+	"V_MPEG4/ISO/ASP": "mpeg4", "V_THEORA": "theora",
+	"A_OPUS": "opus", "A_VORBIS": "vorbis", "A_AAC": "aac",
+	"A_MPEG/L3": "mp3", "A_PCM/INT/LIT": "pcm", "A_FLAC": "flac",
+}
+
+type matroskaTrack struct {
+	number          uint64
+	trackType       uint64
+	codec           string
+	defaultDuration uint64
+	width           int
+	height          int
+	roll            float64
+}
+
+// probeMatroska walks EBML elements in file order. Cluster timecodes are
+// only scanned when the header lacks a duration or frame rate, which is
+// typical for files recorded in the browser.
+func probeMatroska(r io.ReaderAt, size int64) (*Info, error) {
+	reader := newWindowReader(r, size)
+	info := &Info{Container: "matroska"}
+
+	timecodeScale := uint64(1000000)
+	var duration float64
+	var tracks []*matroskaTrack
+	var current *matroskaTrack
+
+	var clusterTimecode uint64
+	var maxTimecode int64
+	var videoBlocks uint64
+	var firstVideo, lastVideo int64
+	firstCluster := int64(-1)
+	skipped := false
+
+	var offset int64
+	for offset < size {
+		id, idLen, err := readElementID(reader, offset)
+		if err != nil {
+			break
+		}
+		dataSize, sizeLen, unknown, err := readElementSize(reader, offset+int64(idLen))
+		if err != nil {
+			break
+		}
+		dataStart := offset + int64(idLen+sizeLen)
+
+		if matroskaMasters[id] {
+			if id == idTrackEntry {
+				current = &matroskaTrack{}
+				tracks = append(tracks, current)
+			}
+			// The headers are complete once clusters start; blocks only
+			// need scanning when duration or frame rate are still unknown
+			if id == idCluster && duration > 0 && matroskaFrameRate(tracks) > 0 {
+				break
+			}
+			if id == idCluster {
+				if firstCluster < 0 {
+					firstCluster = offset
+				}
+				if !skipped && offset-firstCluster > maxClusterScan {
+					skipped = true
+					last := lastMatroskaCluster(reader, offset, size)
+					if last < 0 {
+						break
+					}
+					offset = last
+					continue
+				}
+			}
+			offset = dataStart
+			continue
+		}
+
+		if unknown {
+			return nil, errMalformedEBML
+		}
+		if dataStart+int64(dataSize) > size {
+			// Truncated element at the end of an interrupted recording
+			break
+		}
+
+		switch id {
+		case idDocType:
+			if value, err := reader.bytesAt(dataStart, int(dataSize)); err == nil {
+				info.Container = string(value)
+			}
+		case idTimecodeScale:
+			timecodeScale = readUint(reader, dataStart, dataSize)
+		case idDuration:
+			duration = readFloat(reader, dataStart, dataSize)
+		case idDateUTC:
+			if dataSize == 8 {
+				ns := int64(readUint(reader, dataStart, dataSize))
+				created := matroskaEpoch.Add(time.Duration(ns))
+				info.CreationTime = &created
+			}
+		case idTrackNumber:
+			if current != nil {
+				current.number = readUint(reader, dataStart, dataSize)
+			}
+		case idTrackType:
+			if current != nil {
+				current.trackType = readUint(reader, dataStart, dataSize)
+			}
+		case idCodecID:
+			if current != nil {
+				if value, err := reader.bytesAt(dataStart, int(dataSize)); err == nil {
+					current.codec = string(value)
+				}
+			}
+		case idDefaultDuration:
+			if current != nil {
+				current.defaultDuration = readUint(reader, dataStart, dataSize)
+			}
+		case idPixelWidth:
+			if current != nil {
+				current.width = int(readUint(reader, dataStart, dataSize))
+			}
+		case idPixelHeight:
+			if current != nil {
+				current.height = int(readUint(reader, dataStart, dataSize))
+			}
+		case idProjectionRoll:
+			if current != nil {
+				current.roll = readFloat(reader, dataStart, dataSize)
+			}
+		case idTimecode:
+			clusterTimecode = readUint(reader, dataStart, dataSize)
+		case idSimpleBlock, idBlock:
+			track, relative, ok := readBlockHeader(reader, dataStart, dataSize)
+			if !ok {
+				break
+			}
+			timecode := int64(clusterTimecode) + int64(relative)
+			if timecode > maxTimecode {
+				maxTimecode = timecode
+			}
+			// Frame rate is measured over the contiguous blocks only
+			if video := matroskaVideoTrack(tracks); !skipped && video != nil && video.number == track {
+				if videoBlocks == 0 || timecode < firstVideo {
+					firstVideo = timecode
+				}
+				if timecode > lastVideo {
+					lastVideo = timecode
+				}
+				videoBlocks++
+			}
+		}
+
+		offset = dataStart + int64(dataSize)
+	}
+
+	// Without a header duration, estimate from the last block plus one
+	// frame interval, since block timecodes mark where frames start
+	scale := float64(timecodeScale) / 1e9
+	var blockFrameRate float64
+	if videoBlocks > 1 && lastVideo > firstVideo {
+		blockFrameRate = float64(videoBlocks-1) / (float64(lastVideo-firstVideo) * scale)
+	}
+	if duration == 0 && maxTimecode > 0 {
+		duration = float64(maxTimecode)
+		if blockFrameRate > 0 {
+			duration += 1 / blockFrameRate / scale
+		}
+	}
+	info.Duration = roundTo(duration*scale, 3)
+
+	for _, track := range tracks {
+		codec, ok := matroskaCodecs[track.codec]
+		if !ok {
+			codec = track.codec
+		}
+
+		switch track.trackType {
+		case 1:
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = codec
+			info.Width = track.width
+			info.Height = track.height
+			info.Rotation = normalizeRotation(-track.roll)
+		case 2:
+			if info.AudioCodec == "" {
+				info.AudioCodec = codec
+			}
+		}
+	}
+
+	if fps := matroskaFrameRate(tracks); fps > 0 {
+		info.FrameRate = fps
+	} else if blockFrameRate > 0 {
+		info.FrameRate = roundTo(blockFrameRate, 3)
+	}
+
+	if info.VideoCodec == "" && info.AudioCodec == "" {
+		return nil, errors.New("media: matroska file has no tracks")
+	}
+
+	return info, nil
+}
+
+// lastMatroskaCluster finds the start of the last cluster after from,
+// reading backwards from the end in matroskaTailScan chunks. A candidate ID
+// only counts when it is followed by a size and the cluster Timecode, since
+// the same bytes can occur inside frames. It returns -1 if none is found.
+func lastMatroskaCluster(reader *windowReader, from, size int64) int64 {
+	clusterID := []byte{0x1F, 0x43, 0xB6, 0x75}
+	limit := size - maxClusterScan
+	if limit < from {
+		limit = from
+	}
+
+	for end := size; end > limit; end -= matroskaTailScan {
+		start := end - matroskaTailScan
+		if start < limit {
+			start = limit
+		}
+		// Overlap the next chunk so an ID on the boundary is not missed
+		readEnd := end + int64(len(clusterID)) - 1
+		if readEnd > size {
+			readEnd = size
+		}
+		chunk, err := reader.bytesAt(start, int(readEnd-start))
+		if err != nil {
+			return -1
+		}
+		// bytesAt may return its cached window, which the checks replace
+		chunk = append([]byte(nil), chunk...)
+
+		for index := len(chunk); index > 0; {
+			index = bytes.LastIndex(chunk[:index], clusterID)
+			if index < 0 {
+				break
+			}
+			offset := start + int64(index)
+			_, sizeLen, _, err := readElementSize(reader, offset+4)
+			if err != nil {
+				continue
+			}
+			if id, _, err := readElementID(reader, offset+4+int64(sizeLen)); err == nil && id == idTimecode {
+				return offset
+			}
+		}
+	}
+	return -1
+}
+
+func matroskaVideoTrack(tracks []*matroskaTrack) *matroskaTrack {
+	for _, track := range tracks {
+		if track.trackType == 1 {
+			return track
+		}
+	}
+	return nil
+}
+
+// matroskaFrameRate derives frames per second from DefaultDuration
+func matroskaFrameRate(tracks []*matroskaTrack) float64 {
+	if video := matroskaVideoTrack(tracks); video != nil && video.defaultDuration > 0 {
+		return roundTo(1e9/float64(video.defaultDuration), 3)
+	}
+	return 0
+}
+
+// readElementID reads an EBML element ID, keeping its length marker bits
+func readElementID(r *windowReader, offset int64) (uint32, int, error) {
+	first, err := r.bytesAt(offset, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length := vintLength(first[0])
+	if length == 0 || length > 4 {
+		return 0, 0, errMalformedEBML
+	}
+
+	data, err := r.bytesAt(offset, length)
+	if err != nil || len(data) < length {
+		return 0, 0, errMalformedEBML
+	}
+
+	var id uint32
+	for _, b := range data {
+		id = id<<8 | uint32(b)
+	}
+	return id, length, nil
+}
+
+// readElementSize reads an EBML data size; all value bits set means unknown
+func readElementSize(r *windowReader, offset int64) (uint64, int, bool, error) {
+	first, err := r.bytesAt(offset, 1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	length := vintLength(first[0])
+	if length == 0 {
+		return 0, 0, false, errMalformedEBML
+	}
+
+	data, err := r.bytesAt(offset, length)
+	if err != nil || len(data) < length {
+		return 0, 0, false, errMalformedEBML
+	}
+
+	value := uint64(data[0] & (0xFF >> length))
+	allOnes := value == uint64(0xFF>>length)
+	for _, b := range data[1:] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return value, length, allOnes, nil
+}
+
+func vintLength(first byte) int {
+	for i := 0; i < 8; i++ {
+		if first&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func readUint(r *windowReader, offset int64, size uint64) uint64 {
+	if size > 8 {
+		return 0
+	}
+	data, err := r.bytesAt(offset, int(size))
+	if err != nil {
+		return 0
+	}
+
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func readFloat(r *windowReader, offset int64, size uint64) float64 {
+	data, err := r.bytesAt(offset, int(size))
+	if err != nil {
+		return 0
+	}
+
+	switch size {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+// readBlockHeader returns the track number and relative timecode of a
+// SimpleBlock or Block
+func readBlockHeader(r *windowReader, offset int64, size uint64) (uint64, int16, bool) {
+	track, length, _, err := readElementSize(r, offset)
+	if err != nil || uint64(length)+2 > size {
+		return 0, 0, false
+	}
+
+	data, err := r.bytesAt(offset+int64(length), 2)
+	if err != nil || len(data) < 2 {
+		return 0, 0, false
+	}
+
+	return track, int16(binary.BigEndian.Uint16(data)), true
+}
@@ -8,7 +8,7 @@ import (
 func CORSMiddleware() gin.HandlerFunc {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"}
 	config.AllowHeaders = []string{
 		"Origin",
 		"Content-Type",
@@ -16,9 +16,19 @@ func CORSMiddleware() gin.HandlerFunc {
 		"Authorization",
 		"X-Requested-With",
 		"Range",
+		"If-Range",
+		"If-Match",
+		"If-None-Match",
+		"If-Modified-Since",
+		"If-Unmodified-Since",
 		"Content-Range",
 		"Accept-Ranges",
 		"Content-Length",
+		"Tus-Resumable",
+		"Upload-Length",
+		"Upload-Offset",
+		"Upload-Metadata",
+		"Upload-Checksum",
 	}
 	config.AllowCredentials = true
 	config.ExposeHeaders = []string{
@@ -26,6 +36,20 @@ func CORSMiddleware() gin.HandlerFunc {
 		"Content-Range",
 		"Accept-Ranges",
 		"Content-Type",
+		"Content-Disposition",
+		"ETag",
+		"Last-Modified",
+		"Location",
+		"Tus-Resumable",
+		"Tus-Version",
+		"Tus-Extension",
+		"Tus-Max-Size",
+		"Tus-Checksum-Algorithm",
+		"Upload-Length",
+		"Upload-Offset",
+		"Upload-Expires",
+		"X-Video-ID",
+		"X-Duplicate-Of",
 	}
 
 	return cors.New(config)
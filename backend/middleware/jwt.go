@@ -6,26 +6,32 @@ import (
 	"time"
 
 	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, username, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // 24 hours
+// GenerateToken issues a short-lived access token for a login session
+func GenerateToken(userID uint, username, role, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(config.AppConfig.JWT.Expiry)
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -63,10 +69,30 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		// Tokens stop working as soon as their session is revoked or the
+		// user is deactivated, and the role is read fresh so changes apply
+		// without logging in again
+		var user models.User
+		err = config.DB.Joins("JOIN sessions ON sessions.user_id = users.id").
+			Where("sessions.id = ? AND users.id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ? AND users.is_active = ?",
+				claims.SessionID, claims.UserID, time.Now(), true).
+			First(&user).Error
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			c.Abort()
+			return
+		}
+
+		SetPrincipal(c, &Principal{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Role:      user.Role,
+			SessionID: claims.SessionID,
+
+			MustChangePassword: user.MustChangePassword,
+
+			TwoFactorSetupRequired: TwoFactorRequired(user.Role) && !user.TwoFactorEnabled,
+		})
 
 		c.Next()
 	}
@@ -74,22 +100,55 @@ func AuthMiddleware() gin.HandlerFunc {
 
 func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
+		principal := CurrentPrincipal(c)
+		if principal.Role == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
 			c.Abort()
 			return
 		}
 
-		roleStr := userRole.(string)
-		for _, role := range roles {
-			if roleStr == role {
-				c.Next()
-				return
-			}
+		if principal.HasRole(roles...) {
+			c.Next()
+			return
 		}
 
 		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 		c.Abort()
 	}
 }
+
+// PasswordChangeMiddleware blocks users who must change their password
+// until they have done so
+func PasswordChangeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentPrincipal(c).MustChangePassword {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password change required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// TwoFactorSetupMiddleware blocks users whose role requires two-factor
+// authentication until they have enrolled
+func TwoFactorSetupMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentPrincipal(c).TwoFactorSetupRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication setup required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// TwoFactorRequired reports whether TOTP_REQUIRED_ROLES lists role
+func TwoFactorRequired(role string) bool {
+	for _, required := range config.AppConfig.TOTP.RequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role grants are read on every request, so they are kept in memory and
+// reloaded whenever they are edited
+var (
+	grantsMu sync.RWMutex
+	grants   = map[string]map[string]bool{}
+)
+
+// LoadPermissions reads the role grants from the database
+func LoadPermissions() error {
+	var rows []models.RolePermission
+	if err := config.DB.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	loaded := map[string]map[string]bool{}
+	for _, row := range rows {
+		if loaded[row.Role] == nil {
+			loaded[row.Role] = map[string]bool{}
+		}
+		loaded[row.Role][row.Permission] = true
+	}
+
+	grantsMu.Lock()
+	grants = loaded
+	grantsMu.Unlock()
+	return nil
+}
+
+// RoleHasPermission reports whether role is granted permission
+func RoleHasPermission(role, permission string) bool {
+	grantsMu.RLock()
+	defer grantsMu.RUnlock()
+	return grants[role][permission]
+}
+
+// Can reports whether the principal's role is granted permission
+func (p *Principal) Can(permission string) bool {
+	return RoleHasPermission(p.Role, permission)
+}
+
+// RequirePermission restricts a route to users whose role is granted
+// permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := CurrentPrincipal(c)
+		if principal.Role == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
+			c.Abort()
+			return
+		}
+
+		if principal.Can(permission) {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    uint
+	Username  string
+	Role      string
+	SessionID string // login session of the access token, if any
+
+	MustChangePassword bool
+
+	// Set when the role requires two-factor authentication and the user
+	// has not enrolled yet
+	TwoFactorSetupRequired bool
+}
+
+// principalKey is the gin context key holding the *Principal
+const principalKey = "principal"
+
+// HasRole reports whether the principal has one of roles
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPrincipal attaches the authenticated caller to the request
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// CurrentPrincipal returns the caller attached by the authentication
+// middleware, or an empty principal (user ID 0, no role) when the request
+// is unauthenticated
+func CurrentPrincipal(c *gin.Context) *Principal {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*Principal); ok {
+			return principal
+		}
+	}
+	return &Principal{}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters carried by a signed media URL
+const (
+	signedUserParam    = "uid"
+	signedSessionParam = "sid"
+	signedExpiresParam = "exp"
+	signedSigParam     = "sig"
+)
+
+// SignMediaQuery returns the query string that authorises userID to fetch
+// the media of a video (stream, thumbnail and HLS files) until expires, as
+// long as login session sessionID stays active
+func SignMediaQuery(videoID, userID uint, sessionID string, expires time.Time) string {
+	exp := expires.Unix()
+	query := url.Values{}
+	query.Set(signedUserParam, strconv.FormatUint(uint64(userID), 10))
+	query.Set(signedSessionParam, sessionID)
+	query.Set(signedExpiresParam, strconv.FormatInt(exp, 10))
+	query.Set(signedSigParam, mediaSignature(videoID, userID, sessionID, exp))
+	return query.Encode()
+}
+
+// SignedMediaQuery extracts the signature parameters of a request so they
+// can be carried over to URLs referenced from it, such as HLS playlists
+func SignedMediaQuery(c *gin.Context) string {
+	query := url.Values{}
+	for _, name := range []string{signedUserParam, signedSessionParam, signedExpiresParam, signedSigParam} {
+		query.Set(name, c.Query(name))
+	}
+	return query.Encode()
+}
+
+// SignedURLMiddleware authenticates media requests by the signed query
+// string instead of an Authorization header, so the URLs can be used
+// directly as <video> and <img> sources
+func SignedURLMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, sid := c.Query(signedUserParam), c.Query(signedSessionParam)
+		exp, sig := c.Query(signedExpiresParam), c.Query(signedSigParam)
+		if uid == "" || sid == "" || exp == "" || sig == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Signature required"})
+			c.Abort()
+			return
+		}
+
+		videoID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			c.Abort()
+			return
+		}
+		userID, errUser := strconv.ParseUint(uid, 10, 64)
+		expires, errExp := strconv.ParseInt(exp, 10, 64)
+		if errUser != nil || errExp != nil ||
+			!hmac.Equal([]byte(sig), []byte(mediaSignature(uint(videoID), uint(userID), sid, expires))) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
+			c.Abort()
+			return
+		}
+
+		if time.Now().Unix() > expires {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Signed URL has expired"})
+			c.Abort()
+			return
+		}
+
+		// Links stop working before they expire when the user logs out, the
+		// session is revoked or the user is deactivated
+		var user models.User
+		err = config.DB.Joins("JOIN sessions ON sessions.user_id = users.id").
+			Where("sessions.id = ? AND users.id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ? AND users.is_active = ?",
+				sid, userID, time.Now(), true).
+			First(&user).Error
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
+			c.Abort()
+			return
+		}
+
+		SetPrincipal(c, &Principal{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Role:      user.Role,
+			SessionID: sid,
+		})
+
+		c.Next()
+	}
+}
+
+// mediaSignature is the HMAC-SHA256 of the video, user, session and expiry
+func mediaSignature(videoID, userID uint, sessionID string, expires int64) string {
+	mac := hmac.New(sha256.New, mediaSigningKey())
+	fmt.Fprintf(mac, "media\n%d\n%d\n%s\n%d", videoID, userID, sessionID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// mediaSigningKey is the configured key, or one derived from the JWT
+// secret so a token can never be replayed as a signature and vice versa
+func mediaSigningKey() []byte {
+	if key := config.AppConfig.Stream.SigningKey; key != "" {
+		return []byte(key)
+	}
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWT.Secret))
+	mac.Write([]byte("stream-url-signing"))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"trialuploadhk/backend/config"
+	"trialuploadhk/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB loads the default configuration and points config.DB at an
+// empty database
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config.LoadConfig()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	config.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := config.MigrateDatabase(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+}
+
+// createTestSession stores an active user with a login session
+func createTestSession(t *testing.T, username string) (*models.User, *models.Session) {
+	t.Helper()
+	user := models.User{
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: "unused",
+		Role:         models.RoleUser,
+		IsActive:     true,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+
+	session := models.Session{
+		ID:               username + "-session",
+		UserID:           user.ID,
+		RefreshTokenHash: username + "-refresh",
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return &user, &session
+}
+
+// fetchSigned requests the stream of videoID through SignedURLMiddleware
+func fetchSigned(videoID, query string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/videos/:id/stream", SignedURLMiddleware(), func(c *gin.Context) {
+		principal := CurrentPrincipal(c)
+		c.String(http.StatusOK, "%d %s", principal.UserID, principal.SessionID)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/videos/"+videoID+"/stream?"+query, nil))
+	return w
+}
+
+// withParam replaces one parameter of a signed query
+func withParam(query, name, value string) string {
+	values, _ := url.ParseQuery(query)
+	if value == "" {
+		values.Del(name)
+	} else {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+func TestSignedURLMiddleware(t *testing.T) {
+	setupTestDB(t)
+	user, session := createTestSession(t, "viewer")
+	other, otherSession := createTestSession(t, "other")
+	valid := SignMediaQuery(1, user.ID, session.ID, time.Now().Add(time.Hour))
+	expired := SignMediaQuery(1, user.ID, session.ID, time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name    string
+		videoID string
+		query   string
+		want    int
+	}{
+		{"valid", "1", valid, http.StatusOK},
+		{"missing signature", "1", withParam(valid, signedSigParam, ""), http.StatusUnauthorized},
+		{"missing session", "1", withParam(valid, signedSessionParam, ""), http.StatusUnauthorized},
+		{"no query", "1", "", http.StatusUnauthorized},
+		{"other video", "2", valid, http.StatusForbidden},
+		{"invalid video ID", "abc", valid, http.StatusNotFound},
+		{"tampered user", "1", withParam(valid, signedUserParam, "2"), http.StatusForbidden},
+		{"tampered session", "1", withParam(valid, signedSessionParam, otherSession.ID), http.StatusForbidden},
+		{"tampered expiry", "1", withParam(valid, signedExpiresParam, "9999999999"), http.StatusForbidden},
+		{"tampered signature", "1", withParam(valid, signedSigParam, "AAAA"), http.StatusForbidden},
+		{"expired", "1", expired, http.StatusForbidden},
+		// A correct signature for another user's session does not transfer
+		{"other user", "1", SignMediaQuery(1, other.ID, session.ID, time.Now().Add(time.Hour)), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := fetchSigned(tt.videoID, tt.query)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	w := fetchSigned("1", valid)
+	if want := "1 viewer-session"; w.Body.String() != want {
+		t.Errorf("principal = %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestSignedURLMiddlewareRevocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(user *models.User, session *models.Session)
+	}{
+		{"deactivated user", func(user *models.User, session *models.Session) {
+			config.DB.Model(user).Update("is_active", false)
+		}},
+		{"deleted user", func(user *models.User, session *models.Session) {
+			config.DB.Delete(user)
+		}},
+		{"logged out", func(user *models.User, session *models.Session) {
+			config.DB.Model(session).Update("revoked_at", time.Now())
+		}},
+		{"session expired", func(user *models.User, session *models.Session) {
+			config.DB.Model(session).Update("expires_at", time.Now().Add(-time.Minute))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			user, session := createTestSession(t, "viewer")
+			query := SignMediaQuery(1, user.ID, session.ID, time.Now().Add(time.Hour))
+			if w := fetchSigned("1", query); w.Code != http.StatusOK {
+				t.Fatalf("status before revoking = %d", w.Code)
+			}
+
+			tt.revoke(user, session)
+
+			if w := fetchSigned("1", query); w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+// UserRoom assigns a room to a user, who can then see every video
+// recorded in it
+type UserRoom struct {
+	UserID uint `json:"user_id" gorm:"primaryKey"`
+	RoomID uint `json:"room_id" gorm:"primaryKey;index"`
+}
+
+// UserFloor puts a floor under a manager, who can then see every video
+// recorded in the rooms on it
+type UserFloor struct {
+	UserID uint   `json:"user_id" gorm:"primaryKey"`
+	Floor  string `json:"floor" gorm:"primaryKey;size:20"`
+}
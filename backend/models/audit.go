@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit actions
+const (
+	AuditVideoDownload = "video.download"
+	AuditVideoExport   = "video.export"
+
+	AuditPermissionsUpdate = "permissions.update"
+
+	AuditUserUnlock = "user.unlock"
+	AuditIPUnlock   = "ip.unlock"
+
+	AuditPasswordResetIssue  = "user.password_reset"
+	AuditPasswordResetRedeem = "user.password_reset.redeem"
+
+	AuditTwoFactorEnable  = "2fa.enable"
+	AuditTwoFactorDisable = "2fa.disable"
+	AuditTwoFactorReset   = "2fa.reset"
+)
+
+// AuditLog records who did what, and to which video, for accountability. Rows
+// are kept when the video is deleted.
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Username  string    `json:"username" gorm:"size:50"`
+	Action    string    `json:"action" gorm:"not null;size:50;index"`
+	VideoID   *uint     `json:"video_id" gorm:"index"`
+	Detail    string    `json:"detail,omitempty" gorm:"type:text"`
+	IPAddress string    `json:"ip_address" gorm:"size:64"`
+	UserAgent string    `json:"user_agent,omitempty" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Blob is a stored file identified by the SHA-256 of its content. Videos
+// with identical content share a single blob, which is only removed from
+// storage once RefCount drops to zero.
+type Blob struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Hash       string    `json:"hash" gorm:"uniqueIndex;not null;size:64"`
+	Size       int64     `json:"size" gorm:"not null"`
+	StorageKey string    `json:"-" gorm:"not null;size:500"`
+	RefCount   int       `json:"ref_count" gorm:"not null;default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
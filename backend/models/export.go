@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Export statuses
+const (
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+)
+
+// ExportFilters select the videos included in an export
+type ExportFilters struct {
+	RoomIDs    []uint     `json:"room_ids,omitempty"`
+	UploadedBy *uint      `json:"uploaded_by,omitempty"`
+	StartDate  *time.Time `json:"start_date,omitempty"` // inclusive
+	EndDate    *time.Time `json:"end_date,omitempty"`   // exclusive
+}
+
+// Export is a ZIP archive of videos built in the background. The archive
+// is removed once ExpiresAt has passed.
+type Export struct {
+	ID         uint          `json:"id" gorm:"primaryKey"`
+	UserID     uint          `json:"user_id" gorm:"not null;index"`
+	Filters    ExportFilters `json:"filters" gorm:"type:jsonb;serializer:json"`
+	Status     string        `json:"status" gorm:"not null;size:20;default:'processing'"`
+	JobID      *uint         `json:"job_id"`
+	StorageKey string        `json:"-" gorm:"size:500"`
+	Size       int64         `json:"size"`
+	VideoCount int           `json:"video_count"`
+	Error      string        `json:"error,omitempty" gorm:"type:text"`
+	ExpiresAt  time.Time     `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
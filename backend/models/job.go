@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job types
+const (
+	JobTypeThumbnails = "thumbnails"
+	JobTypeTranscode  = "transcode" // payload is the rendition quality
+	JobTypeHLS        = "hls"
+	JobTypeExport     = "export" // payload is the export ID
+)
+
+// Video processing statuses, derived from the video's jobs
+const (
+	ProcessingStatusProcessing = "processing"
+	ProcessingStatusReady      = "ready"
+	ProcessingStatusFailed     = "failed"
+)
+
+// Job is a unit of background work persisted so it survives restarts.
+// Failed attempts are retried with backoff until MaxAttempts is reached.
+type Job struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"not null;size:50;index"`
+	VideoID     *uint      `json:"video_id" gorm:"index"`
+	Payload     string     `json:"payload,omitempty" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"not null;default:'queued';size:20;index:idx_job_due"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"not null;default:5"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index:idx_job_due"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle counts failed password logins from one client address, so
+// guessing across many usernames is slowed down as well
+type LoginThrottle struct {
+	IPAddress      string     `json:"ip_address" gorm:"primaryKey;size:64"`
+	FailedAttempts int        `json:"failed_attempts" gorm:"not null;default:0"`
+	LastFailedAt   time.Time  `json:"last_failed_at" gorm:"index"`
+	LockedUntil    *time.Time `json:"locked_until"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory keeps the hashes of a user's recent passwords so they are
+// not reused
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	PasswordHash string    `json:"-" gorm:"not null;size:255"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
+// PasswordReset is a one-time code issued by a supervisor so a user who
+// forgot their password can set a new one. Only a hash of the code is
+// stored.
+type PasswordReset struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64"`
+	CreatedBy uint       `json:"created_by" gorm:"not null"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"` // wrong codes entered
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
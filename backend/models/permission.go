@@ -0,0 +1,122 @@
+package models
+
+// Roles a user can have
+const (
+	RoleUser       = "user"
+	RoleManager    = "manager"
+	RoleSupervisor = "supervisor"
+)
+
+// Roles lists every role, lowest first
+var Roles = []string{RoleUser, RoleManager, RoleSupervisor}
+
+// IsValidRole reports whether role is one of Roles
+func IsValidRole(role string) bool {
+	return RoleRank(role) >= 0
+}
+
+// RoleRank orders roles by seniority: 0 for user, increasing towards
+// supervisor, and -1 for an unknown role
+func RoleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// Permissions granted to roles
+const (
+	PermVideosUpload      = "videos.upload"
+	PermVideosDownload    = "videos.download"
+	PermVideosViewFloor   = "videos.view.floor"
+	PermVideosViewAll     = "videos.view.all"
+	PermVideosDeleteAny   = "videos.delete.any"
+	PermExportsCreate     = "exports.create"
+	PermRoomsManage       = "rooms.manage"
+	PermUsersManage       = "users.manage"
+	PermUsersUnlock       = "users.unlock"
+	PermPasswordsReset    = "passwords.reset"
+	PermJobsView          = "jobs.view"
+	PermAuditView         = "audit.view"
+	PermPermissionsManage = "permissions.manage"
+)
+
+// PermissionInfo describes a permission for the admin endpoints
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions lists every permission the application checks
+var Permissions = []PermissionInfo{
+	{PermVideosUpload, "Upload videos"},
+	{PermVideosDownload, "Download original video files"},
+	{PermVideosViewFloor, "See videos recorded on the floors assigned to the user"},
+	{PermVideosViewAll, "See every video"},
+	{PermVideosDeleteAny, "Delete videos uploaded by other users"},
+	{PermExportsCreate, "Create and download bulk video exports"},
+	{PermRoomsManage, "Create, edit and delete rooms"},
+	{PermUsersManage, "Create, edit and delete users"},
+	{PermUsersUnlock, "Unlock accounts and addresses locked by failed logins"},
+	{PermPasswordsReset, "Issue one-time password reset codes and reset two-factor authentication"},
+	{PermJobsView, "View background processing jobs"},
+	{PermAuditView, "View the audit log"},
+	{PermPermissionsManage, "View and edit the permissions granted to each role"},
+}
+
+// IsValidPermission reports whether name is one of Permissions
+func IsValidPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions are the grants a new database starts with
+var DefaultRolePermissions = map[string][]string{
+	RoleUser: {
+		PermVideosUpload,
+		PermVideosDownload,
+	},
+	RoleManager: {
+		PermVideosUpload,
+		PermVideosDownload,
+		PermVideosViewFloor,
+		PermVideosDeleteAny,
+		PermExportsCreate,
+		PermRoomsManage,
+		PermUsersManage,
+		PermJobsView,
+		PermAuditView,
+	},
+	RoleSupervisor: {
+		PermVideosUpload,
+		PermVideosDownload,
+		PermVideosViewAll,
+		PermVideosDeleteAny,
+		PermExportsCreate,
+		PermRoomsManage,
+		PermUsersManage,
+		PermUsersUnlock,
+		PermPasswordsReset,
+		PermJobsView,
+		PermAuditView,
+		PermPermissionsManage,
+	},
+}
+
+// RolePermission grants a permission to every user with a role
+type RolePermission struct {
+	Role       string `json:"role" gorm:"primaryKey;size:20"`
+	Permission string `json:"permission" gorm:"primaryKey;size:50"`
+}
+
+// SeededPermission records that a permission's default grants have been
+// created, so grants revoked later through the admin endpoints stay revoked
+type SeededPermission struct {
+	Permission string `gorm:"primaryKey;size:50"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Rendition qualities. The original upload is served as "original" and
+// has no rendition row.
+const (
+	QualityOriginal   = "original"
+	QualityNormalized = "normalized"
+	QualityPreview    = "preview"
+)
+
+// Rendition is a transcoded copy of a video. Renditions of identical
+// content share their stored file.
+type Rendition struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	VideoID     uint      `json:"video_id" gorm:"not null;uniqueIndex:idx_rendition_quality"`
+	Quality     string    `json:"quality" gorm:"not null;size:20;uniqueIndex:idx_rendition_quality"`
+	StorageKey  string    `json:"-" gorm:"not null;size:500"`
+	ContentType string    `json:"content_type" gorm:"size:100"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
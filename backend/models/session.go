@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a login. Access tokens name the session they belong to, and
+// the refresh token that renews them is rotated on every use. Only hashes
+// of refresh tokens are stored.
+type Session struct {
+	ID                string     `json:"id" gorm:"primaryKey;size:36"`
+	UserID            uint       `json:"user_id" gorm:"not null;index"`
+	RefreshTokenHash  string     `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	PreviousTokenHash string     `json:"-" gorm:"size:64;index"` // detects replay of a rotated token
+	IPAddress         string     `json:"ip_address" gorm:"size:64"`
+	UserAgent         string     `json:"user_agent,omitempty" gorm:"size:255"`
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// TOTPSecret is the shared secret of a user's authenticator app. It is
+// pending until the user confirms it with a code; a user has at most one.
+type TOTPSecret struct {
+	UserID       uint       `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Secret       string     `json:"-" gorm:"not null;size:64"` // base32
+	LastUsedStep int64      `json:"-" gorm:"not null;default:0"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (TOTPSecret) TableName() string {
+	return "totp_secrets"
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is lost. Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorChallenge is a login that passed the password check and waits
+// for a TOTP or recovery code. The ID is a hash of the challenge token.
+type TwoFactorChallenge struct {
+	ID        string    `json:"-" gorm:"primaryKey;size:64"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Attempts  int       `json:"attempts" gorm:"not null;default:0"` // wrong codes entered
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
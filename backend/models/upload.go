@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// Upload session protocols
+const (
+	UploadProtocolChunked = "chunked"
+	UploadProtocolTus     = "tus"
+)
+
+// Upload session statuses
+const (
+	UploadStatusPending    = "pending"
+	UploadStatusAssembling = "assembling"
+	UploadStatusCompleted  = "completed"
+)
+
+// UploadSession tracks a resumable upload whose chunks are written into a
+// partial file until the client finalizes it into a Video.
+type UploadSession struct {
+	ID               string    `json:"id" gorm:"primaryKey;size:36"`
+	Protocol         string    `json:"protocol" gorm:"not null;default:'chunked';size:20"`
+	UserID           uint      `json:"user_id" gorm:"not null;index"`
+	RoomID           uint      `json:"room_id" gorm:"not null"`
+	OriginalFilename string    `json:"original_filename" gorm:"not null;size:255"`
+	ContentType      string    `json:"content_type" gorm:"size:100"`
+	TotalSize        int64     `json:"total_size" gorm:"not null"`
+	ChunkSize        int64     `json:"chunk_size" gorm:"not null"`
+	TempPath         string    `json:"-" gorm:"not null;size:500"`
+	Status           string    `json:"status" gorm:"not null;default:'pending';size:20"`
+	VideoID          *uint     `json:"video_id"`
+	ExpiresAt        time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+
+	// Relationships
+	Chunks []UploadChunk `json:"-" gorm:"foreignKey:SessionID"`
+}
+
+// UploadChunk records a byte range of an UploadSession that has been
+// written to the partial file.
+type UploadChunk struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	SessionID string    `json:"session_id" gorm:"not null;size:36;uniqueIndex:idx_upload_chunk"`
+	Index     int       `json:"index" gorm:"column:chunk_index;not null;uniqueIndex:idx_upload_chunk"`
+	Offset    int64     `json:"offset" gorm:"column:byte_offset;not null"`
+	Size      int64     `json:"size" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -17,6 +17,22 @@ type User struct {
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Set for new accounts; only the password change endpoint works until
+	// the user picks a new password
+	MustChangePassword bool `json:"must_change_password" gorm:"not null;default:false"`
+
+	// Password login lockout, see controllers/lockout.go
+	FailedLoginAttempts int        `json:"failed_login_attempts" gorm:"not null;default:0"`
+	LastFailedLoginAt   *time.Time `json:"last_failed_login_at"`
+	LockedUntil         *time.Time `json:"locked_until"`
+
+	// Set once a TOTP authenticator is confirmed, see controllers/twofactor.go
+	TwoFactorEnabled bool `json:"two_factor_enabled" gorm:"not null;default:false"`
+
+	// Visibility assignments, filled by the user management endpoints
+	RoomIDs []uint   `json:"room_ids,omitempty" gorm:"-"`
+	Floors  []string `json:"floors,omitempty" gorm:"-"`
+
 	// Relationships
 	Videos []Video `json:"videos,omitempty" gorm:"foreignKey:UploadedBy"`
 }
@@ -24,6 +40,7 @@ type User struct {
 type Room struct {
 	ID         uint           `json:"id" gorm:"primaryKey"`
 	RoomNumber string         `json:"room_number" gorm:"uniqueIndex;not null;size:20"`
+	Floor      string         `json:"floor" gorm:"size:20;index"`
 	IsActive   bool           `json:"is_active" gorm:"default:true"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
@@ -39,17 +56,45 @@ type Video struct {
 	OriginalFilename string         `json:"original_filename" gorm:"not null;size:255"`
 	FilePath         string         `json:"file_path" gorm:"not null;size:500"`
 	FileSize         int64          `json:"file_size" gorm:"not null"`
+	ContentType      string         `json:"content_type" gorm:"size:100"` // detected from the file content
+	ContentHash      string         `json:"content_hash" gorm:"size:64;index"`
+	BlobID           *uint          `json:"blob_id" gorm:"index"`
 	Duration         *int           `json:"duration"` // in seconds
 	RoomID           *uint          `json:"room_id"`
 	UploadedBy       uint           `json:"uploaded_by" gorm:"not null"`
 	UploadDate       time.Time      `json:"upload_date" gorm:"default:CURRENT_TIMESTAMP"`
 	IsDeleted        bool           `json:"is_deleted" gorm:"default:false"`
-	Metadata         string         `json:"metadata" gorm:"type:jsonb"`
+	Metadata         VideoMetadata  `json:"metadata" gorm:"type:jsonb;serializer:json"`
+	ThumbnailPath    string         `json:"thumbnail_path,omitempty" gorm:"size:500"` // poster frame
+	SpritePath       string         `json:"sprite_path,omitempty" gorm:"size:500"`    // strip of preview frames
+	HLSPath          string         `json:"hls_path,omitempty" gorm:"size:500"`       // master playlist
+	ProcessingStatus string         `json:"processing_status" gorm:"size:20;default:'ready'"`
+	StreamURL        string         `json:"stream_url,omitempty" gorm:"-"` // signed per request
+	ThumbnailURL     string         `json:"thumbnail_url,omitempty" gorm:"-"`
+	HLSURL           string         `json:"hls_url,omitempty" gorm:"-"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Room *Room `json:"room,omitempty" gorm:"foreignKey:RoomID"`
-	User User  `json:"user,omitempty" gorm:"foreignKey:UploadedBy"`
+	Room       *Room       `json:"room,omitempty" gorm:"foreignKey:RoomID"`
+	User       User        `json:"user,omitempty" gorm:"foreignKey:UploadedBy"`
+	Blob       *Blob       `json:"-" gorm:"foreignKey:BlobID"`
+	Renditions []Rendition `json:"renditions,omitempty" gorm:"foreignKey:VideoID"`
+}
+
+// VideoMetadata is the structured metadata stored with each video. Media
+// fields are filled by probing the uploaded container.
+type VideoMetadata struct {
+	RoomNumber   string     `json:"room_number,omitempty"`
+	Container    string     `json:"container,omitempty"`
+	Duration     float64    `json:"duration,omitempty"` // in seconds
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	VideoCodec   string     `json:"video_codec,omitempty"`
+	AudioCodec   string     `json:"audio_codec,omitempty"`
+	FrameRate    float64    `json:"frame_rate,omitempty"`
+	Rotation     int        `json:"rotation,omitempty"` // clockwise degrees
+	CreationTime *time.Time `json:"creation_time,omitempty"`
+	ProbeError   string     `json:"probe_error,omitempty"`
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthn ceremony purposes
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey registered by a user. A user can hold
+// several, one per device.
+type WebAuthnCredential struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"user_id" gorm:"not null;index"`
+	CredentialID    string     `json:"-" gorm:"not null;size:255;uniqueIndex"` // base64url
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"-" gorm:"size:50"`
+	Transports      []string   `json:"transports" gorm:"type:jsonb;serializer:json"`
+	AAGUID          []byte     `json:"-"`
+	BackupEligible  bool       `json:"backup_eligible"` // synced passkey
+	BackupState     bool       `json:"backup_state"`
+	SignCount       uint32     `json:"sign_count" gorm:"not null;default:0"`
+	DeviceName      string     `json:"device_name" gorm:"size:100"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnChallenge holds the server side of a registration or login
+// ceremony between its begin and finish requests
+type WebAuthnChallenge struct {
+	ID          string               `json:"id" gorm:"primaryKey;size:36"`
+	Purpose     string               `json:"purpose" gorm:"not null;size:20"`
+	UserID      *uint                `json:"user_id"` // nil for discoverable logins
+	SessionData webauthn.SessionData `json:"-" gorm:"type:jsonb;serializer:json"`
+	ExpiresAt   time.Time            `json:"expires_at" gorm:"index"`
+	CreatedAt   time.Time            `json:"created_at"`
+}
+
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"trialuploadhk/backend/config"
+)
+
+// Recipient is the user a message is for
+type Recipient struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// Notifier delivers messages to users outside the application
+type Notifier interface {
+	// SendPasswordResetCode delivers a one-time password reset code
+	SendPasswordResetCode(to Recipient, code string, expiresAt time.Time) error
+}
+
+// Service is the notifier used by the application, or nil when codes are
+// displayed to the supervisor who issued them instead
+var Service Notifier
+
+// InitNotifier selects the backend configured in config.NotifyConfig
+func InitNotifier() {
+	var err error
+	Service, err = New(config.AppConfig.Notify)
+	if err != nil {
+		log.Fatal("Failed to initialize notifier:", err)
+	}
+
+	log.Printf("Notifier initialized: %s", config.AppConfig.Notify.Backend)
+}
+
+// New creates the backend named by cfg.Backend. The "display" backend has no
+// notifier and returns nil.
+func New(cfg config.NotifyConfig) (Notifier, error) {
+	switch cfg.Backend {
+	case "", "display":
+		return nil, nil
+	case "log":
+		return LogNotifier{}, nil
+	case "webhook":
+		return NewWebhookNotifier(cfg.WebhookURL)
+	}
+
+	return nil, fmt.Errorf("unknown notifier %q", cfg.Backend)
+}
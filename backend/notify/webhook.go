@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts messages as JSON to a URL, so delivery by email,
+// SMS or chat can be handled by an external service
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier posts to url
+func NewWebhookNotifier(url string) (*WebhookNotifier, error) {
+	if url == "" {
+		return nil, errors.New("NOTIFIER_WEBHOOK_URL is required for the webhook notifier")
+	}
+
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type webhookMessage struct {
+	Event     string    `json:"event"`
+	To        Recipient `json:"to"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (n *WebhookNotifier) SendPasswordResetCode(to Recipient, code string, expiresAt time.Time) error {
+	return n.post(webhookMessage{
+		Event:     "password_reset",
+		To:        to,
+		Code:      code,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (n *WebhookNotifier) post(message webhookMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"trialuploadhk/backend/controllers"
+	"trialuploadhk/backend/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,49 +15,174 @@ func SetupRoutes(r *gin.Engine) {
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", controllers.Login)
-			auth.POST("/logout", controllers.Logout)
+			auth.POST("/refresh", controllers.RefreshToken)
+			auth.POST("/logout", controllers.AuthMiddleware(), controllers.Logout)
+			auth.POST("/change-password", controllers.AuthMiddleware(), controllers.ChangePassword)
+			auth.POST("/password-reset", controllers.RedeemPasswordReset)
+			auth.POST("/2fa/verify", controllers.VerifyTwoFactorLogin)
+
+			// Passkey login
+			auth.POST("/webauthn/authenticate/begin", controllers.BeginWebAuthnLogin)
+			auth.POST("/webauthn/authenticate/finish", controllers.FinishWebAuthnLogin)
+		}
+
+		// Passkey management for the signed in user
+		webauthn := api.Group("/auth/webauthn")
+		webauthn.Use(controllers.AuthMiddleware(), controllers.PasswordChangeMiddleware(), controllers.TwoFactorSetupMiddleware())
+		{
+			webauthn.POST("/register/begin", controllers.BeginWebAuthnRegistration)
+			webauthn.POST("/register/finish", controllers.FinishWebAuthnRegistration)
+			webauthn.GET("/credentials", controllers.GetWebAuthnCredentials)
+			webauthn.DELETE("/credentials/:id", controllers.DeleteWebAuthnCredential)
+		}
+
+		// Profile of the signed in user; the password can be changed even
+		// while a change is required
+		me := api.Group("/me")
+		me.Use(controllers.AuthMiddleware())
+		{
+			me.GET("", controllers.GetProfile)
+			me.PUT("", controllers.PasswordChangeMiddleware(), controllers.TwoFactorSetupMiddleware(), controllers.UpdateProfile)
+			me.POST("/password", controllers.ChangePassword)
+		}
+
+		// Two-factor enrollment, open to users who still have to enroll
+		twoFactor := api.Group("/me/2fa")
+		twoFactor.Use(controllers.AuthMiddleware(), controllers.PasswordChangeMiddleware())
+		{
+			twoFactor.GET("", controllers.GetTwoFactorStatus)
+			twoFactor.POST("/setup", controllers.SetupTwoFactor)
+			twoFactor.POST("/enable", controllers.EnableTwoFactor)
+			twoFactor.POST("/disable", controllers.DisableTwoFactor)
+			twoFactor.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
 		}
 
 		// Protected routes
 		protected := api.Group("/")
-		protected.Use(controllers.AuthMiddleware())
+		protected.Use(controllers.AuthMiddleware(), controllers.PasswordChangeMiddleware(), controllers.TwoFactorSetupMiddleware())
 		{
 			// Video routes
 			videos := protected.Group("/videos")
 			{
-				videos.POST("/upload", controllers.UploadVideo)
+				videos.POST("/upload", controllers.RequirePermission(models.PermVideosUpload), controllers.UploadVideo)
 				videos.GET("", controllers.GetVideos)
 				videos.GET("/:id", controllers.GetVideo)
 				videos.DELETE("/:id", controllers.DeleteVideo)
+				videos.GET("/:id/processing", controllers.GetVideoProcessing)
+				videos.GET("/:id/download", controllers.RequirePermission(models.PermVideosDownload), controllers.DownloadVideo)
+				videos.HEAD("/:id/download", controllers.RequirePermission(models.PermVideosDownload), controllers.DownloadVideo)
 			}
 
-			// Room routes - GET for all users, others for Manager/Supervisor only
+			// Resumable chunked uploads
+			uploads := protected.Group("/videos/uploads")
+			uploads.Use(controllers.RequirePermission(models.PermVideosUpload))
+			{
+				uploads.POST("", controllers.CreateUploadSession)
+				uploads.GET("/:upload_id", controllers.GetUploadSession)
+				uploads.PUT("/:upload_id/chunks/:index", controllers.UploadChunk)
+				uploads.POST("/:upload_id/complete", controllers.CompleteUploadSession)
+				uploads.DELETE("/:upload_id", controllers.CancelUploadSession)
+			}
+
+			// tus 1.0 resumable upload protocol
+			tus := protected.Group("/videos/tus")
+			tus.Use(controllers.RequirePermission(models.PermVideosUpload), controllers.TusMiddleware())
+			{
+				tus.OPTIONS("", controllers.TusOptions)
+				tus.POST("", controllers.TusCreateUpload)
+				tus.HEAD("/:upload_id", controllers.TusUploadOffset)
+				tus.PATCH("/:upload_id", controllers.TusPatchUpload)
+				tus.DELETE("/:upload_id", controllers.TusTerminateUpload)
+			}
+
+			// Room routes - GET for all users, others need rooms.manage
 			rooms := protected.Group("/rooms")
 			{
 				rooms.GET("", controllers.GetRooms) // All authenticated users can view rooms
 			}
 
-			// Room management routes (Manager/Supervisor only)
+			// Room management routes
 			roomManagement := protected.Group("/rooms")
-			roomManagement.Use(controllers.RoleMiddleware("manager", "supervisor"))
+			roomManagement.Use(controllers.RequirePermission(models.PermRoomsManage))
 			{
 				roomManagement.POST("", controllers.CreateRoom)
 				roomManagement.PUT("/:id", controllers.UpdateRoom)
 				roomManagement.DELETE("/:id", controllers.DeleteRoom)
 			}
 
-			// User routes (Manager/Supervisor only)
+			// Background job routes
+			jobs := protected.Group("/jobs")
+			jobs.Use(controllers.RequirePermission(models.PermJobsView))
+			{
+				jobs.GET("", controllers.GetJobs)
+			}
+
+			// Bulk export routes
+			exports := protected.Group("/exports")
+			exports.Use(controllers.RequirePermission(models.PermExportsCreate))
+			{
+				exports.POST("", controllers.CreateExport)
+				exports.GET("", controllers.GetExports)
+				exports.GET("/:id", controllers.GetExport)
+				exports.GET("/:id/download", controllers.DownloadExport)
+				exports.HEAD("/:id/download", controllers.DownloadExport)
+			}
+
+			// Audit log routes
+			audit := protected.Group("/audit")
+			audit.Use(controllers.RequirePermission(models.PermAuditView))
+			{
+				audit.GET("", controllers.GetAuditLogs)
+			}
+
+			// User routes
 			users := protected.Group("/users")
-			users.Use(controllers.RoleMiddleware("manager", "supervisor"))
+			users.Use(controllers.RequirePermission(models.PermUsersManage))
 			{
 				users.GET("", controllers.GetUsers)
 				users.POST("", controllers.CreateUser)
 				users.PUT("/:id", controllers.UpdateUser)
 				users.DELETE("/:id", controllers.DeleteUser)
 			}
+
+			// Password and two-factor resets are granted separately from user
+			// management
+			protected.POST("/users/:id/password-reset", controllers.RequirePermission(models.PermPasswordsReset), controllers.IssuePasswordReset)
+			protected.DELETE("/users/:id/2fa", controllers.RequirePermission(models.PermPasswordsReset), controllers.ResetTwoFactor)
+
+			// Failed login lockout routes
+			lockouts := protected.Group("/lockouts")
+			lockouts.Use(controllers.RequirePermission(models.PermUsersUnlock))
+			{
+				lockouts.GET("", controllers.GetLockouts)
+				lockouts.DELETE("/users/:id", controllers.UnlockUser)
+				lockouts.DELETE("/addresses/:ip", controllers.UnlockAddress)
+			}
+
+			// Permission routes
+			permissions := protected.Group("/permissions")
+			permissions.Use(controllers.RequirePermission(models.PermPermissionsManage))
+			{
+				permissions.GET("", controllers.GetPermissions)
+			}
+
+			roles := protected.Group("/roles")
+			roles.Use(controllers.RequirePermission(models.PermPermissionsManage))
+			{
+				roles.GET("/:role/permissions", controllers.GetRolePermissions)
+				roles.PUT("/:role/permissions", controllers.UpdateRolePermissions)
+			}
 		}
 
-		// Public video streaming route (no auth required)
-		api.GET("/videos/:id/stream", controllers.StreamVideo)
+		// Video streaming and preview routes, authorised by the signed URLs
+		// returned with each video so they work as <video> and <img> sources
+		media := api.Group("/videos/:id")
+		media.Use(controllers.SignedURLMiddleware())
+		{
+			media.GET("/stream", controllers.StreamVideo)
+			media.HEAD("/stream", controllers.StreamVideo)
+			media.GET("/thumbnail", controllers.GetVideoThumbnail)
+			media.GET("/hls/*path", controllers.ServeHLS)
+		}
 	}
 }
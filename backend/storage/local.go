@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage keeps objects as plain files below a root directory
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates the root directory if needed
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create storage directory: %w", err)
+	}
+
+	return &LocalStorage{root: filepath.Clean(root)}, nil
+}
+
+// path maps a key to a file below root. Videos uploaded before the storage
+// abstraction stored the full file path, so keys that already point inside
+// root are accepted as well.
+func (s *LocalStorage) path(key string) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(key))
+	if rel, err := filepath.Rel(s.root, name); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+
+	return filepath.Join(s.root, name), nil
+}
+
+// LocalPath returns the file backing key, letting external tools such as
+// ffmpeg read stored videos without copying them first
+func (s *LocalStorage) LocalPath(key string) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (s *LocalStorage) Upload(key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary name first so readers never see partial files
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+
+	return written, nil
+}
+
+func (s *LocalStorage) Open(key string) (Object, FileInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, FileInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, FileInfo{}, err
+	}
+
+	return file, FileInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (s *LocalStorage) Stat(key string) (FileInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return FileInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return FileInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) ListFiles(prefix string) ([]FileInfo, error) {
+	files := []FileInfo{}
+
+	// Only walk the directory the prefix points into; "videos/2024/ro"
+	// starts at videos/2024
+	start, err := s.path(prefix[:strings.LastIndex(prefix, "/")+1])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(start); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden entries such as the partial upload directory
+		if path != start && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files = append(files, FileInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return files, err
+}
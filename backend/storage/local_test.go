@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageListFiles(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	for _, key := range []string{
+		"videos/2024/room_101/a.mp4",
+		"videos/2024/room_102/b.mp4",
+		"videos/2025/room_101/c.mp4",
+		"thumbnails/a.jpg",
+		".partial/upload.part",
+	} {
+		if _, err := s.Upload(key, strings.NewReader(key)); err != nil {
+			t.Fatalf("Upload %s: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"thumbnails/a.jpg", "videos/2024/room_101/a.mp4", "videos/2024/room_102/b.mp4", "videos/2025/room_101/c.mp4"}},
+		{"videos/2024/", []string{"videos/2024/room_101/a.mp4", "videos/2024/room_102/b.mp4"}},
+		{"videos/2024/room_10", []string{"videos/2024/room_101/a.mp4", "videos/2024/room_102/b.mp4"}},
+		{"videos/2025/room_101/c", []string{"videos/2025/room_101/c.mp4"}},
+		{"thumb", []string{"thumbnails/a.jpg"}},
+		{"exports/", []string{}},
+	}
+
+	for _, tt := range tests {
+		files, err := s.ListFiles(tt.prefix)
+		if err != nil {
+			t.Fatalf("ListFiles(%q): %v", tt.prefix, err)
+		}
+		keys := []string{}
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("ListFiles(%q) = %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+}
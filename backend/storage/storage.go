@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"trialuploadhk/backend/config"
+)
+
+// ErrNotFound is returned when a key does not exist in the store
+var ErrNotFound = errors.New("storage: object not found")
+
+// FileInfo describes a stored object
+type FileInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Object is an opened stored file. It can be handed straight to
+// http.ServeContent, which seeks to serve Range requests.
+type Object interface {
+	io.ReadSeekCloser
+}
+
+// StorageService is the storage abstraction sketched in PROJECT_DESIGN.md.
+// Keys are slash-separated paths such as "2024/01/room_101/video.mp4";
+// content is streamed rather than passed around as byte slices.
+type StorageService interface {
+	// Upload stores r under key, replacing any existing object, and returns
+	// the number of bytes written
+	Upload(key string, r io.Reader) (int64, error)
+	// Open returns a seekable reader for key together with its metadata
+	Open(key string) (Object, FileInfo, error)
+	// Stat returns metadata for key without reading it
+	Stat(key string) (FileInfo, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+	// ListFiles returns every object whose key starts with prefix
+	ListFiles(prefix string) ([]FileInfo, error)
+}
+
+// Service is the storage backend used by the application
+var Service StorageService
+
+// InitStorage selects the backend configured in config.UploadConfig
+func InitStorage() {
+	var err error
+	Service, err = New(config.AppConfig.Upload)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	log.Printf("Storage backend initialized: %s", config.AppConfig.Upload.Storage)
+}
+
+// New creates the backend named by cfg.Storage
+func New(cfg config.UploadConfig) (StorageService, error) {
+	switch cfg.Storage {
+	case "", "local":
+		return NewLocalStorage(cfg.Dir)
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	}
+
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bcrypt ignores everything past 72 bytes
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = parseCommonPasswords(commonPasswordList)
+
+// ErrCommonPassword is returned for passwords found in the embedded list of
+// common and breached passwords
+var ErrCommonPassword = errors.New("Password is too common")
+
+// PasswordPolicy describes what a new password must satisfy
+type PasswordPolicy struct {
+	MinLength    int
+	RejectCommon bool // reject passwords from the embedded common list
+}
+
+// Validate returns an error, worded for the user, when password breaks the
+// policy
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("Password must be at most %d bytes", maxPasswordBytes)
+	}
+	if p.RejectCommon && IsCommonPassword(password) {
+		return ErrCommonPassword
+	}
+	return nil
+}
+
+// IsCommonPassword reports whether password is in the embedded common list
+func IsCommonPassword(password string) bool {
+	_, found := commonPasswords[strings.ToLower(password)]
+	return found
+}
+
+func parseCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
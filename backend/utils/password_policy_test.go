@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, RejectCommon: true}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{"long enough", "night-shift-42", ""},
+		{"too short", "short-pw", "Password must be at least 10 characters"},
+		{"empty", "", "Password must be at least 10 characters"},
+		// Length counts characters, not bytes
+		{"multibyte at minimum", "ñandú-ñoño", ""},
+		{"multibyte too short", "ñandú-ñoñ", "Password must be at least 10 characters"},
+		{"at bcrypt limit", strings.Repeat("x", 72), ""},
+		{"past bcrypt limit", strings.Repeat("x", 73), "Password must be at most 72 bytes"},
+		{"multibyte past bcrypt limit", strings.Repeat("ü", 37), "Password must be at most 72 bytes"},
+		{"common", "1q2w3e4r5t", ErrCommonPassword.Error()},
+		{"common in other case", "1Q2W3E4R5T", ErrCommonPassword.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate(%q) = %v, want nil", tt.password, err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Validate(%q) = %v, want %q", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := policy.Validate("1234567890"); !errors.Is(err, ErrCommonPassword) {
+		t.Errorf("common password error = %v, want ErrCommonPassword", err)
+	}
+}
+
+func TestPasswordPolicyAllowsCommonWhenDisabled(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10}
+	if err := policy.Validate("1q2w3e4r5t"); err != nil {
+		t.Errorf("Validate = %v with the common list disabled", err)
+	}
+}
+
+func TestIsCommonPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password1", true},
+		{"PassWord1", true},
+		{"iloveyou", true},
+		{"night-shift-42", false},
+		{"", false},
+		// Comment lines of the list are not passwords
+		{"# commonly used and breached passwords, one per line, compared without", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCommonPassword(tt.password); got != tt.want {
+			t.Errorf("IsCommonPassword(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommonPasswords(t *testing.T) {
+	got := parseCommonPasswords("# comment\n\n  Secret123  \r\nhunter2\n#hidden\n")
+	want := []string{"secret123", "hunter2"}
+
+	if len(got) != len(want) {
+		t.Fatalf("parsed %v, want %v", got, want)
+	}
+	for _, password := range want {
+		if _, ok := got[password]; !ok {
+			t.Errorf("%q missing from %v", password, got)
+		}
+	}
+}
@@ -3,6 +3,8 @@
 import { useAuth } from '@/contexts/AuthContext';
 import LoginForm from '@/components/LoginForm';
 import Dashboard from '@/components/Dashboard';
+import ChangePasswordForm from '@/components/ChangePasswordForm';
+import TwoFactorEnrollmentForm from '@/components/TwoFactorEnrollmentForm';
 
 export default function Home() {
   const { user, isLoading } = useAuth();
@@ -15,5 +17,15 @@ export default function Home() {
     );
   }
 
-  return user ? <Dashboard /> : <LoginForm />;
+  if (!user) {
+    return <LoginForm />;
+  }
+
+  // Accounts with a temporary or outdated password must replace it first
+  if (user.must_change_password) {
+    return <ChangePasswordForm />;
+  }
+
+  // Roles that require two-factor authentication must enroll next
+  return user.two_factor_setup_required ? <TwoFactorEnrollmentForm /> : <Dashboard />;
 }
@@ -2,15 +2,17 @@
 
 import React, { useState } from 'react';
 import { useAuth } from '@/contexts/AuthContext';
-import { LogOut, User, Shield, Video, Upload, ArrowLeft, Camera, FolderOpen, Users, Building, Home, Settings } from 'lucide-react';
+import { LogOut, User, Shield, Video, Upload, ArrowLeft, Camera, FolderOpen, Users, Building, Home, Settings, Fingerprint, KeyRound, UserCircle } from 'lucide-react';
 import VideoRecorder from './VideoRecorder';
 import RoomManagement from './RoomManagement';
 import UserManagement from './UserManagement';
 import FileManagement from './FileManagement';
+import PasskeyManagement from './PasskeyManagement';
+import ProfileManagement from './ProfileManagement';
 
 const Dashboard: React.FC = () => {
   const { user, logout } = useAuth();
-  const [currentView, setCurrentView] = useState<'dashboard' | 'videoRecording' | 'roomManagement' | 'userManagement' | 'fileManagement' | null>(null);
+  const [currentView, setCurrentView] = useState<'dashboard' | 'videoRecording' | 'roomManagement' | 'userManagement' | 'fileManagement' | 'passkeys' | 'profile' | null>(null);
 
   const handleLogout = () => {
     logout();
@@ -51,6 +53,14 @@ const Dashboard: React.FC = () => {
     }
   };
 
+  const handlePasskeys = () => {
+    setCurrentView('passkeys');
+  };
+
+  const handleProfile = () => {
+    setCurrentView('profile');
+  };
+
   return (
     <div className="min-h-screen bg-gradient-to-br from-gray-50 to-blue-50">
       {/* Top Bar - Mobile Responsive */}
@@ -228,6 +238,46 @@ const Dashboard: React.FC = () => {
                   </div>
                 </div>
               )}
+
+              {/* Passkeys Card */}
+              <div className="bg-white rounded-2xl shadow-sm border border-gray-200 hover:shadow-lg transition-all duration-200 transform hover:scale-[1.02] cursor-pointer" onClick={handlePasskeys}>
+                <div className="p-6">
+                  <div className="flex items-center justify-between mb-4">
+                    <div className="w-12 h-12 bg-gradient-to-br from-teal-500 to-emerald-500 rounded-xl flex items-center justify-center">
+                      <Fingerprint className="w-6 h-6 text-white" />
+                    </div>
+                    <div className="w-8 h-8 bg-teal-100 rounded-full flex items-center justify-center">
+                      <KeyRound className="w-4 h-4 text-teal-600" />
+                    </div>
+                  </div>
+                  <h3 className="text-lg font-semibold text-gray-900 mb-2">
+                    Passkeys
+                  </h3>
+                  <p className="text-gray-600 text-sm">
+                    Sign in with your fingerprint or face instead of a password
+                  </p>
+                </div>
+              </div>
+
+              {/* My Account Card */}
+              <div className="bg-white rounded-2xl shadow-sm border border-gray-200 hover:shadow-lg transition-all duration-200 transform hover:scale-[1.02] cursor-pointer" onClick={handleProfile}>
+                <div className="p-6">
+                  <div className="flex items-center justify-between mb-4">
+                    <div className="w-12 h-12 bg-gradient-to-br from-indigo-500 to-blue-500 rounded-xl flex items-center justify-center">
+                      <UserCircle className="w-6 h-6 text-white" />
+                    </div>
+                    <div className="w-8 h-8 bg-indigo-100 rounded-full flex items-center justify-center">
+                      <Settings className="w-4 h-4 text-indigo-600" />
+                    </div>
+                  </div>
+                  <h3 className="text-lg font-semibold text-gray-900 mb-2">
+                    My Account
+                  </h3>
+                  <p className="text-gray-600 text-sm">
+                    Update your details and change your password
+                  </p>
+                </div>
+              </div>
             </div>
           </div>
         )}
@@ -237,6 +287,8 @@ const Dashboard: React.FC = () => {
         {currentView === 'roomManagement' && <RoomManagement onBack={handleBackToDashboard} />}
         {currentView === 'userManagement' && <UserManagement onBack={handleBackToDashboard} />}
         {currentView === 'fileManagement' && <FileManagement onBack={handleBackToDashboard} />}
+        {currentView === 'passkeys' && <PasskeyManagement onBack={handleBackToDashboard} />}
+        {currentView === 'profile' && <ProfileManagement onBack={handleBackToDashboard} />}
       </div>
     </div>
   );
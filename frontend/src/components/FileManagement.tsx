@@ -16,6 +16,12 @@ interface Video {
   upload_date?: string;
   user?: { username: string };
   room?: { room_number: string };
+  thumbnail_path?: string;
+  processing_status?: 'processing' | 'ready' | 'failed';
+  hls_path?: string;
+  stream_url?: string;
+  thumbnail_url?: string;
+  hls_url?: string;
 }
 
 interface FileManagementProps {
@@ -136,29 +142,78 @@ const FileManagement: React.FC<FileManagementProps> = ({ onBack }) => {
       setIsLoading(true);
       setError(null);
 
-      const response = await fetch(`${API_BASE_URL}/api/videos`, {
+      // The list is paginated; follow the cursor so room and date filters
+      // below see every video
+      const loaded: Video[] = [];
+      let cursor = '';
+      do {
+        const params = new URLSearchParams({ limit: '200' });
+        if (cursor) {
+          params.set('cursor', cursor);
+        }
+        const response = await fetch(`${API_BASE_URL}/api/videos?${params}`, {
+          headers: {
+            'Authorization': `Bearer ${token}`,
+          },
+        });
+
+        if (!response.ok) {
+          setError('Failed to load videos');
+          return;
+        }
+        const data = await response.json();
+        loaded.push(...(data.videos || []));
+        cursor = data.next_cursor || '';
+      } while (cursor);
+
+      setVideos(loaded);
+    } catch (error) {
+      console.error('Error loading videos:', error);
+      setError('Failed to load videos');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  // Media URLs are signed and expire, so fetch fresh ones before playing
+  const handlePlayVideo = async (video: Video) => {
+    let current = video;
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/videos/${video.id}`, {
         headers: {
           'Authorization': `Bearer ${token}`,
         },
       });
-
       if (response.ok) {
         const data = await response.json();
-        setVideos(data.videos || []);
-      } else {
-        setError('Failed to load videos');
+        current = { ...video, ...data.video };
       }
     } catch (error) {
-      console.error('Error loading videos:', error);
-      setError('Failed to load videos');
-    } finally {
-      setIsLoading(false);
+      console.error('Error refreshing video:', error);
     }
+    setSelectedVideo(current);
+    setShowModal(true);
   };
 
-  const handlePlayVideo = (video: Video) => {
-    setSelectedVideo(video);
-    setShowModal(true);
+  // Prefer adaptive HLS where the browser plays it natively (Safari, iOS)
+  const getPlaybackUrl = (video: Video) => {
+    const canPlayHls = typeof document !== 'undefined' &&
+      document.createElement('video').canPlayType('application/vnd.apple.mpegurl') !== '';
+    if (video.hls_url && canPlayHls) {
+      return `${API_BASE_URL}${video.hls_url}`;
+    }
+    return `${API_BASE_URL}${video.stream_url}&quality=auto`;
+  };
+
+  // Prefer the name the server picked in Content-Disposition
+  const getDownloadFilename = (response: Response) => {
+    const disposition = response.headers.get('Content-Disposition') || '';
+    const encoded = disposition.match(/filename\*=utf-8''([^;]+)/i);
+    if (encoded) {
+      return decodeURIComponent(encoded[1]);
+    }
+    const plain = disposition.match(/filename="?([^";]+)"?/i);
+    return plain ? plain[1] : null;
   };
 
   const handleDownloadVideo = async (video: Video) => {
@@ -174,7 +229,7 @@ const FileManagement: React.FC<FileManagementProps> = ({ onBack }) => {
         const url = window.URL.createObjectURL(blob);
         const a = document.createElement('a');
         a.href = url;
-        a.download = video.original_filename || video.filename;
+        a.download = getDownloadFilename(response) || video.original_filename || video.filename;
         document.body.appendChild(a);
         a.click();
         window.URL.revokeObjectURL(url);
@@ -370,9 +425,23 @@ const FileManagement: React.FC<FileManagementProps> = ({ onBack }) => {
             {filteredVideos.map((video) => (
               <div key={video.id} className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 hover:shadow-lg transition-all duration-200">
                 <div className="flex flex-col sm:flex-row sm:items-start sm:justify-between">
+                  {video.thumbnail_url && (
+                    <img
+                      src={`${API_BASE_URL}${video.thumbnail_url}`}
+                      alt=""
+                      loading="lazy"
+                      onClick={() => handlePlayVideo(video)}
+                      className="w-full sm:w-40 aspect-video object-cover rounded-xl bg-gray-100 mb-4 sm:mb-0 sm:mr-4 cursor-pointer"
+                    />
+                  )}
                   <div className="flex-1 mb-4 sm:mb-0">
                     <h3 className="font-semibold text-gray-900 mb-3 text-lg">
                       {video.original_filename || video.filename}
+                      {video.processing_status === 'processing' && (
+                        <span className="ml-2 align-middle px-2 py-0.5 text-xs font-medium rounded-full bg-yellow-100 text-yellow-800">
+                          Processing
+                        </span>
+                      )}
                     </h3>
                     
                     <div className="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-4">
@@ -452,7 +521,8 @@ const FileManagement: React.FC<FileManagementProps> = ({ onBack }) => {
                   controls
                   crossOrigin="anonymous"
                   className="w-full h-auto max-h-[70vh] rounded-xl"
-                  src={`${API_BASE_URL}/api/videos/${selectedVideo.id}/stream`}
+                  src={getPlaybackUrl(selectedVideo)}
+                  poster={selectedVideo.thumbnail_url ? `${API_BASE_URL}${selectedVideo.thumbnail_url}` : undefined}
                   onError={(e) => {
                     console.error('Video playback error:', e);
                     alert('Failed to load video');
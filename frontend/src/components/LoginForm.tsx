@@ -2,7 +2,10 @@
 
 import React, { useState } from 'react';
 import { useAuth } from '@/contexts/AuthContext';
-import { Eye, EyeOff, User, Lock, Camera, Shield } from 'lucide-react';
+import { Eye, EyeOff, User, Lock, Camera, Shield, Fingerprint } from 'lucide-react';
+import { getCredential, isWebAuthnSupported } from '@/lib/webauthn';
+import ResetPasswordForm from './ResetPasswordForm';
+import TwoFactorLoginForm from './TwoFactorLoginForm';
 
 const LoginForm: React.FC = () => {
   const { loginWithToken } = useAuth();
@@ -11,6 +14,8 @@ const LoginForm: React.FC = () => {
   const [password, setPassword] = useState('');
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState('');
+  const [showReset, setShowReset] = useState(false);
+  const [challengeToken, setChallengeToken] = useState('');
 
   const getApiUrl = () => {
     if (typeof window !== 'undefined') {
@@ -68,7 +73,14 @@ const LoginForm: React.FC = () => {
         throw new Error(data.error || 'Login failed');
       }
 
-      await loginWithToken(data.token, data.user);
+      // Accounts with two-factor authentication continue with a code
+      if (data.two_factor_required) {
+        setChallengeToken(data.challenge_token);
+        setPassword('');
+        return;
+      }
+
+      await loginWithToken(data.token, data.user, data);
 
     } catch (err: any) {
       console.error('Login error:', err);
@@ -78,6 +90,57 @@ const LoginForm: React.FC = () => {
     }
   };
 
+  // Sign in with a passkey. A typed username limits the prompt to that
+  // user's passkeys, which helps on devices shared by several staff.
+  const handlePasskeyLogin = async () => {
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const beginResponse = await fetch(`${API_BASE_URL}/api/auth/webauthn/authenticate/begin`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ username }),
+      });
+      const begin = await beginResponse.json();
+      if (!beginResponse.ok) {
+        throw new Error(begin.error || 'Passkey sign in failed');
+      }
+
+      const credential = await getCredential(begin.options);
+
+      const finishResponse = await fetch(`${API_BASE_URL}/api/auth/webauthn/authenticate/finish`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ challenge_id: begin.challenge_id, credential }),
+      });
+      const data = await finishResponse.json();
+      if (!finishResponse.ok) {
+        throw new Error(data.error || 'Passkey sign in failed');
+      }
+
+      await loginWithToken(data.token, data.user, data);
+
+    } catch (err: any) {
+      console.error('Passkey login error:', err);
+      setError(err.message || 'Passkey sign in failed');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  if (challengeToken) {
+    return <TwoFactorLoginForm challengeToken={challengeToken} onBack={() => setChallengeToken('')} />;
+  }
+
+  if (showReset) {
+    return <ResetPasswordForm initialUsername={username} onBack={() => setShowReset(false)} />;
+  }
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-50 via-white to-cyan-50 p-4">
       <div className="w-full max-w-md">
@@ -195,6 +258,27 @@ const LoginForm: React.FC = () => {
             </button>
           </form>
 
+          {/* Passkey Login */}
+          {isWebAuthnSupported() && (
+            <button
+              type="button"
+              onClick={handlePasskeyLogin}
+              disabled={isLoading}
+              className="mt-4 w-full flex justify-center items-center py-3 px-4 border border-gray-300 rounded-xl shadow-sm text-base font-medium text-gray-700 bg-white hover:bg-gray-50 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 disabled:opacity-50 disabled:cursor-not-allowed transition-all duration-200"
+            >
+              <Fingerprint className="h-5 w-5 mr-2 text-blue-600" />
+              Sign in with passkey
+            </button>
+          )}
+
+          <button
+            type="button"
+            onClick={() => setShowReset(true)}
+            className="mt-4 w-full text-sm text-blue-600 hover:text-blue-800"
+          >
+            Have a password reset code?
+          </button>
+
           {/* Footer */}
           <div className="mt-8 text-center">
             <p className="text-xs text-gray-500">
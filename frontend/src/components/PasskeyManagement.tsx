@@ -0,0 +1,259 @@
+'use client';
+
+import React, { useState, useEffect } from 'react';
+import { useAuth } from '@/contexts/AuthContext';
+import { Plus, Trash2, AlertCircle, Fingerprint, Calendar, Smartphone } from 'lucide-react';
+import { createCredential, isWebAuthnSupported } from '@/lib/webauthn';
+
+interface Passkey {
+  id: number;
+  device_name: string;
+  transports?: string[];
+  backup_eligible: boolean;
+  created_at: string;
+  last_used_at?: string | null;
+}
+
+const PasskeyManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
+  const { token } = useAuth();
+  const [passkeys, setPasskeys] = useState<Passkey[]>([]);
+  const [isLoading, setIsLoading] = useState(true);
+  const [isRegistering, setIsRegistering] = useState(false);
+  const [error, setError] = useState('');
+  const [deviceName, setDeviceName] = useState('');
+
+  // Dynamic API URL
+  const getApiUrl = () => {
+    if (typeof window !== 'undefined') {
+      const hostname = window.location.hostname;
+      const protocol = window.location.protocol;
+
+      // If frontend is HTTPS, backend should also be HTTPS
+      if (protocol === 'https:') {
+        return `https://${hostname}:8080`;
+      }
+
+      // For localhost or 127.0.0.1, use localhost
+      if (hostname === 'localhost' || hostname === '127.0.0.1') {
+        return 'http://localhost:8080';
+      }
+
+      // For other hosts, use the same hostname with backend port
+      return `http://${hostname}:8080`;
+    }
+    return 'http://localhost:8080';
+  };
+
+  const API_BASE_URL = getApiUrl();
+
+  useEffect(() => {
+    loadPasskeys();
+  }, []);
+
+  const loadPasskeys = async () => {
+    try {
+      setIsLoading(true);
+      setError('');
+
+      const response = await fetch(`${API_BASE_URL}/api/auth/webauthn/credentials`, {
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        const data = await response.json();
+        setPasskeys(data.credentials || []);
+      } else {
+        setError('Failed to load passkeys');
+      }
+    } catch (error) {
+      setError('Failed to load passkeys');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const handleRegister = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setIsRegistering(true);
+
+    try {
+      const beginResponse = await fetch(`${API_BASE_URL}/api/auth/webauthn/register/begin`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+      const begin = await beginResponse.json();
+      if (!beginResponse.ok) {
+        throw new Error(begin.error || 'Failed to register passkey');
+      }
+
+      const credential = await createCredential(begin.options);
+
+      const finishResponse = await fetch(`${API_BASE_URL}/api/auth/webauthn/register/finish`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify({
+          challenge_id: begin.challenge_id,
+          device_name: deviceName.trim(),
+          credential,
+        }),
+      });
+      const data = await finishResponse.json();
+      if (!finishResponse.ok) {
+        throw new Error(data.error || 'Failed to register passkey');
+      }
+
+      setPasskeys([...passkeys, data.credential]);
+      setDeviceName('');
+    } catch (err: any) {
+      console.error('Passkey registration error:', err);
+      setError(err.message || 'Failed to register passkey');
+    } finally {
+      setIsRegistering(false);
+    }
+  };
+
+  const handleDelete = async (passkeyId: number) => {
+    if (!confirm('Are you sure you want to remove this passkey?')) {
+      return;
+    }
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/auth/webauthn/credentials/${passkeyId}`, {
+        method: 'DELETE',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        setPasskeys(passkeys.filter(passkey => passkey.id !== passkeyId));
+      } else {
+        setError('Failed to remove passkey');
+      }
+    } catch (error) {
+      setError('Failed to remove passkey');
+    }
+  };
+
+  const formatDate = (dateString: string) => {
+    return new Date(dateString).toLocaleDateString();
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-gray-50 to-blue-50 p-4">
+      <div className="max-w-4xl mx-auto">
+        {/* Header */}
+        <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
+          <div className="flex items-center space-x-3">
+            <div className="w-10 h-10 bg-gradient-to-br from-teal-500 to-emerald-500 rounded-xl flex items-center justify-center">
+              <Fingerprint className="w-5 h-5 text-white" />
+            </div>
+            <div>
+              <h1 className="text-xl font-bold text-gray-900">Passkeys</h1>
+              <p className="text-sm text-gray-600">Sign in with your fingerprint or face on your devices</p>
+            </div>
+          </div>
+        </div>
+
+        {/* Error Message */}
+        {error && (
+          <div className="mb-6 bg-red-50 border border-red-200 rounded-xl p-4">
+            <div className="flex items-center">
+              <AlertCircle className="h-5 w-5 text-red-400 mr-2" />
+              <p className="text-sm text-red-700 font-medium">{error}</p>
+            </div>
+          </div>
+        )}
+
+        {/* Register Form */}
+        <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
+          {isWebAuthnSupported() ? (
+            <form onSubmit={handleRegister} className="flex flex-col sm:flex-row gap-3">
+              <input
+                type="text"
+                value={deviceName}
+                onChange={(e) => setDeviceName(e.target.value)}
+                maxLength={100}
+                className="flex-1 px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-teal-500"
+                placeholder="Device name, e.g. Front desk phone"
+              />
+              <button
+                type="submit"
+                disabled={isRegistering}
+                className="flex items-center justify-center px-4 py-2 bg-gradient-to-r from-teal-500 to-emerald-500 text-white rounded-xl hover:from-teal-600 hover:to-emerald-600 transition-all duration-200 shadow-sm font-medium disabled:opacity-50"
+              >
+                <Plus className="w-4 h-4 mr-2" />
+                {isRegistering ? 'Waiting for device...' : 'Add Passkey on This Device'}
+              </button>
+            </form>
+          ) : (
+            <p className="text-sm text-gray-600">This browser does not support passkeys.</p>
+          )}
+        </div>
+
+        {/* Loading State */}
+        {isLoading ? (
+          <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-12 text-center">
+            <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-teal-600 mx-auto mb-4"></div>
+            <p className="text-gray-600 font-medium">Loading passkeys...</p>
+          </div>
+        ) : passkeys.length === 0 ? (
+          <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-12 text-center">
+            <div className="w-16 h-16 bg-gray-100 rounded-full flex items-center justify-center mx-auto mb-4">
+              <Fingerprint className="w-8 h-8 text-gray-400" />
+            </div>
+            <h3 className="text-lg font-semibold text-gray-900 mb-2">No passkeys yet</h3>
+            <p className="text-gray-600">Add a passkey to sign in without your password</p>
+          </div>
+        ) : (
+          <div className="grid gap-4">
+            {passkeys.map((passkey) => (
+              <div key={passkey.id} className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 hover:shadow-lg transition-all duration-200">
+                <div className="flex items-center justify-between">
+                  <div className="flex items-center space-x-4">
+                    <div className="w-12 h-12 bg-gradient-to-br from-teal-500 to-emerald-500 rounded-xl flex items-center justify-center">
+                      <Smartphone className="w-6 h-6 text-white" />
+                    </div>
+                    <div>
+                      <h3 className="text-lg font-semibold text-gray-900">
+                        {passkey.device_name}
+                        {passkey.backup_eligible && <span className="ml-2 text-sm font-normal text-gray-500">Synced</span>}
+                      </h3>
+                      <div className="flex items-center space-x-4 text-sm text-gray-600">
+                        <div className="flex items-center space-x-1">
+                          <Calendar className="w-4 h-4" />
+                          <span>Added: {formatDate(passkey.created_at)}</span>
+                        </div>
+                        <span>
+                          Last used: {passkey.last_used_at ? formatDate(passkey.last_used_at) : 'Never'}
+                        </span>
+                      </div>
+                    </div>
+                  </div>
+
+                  <button
+                    onClick={() => handleDelete(passkey.id)}
+                    className="flex items-center px-3 py-2 bg-gradient-to-r from-red-500 to-red-600 text-white rounded-xl hover:from-red-600 hover:to-red-700 transition-all duration-200 shadow-sm font-medium text-sm"
+                  >
+                    <Trash2 className="w-4 h-4 mr-1" />
+                    Remove
+                  </button>
+                </div>
+              </div>
+            ))}
+          </div>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default PasskeyManagement;
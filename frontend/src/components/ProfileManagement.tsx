@@ -0,0 +1,446 @@
+'use client';
+
+import React, { useState, useEffect } from 'react';
+import { useAuth } from '@/contexts/AuthContext';
+import { AlertCircle, CheckCircle, UserCircle, Lock, Save, ShieldOff, RefreshCw } from 'lucide-react';
+import TwoFactorSetup, { RecoveryCodeList } from './TwoFactorSetup';
+
+interface Profile {
+  id: number;
+  username: string;
+  email: string;
+  role: string;
+  room_ids?: number[];
+  floors?: string[];
+  created_at: string;
+}
+
+interface TwoFactorStatus {
+  enabled: boolean;
+  required: boolean;
+  recovery_codes_remaining: number;
+}
+
+const ProfileManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
+  const { token, updateUser } = useAuth();
+  const [profile, setProfile] = useState<Profile | null>(null);
+  const [isLoading, setIsLoading] = useState(true);
+  const [error, setError] = useState('');
+  const [message, setMessage] = useState('');
+  const [details, setDetails] = useState({ username: '', email: '' });
+  const [passwords, setPasswords] = useState({ current: '', next: '', confirm: '' });
+  const [twoFactor, setTwoFactor] = useState<TwoFactorStatus | null>(null);
+  const [twoFactorForm, setTwoFactorForm] = useState({ password: '', code: '' });
+  const [newRecoveryCodes, setNewRecoveryCodes] = useState<string[]>([]);
+
+  // Dynamic API URL
+  const getApiUrl = () => {
+    if (typeof window !== 'undefined') {
+      const hostname = window.location.hostname;
+      const protocol = window.location.protocol;
+
+      // If frontend is HTTPS, backend should also be HTTPS
+      if (protocol === 'https:') {
+        return `https://${hostname}:8080`;
+      }
+
+      // For localhost or 127.0.0.1, use localhost
+      if (hostname === 'localhost' || hostname === '127.0.0.1') {
+        return 'http://localhost:8080';
+      }
+
+      // For other hosts, use the same hostname with backend port
+      return `http://${hostname}:8080`;
+    }
+    return 'http://localhost:8080';
+  };
+
+  const API_BASE_URL = getApiUrl();
+
+  useEffect(() => {
+    loadProfile();
+    loadTwoFactorStatus();
+  }, []);
+
+  const loadProfile = async () => {
+    try {
+      setIsLoading(true);
+      setError('');
+
+      const response = await fetch(`${API_BASE_URL}/api/me`, {
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        const data = await response.json();
+        setProfile(data.user);
+        setDetails({ username: data.user.username, email: data.user.email });
+      } else {
+        setError('Failed to load profile');
+      }
+    } catch (error) {
+      setError('Failed to load profile');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const loadTwoFactorStatus = async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/2fa`, {
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        setTwoFactor(await response.json());
+      }
+    } catch (error) {
+      console.error('Failed to load two-factor status:', error);
+    }
+  };
+
+  const handleTwoFactorEnabled = () => {
+    updateUser({ two_factor_enabled: true, two_factor_setup_required: false });
+    setMessage('Two-factor authentication enabled');
+    loadTwoFactorStatus();
+  };
+
+  const handleRegenerateCodes = async () => {
+    setError('');
+    setMessage('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/2fa/recovery-codes`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify({ code: twoFactorForm.code }),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to generate recovery codes');
+        return;
+      }
+
+      setTwoFactorForm({ password: '', code: '' });
+      setNewRecoveryCodes(data.recovery_codes);
+    } catch (error) {
+      setError('Failed to generate recovery codes');
+    }
+  };
+
+  const handleDisableTwoFactor = async () => {
+    if (!confirm('Turn off two-factor authentication?')) {
+      return;
+    }
+    setError('');
+    setMessage('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/2fa/disable`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify(twoFactorForm),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to disable two-factor authentication');
+        return;
+      }
+
+      setTwoFactorForm({ password: '', code: '' });
+      updateUser({ two_factor_enabled: false });
+      setMessage('Two-factor authentication disabled');
+      loadTwoFactorStatus();
+    } catch (error) {
+      setError('Failed to disable two-factor authentication');
+    }
+  };
+
+  const handleSaveDetails = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setMessage('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me`, {
+        method: 'PUT',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify(details),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to update profile');
+        return;
+      }
+
+      setProfile(data.user);
+      updateUser({ username: data.user.username, email: data.user.email });
+      setMessage('Profile updated');
+    } catch (error) {
+      setError('Failed to update profile');
+    }
+  };
+
+  const handleChangePassword = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setMessage('');
+    if (passwords.next !== passwords.confirm) {
+      setError('New passwords do not match');
+      return;
+    }
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/password`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify({
+          current_password: passwords.current,
+          new_password: passwords.next,
+        }),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to change password');
+        return;
+      }
+
+      setPasswords({ current: '', next: '', confirm: '' });
+      setMessage('Password changed. Your other devices have been signed out.');
+    } catch (error) {
+      setError('Failed to change password');
+    }
+  };
+
+  const inputClassName = "block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-indigo-500 focus:border-indigo-500";
+
+  if (isLoading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-indigo-600"></div>
+      </div>
+    );
+  }
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-gray-50 to-blue-50 p-4">
+      <div className="max-w-4xl mx-auto">
+        {/* Header */}
+        <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
+          <div className="flex items-center space-x-3">
+            <div className="w-10 h-10 bg-gradient-to-br from-indigo-500 to-blue-500 rounded-xl flex items-center justify-center">
+              <UserCircle className="w-5 h-5 text-white" />
+            </div>
+            <div>
+              <h1 className="text-xl font-bold text-gray-900">My Account</h1>
+              <p className="text-sm text-gray-600">
+                {profile?.role && <span className="capitalize">{profile.role}</span>}
+                {profile?.created_at && <> · Member since {new Date(profile.created_at).toLocaleDateString()}</>}
+              </p>
+            </div>
+          </div>
+        </div>
+
+        {/* Messages */}
+        {error && (
+          <div className="mb-6 bg-red-50 border border-red-200 rounded-xl p-4">
+            <div className="flex items-center">
+              <AlertCircle className="h-5 w-5 text-red-400 mr-2" />
+              <p className="text-sm text-red-700 font-medium">{error}</p>
+            </div>
+          </div>
+        )}
+        {message && (
+          <div className="mb-6 bg-green-50 border border-green-200 rounded-xl p-4">
+            <div className="flex items-center">
+              <CheckCircle className="h-5 w-5 text-green-500 mr-2" />
+              <p className="text-sm text-green-800 font-medium">{message}</p>
+            </div>
+          </div>
+        )}
+
+        {/* Details */}
+        <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
+          <h3 className="text-lg font-semibold text-gray-900 mb-4">Profile</h3>
+          <form onSubmit={handleSaveDetails} className="space-y-4">
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">Username</label>
+                <input
+                  type="text"
+                  required
+                  value={details.username}
+                  onChange={(e) => setDetails({ ...details, username: e.target.value })}
+                  className={inputClassName}
+                />
+              </div>
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">Email</label>
+                <input
+                  type="email"
+                  required
+                  value={details.email}
+                  onChange={(e) => setDetails({ ...details, email: e.target.value })}
+                  className={inputClassName}
+                />
+              </div>
+            </div>
+            <button
+              type="submit"
+              className="flex items-center px-4 py-2 bg-gradient-to-r from-indigo-500 to-blue-500 text-white rounded-xl hover:from-indigo-600 hover:to-blue-600 transition-all duration-200 shadow-sm font-medium"
+            >
+              <Save className="w-4 h-4 mr-2" />
+              Save Profile
+            </button>
+          </form>
+        </div>
+
+        {/* Password */}
+        <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
+          <h3 className="text-lg font-semibold text-gray-900 mb-4">Change Password</h3>
+          <form onSubmit={handleChangePassword} className="space-y-4">
+            <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">Current Password</label>
+                <input
+                  type="password"
+                  required
+                  autoComplete="current-password"
+                  value={passwords.current}
+                  onChange={(e) => setPasswords({ ...passwords, current: e.target.value })}
+                  className={inputClassName}
+                />
+              </div>
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">New Password</label>
+                <input
+                  type="password"
+                  required
+                  autoComplete="new-password"
+                  value={passwords.next}
+                  onChange={(e) => setPasswords({ ...passwords, next: e.target.value })}
+                  className={inputClassName}
+                />
+              </div>
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">Confirm New Password</label>
+                <input
+                  type="password"
+                  required
+                  autoComplete="new-password"
+                  value={passwords.confirm}
+                  onChange={(e) => setPasswords({ ...passwords, confirm: e.target.value })}
+                  className={inputClassName}
+                />
+              </div>
+            </div>
+            <button
+              type="submit"
+              className="flex items-center px-4 py-2 bg-gradient-to-r from-indigo-500 to-blue-500 text-white rounded-xl hover:from-indigo-600 hover:to-blue-600 transition-all duration-200 shadow-sm font-medium"
+            >
+              <Lock className="w-4 h-4 mr-2" />
+              Change Password
+            </button>
+          </form>
+        </div>
+
+        {/* Two-factor authentication */}
+        {twoFactor && (
+          <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6">
+            <h3 className="text-lg font-semibold text-gray-900 mb-1">Two-Factor Authentication</h3>
+            <p className="text-sm text-gray-600 mb-4">
+              {twoFactor.enabled
+                ? `Enabled · ${twoFactor.recovery_codes_remaining} recovery codes left`
+                : 'Sign in with a code from an authenticator app in addition to your password.'}
+              {twoFactor.required && ' Required for your role.'}
+            </p>
+
+            {!twoFactor.enabled ? (
+              <TwoFactorSetup onComplete={handleTwoFactorEnabled} />
+            ) : newRecoveryCodes.length > 0 ? (
+              <RecoveryCodeList
+                codes={newRecoveryCodes}
+                onDone={() => {
+                  setNewRecoveryCodes([]);
+                  loadTwoFactorStatus();
+                }}
+              />
+            ) : (
+              <div className="space-y-4">
+                <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                  <div>
+                    <label className="block text-sm font-medium text-gray-700 mb-2">Authenticator or Recovery Code</label>
+                    <input
+                      type="text"
+                      autoComplete="one-time-code"
+                      value={twoFactorForm.code}
+                      onChange={(e) => setTwoFactorForm({ ...twoFactorForm, code: e.target.value })}
+                      className={inputClassName}
+                    />
+                  </div>
+                  {!twoFactor.required && (
+                    <div>
+                      <label className="block text-sm font-medium text-gray-700 mb-2">Current Password</label>
+                      <input
+                        type="password"
+                        autoComplete="current-password"
+                        value={twoFactorForm.password}
+                        onChange={(e) => setTwoFactorForm({ ...twoFactorForm, password: e.target.value })}
+                        className={inputClassName}
+                      />
+                      <p className="mt-1 text-xs text-gray-500">Only needed to turn two-factor authentication off</p>
+                    </div>
+                  )}
+                </div>
+                <div className="flex space-x-3">
+                  <button
+                    type="button"
+                    onClick={handleRegenerateCodes}
+                    disabled={!twoFactorForm.code}
+                    className="flex items-center px-4 py-2 bg-gradient-to-r from-indigo-500 to-blue-500 text-white rounded-xl hover:from-indigo-600 hover:to-blue-600 transition-all duration-200 shadow-sm font-medium disabled:opacity-50 disabled:cursor-not-allowed"
+                  >
+                    <RefreshCw className="w-4 h-4 mr-2" />
+                    New Recovery Codes
+                  </button>
+                  {!twoFactor.required && (
+                    <button
+                      type="button"
+                      onClick={handleDisableTwoFactor}
+                      disabled={!twoFactorForm.code || !twoFactorForm.password}
+                      className="flex items-center px-4 py-2 border border-red-300 text-red-700 rounded-xl hover:bg-red-50 transition-colors font-medium disabled:opacity-50 disabled:cursor-not-allowed"
+                    >
+                      <ShieldOff className="w-4 h-4 mr-2" />
+                      Turn Off
+                    </button>
+                  )}
+                </div>
+              </div>
+            )}
+          </div>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default ProfileManagement;
@@ -0,0 +1,221 @@
+'use client';
+
+import React, { useState } from 'react';
+import { User, Lock, KeyRound, Shield, CheckCircle } from 'lucide-react';
+
+interface ResetPasswordFormProps {
+  initialUsername?: string;
+  onBack: () => void;
+}
+
+// Sets a new password with a one-time code issued by a supervisor
+const ResetPasswordForm: React.FC<ResetPasswordFormProps> = ({ initialUsername = '', onBack }) => {
+  const [username, setUsername] = useState(initialUsername);
+  const [code, setCode] = useState('');
+  const [newPassword, setNewPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [isLoading, setIsLoading] = useState(false);
+  const [error, setError] = useState('');
+  const [isDone, setIsDone] = useState(false);
+
+  const getApiUrl = () => {
+    if (typeof window !== 'undefined') {
+      const hostname = window.location.hostname;
+      const protocol = window.location.protocol;
+
+      // If frontend is HTTPS, backend should also be HTTPS
+      if (protocol === 'https:') {
+        return `https://${hostname}:8080`;
+      }
+
+      // For localhost or 127.0.0.1, use localhost
+      if (hostname === 'localhost' || hostname === '127.0.0.1') {
+        return 'http://localhost:8080';
+      }
+
+      // For other hosts, use the same hostname with backend port
+      return `http://${hostname}:8080`;
+    }
+    return 'http://localhost:8080';
+  };
+
+  const API_BASE_URL = getApiUrl();
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (newPassword !== confirmPassword) {
+      setError('New passwords do not match');
+      return;
+    }
+
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/auth/password-reset`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+          username,
+          code,
+          new_password: newPassword,
+        }),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.error || 'Failed to reset password');
+      }
+
+      setIsDone(true);
+    } catch (err: any) {
+      console.error('Password reset error:', err);
+      setError(err.message || 'Failed to reset password');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const inputClassName = "block w-full pl-10 pr-4 py-3 border border-gray-300 rounded-xl shadow-sm placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 text-base";
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-50 via-white to-cyan-50 p-4">
+      <div className="w-full max-w-md">
+        <div className="bg-white rounded-2xl shadow-xl border border-gray-100 p-6 sm:p-8">
+          <div className="text-center mb-6">
+            <div className="mx-auto w-12 h-12 bg-gradient-to-br from-blue-500 to-cyan-500 rounded-xl flex items-center justify-center mb-4">
+              <KeyRound className="w-6 h-6 text-white" />
+            </div>
+            <h2 className="text-2xl font-bold text-gray-900 mb-2">
+              Reset Password
+            </h2>
+            <p className="text-gray-600">
+              Enter the reset code you received from your supervisor
+            </p>
+          </div>
+
+          {isDone ? (
+            <div className="text-center space-y-6">
+              <div className="flex items-center justify-center text-green-700">
+                <CheckCircle className="h-5 w-5 mr-2" />
+                <p className="font-medium">Your password has been reset</p>
+              </div>
+              <button
+                type="button"
+                onClick={onBack}
+                className="w-full py-3 px-4 rounded-xl shadow-sm text-base font-medium text-white bg-gradient-to-r from-blue-600 to-cyan-600 hover:from-blue-700 hover:to-cyan-700 transition-all duration-200"
+              >
+                Back to Sign In
+              </button>
+            </div>
+          ) : (
+            <>
+              {/* Error Message */}
+              {error && (
+                <div className="mb-6 bg-red-50 border border-red-200 rounded-xl p-4">
+                  <div className="flex items-center">
+                    <div className="flex-shrink-0">
+                      <Shield className="h-5 w-5 text-red-400" />
+                    </div>
+                    <div className="ml-3">
+                      <p className="text-sm text-red-700 font-medium">
+                        {error}
+                      </p>
+                    </div>
+                  </div>
+                </div>
+              )}
+
+              <form onSubmit={handleSubmit} className="space-y-6">
+                <div>
+                  <label htmlFor="reset_username" className="block text-sm font-medium text-gray-700 mb-2">
+                    Username
+                  </label>
+                  <div className="relative">
+                    <div className="absolute inset-y-0 left-0 pl-3 flex items-center pointer-events-none">
+                      <User className="h-5 w-5 text-gray-400" />
+                    </div>
+                    <input
+                      id="reset_username"
+                      type="text"
+                      required
+                      value={username}
+                      onChange={(e) => setUsername(e.target.value)}
+                      className={inputClassName}
+                    />
+                  </div>
+                </div>
+
+                <div>
+                  <label htmlFor="reset_code" className="block text-sm font-medium text-gray-700 mb-2">
+                    Reset Code
+                  </label>
+                  <div className="relative">
+                    <div className="absolute inset-y-0 left-0 pl-3 flex items-center pointer-events-none">
+                      <KeyRound className="h-5 w-5 text-gray-400" />
+                    </div>
+                    <input
+                      id="reset_code"
+                      type="text"
+                      required
+                      autoComplete="one-time-code"
+                      value={code}
+                      onChange={(e) => setCode(e.target.value)}
+                      className={`${inputClassName} uppercase tracking-widest`}
+                      placeholder="XXXXX-XXXXX"
+                    />
+                  </div>
+                </div>
+
+                {[
+                  { id: 'reset_new_password', label: 'New Password', value: newPassword, onChange: setNewPassword },
+                  { id: 'reset_confirm_password', label: 'Confirm New Password', value: confirmPassword, onChange: setConfirmPassword },
+                ].map((field) => (
+                  <div key={field.id}>
+                    <label htmlFor={field.id} className="block text-sm font-medium text-gray-700 mb-2">
+                      {field.label}
+                    </label>
+                    <div className="relative">
+                      <div className="absolute inset-y-0 left-0 pl-3 flex items-center pointer-events-none">
+                        <Lock className="h-5 w-5 text-gray-400" />
+                      </div>
+                      <input
+                        id={field.id}
+                        type="password"
+                        required
+                        autoComplete="new-password"
+                        value={field.value}
+                        onChange={(e) => field.onChange(e.target.value)}
+                        className={inputClassName}
+                      />
+                    </div>
+                  </div>
+                ))}
+
+                <button
+                  type="submit"
+                  disabled={isLoading}
+                  className="w-full flex justify-center items-center py-3 px-4 border border-transparent rounded-xl shadow-sm text-base font-medium text-white bg-gradient-to-r from-blue-600 to-cyan-600 hover:from-blue-700 hover:to-cyan-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 disabled:opacity-50 disabled:cursor-not-allowed transition-all duration-200"
+                >
+                  {isLoading ? 'Saving...' : 'Set New Password'}
+                </button>
+              </form>
+
+              <button
+                type="button"
+                onClick={onBack}
+                className="mt-4 w-full text-sm text-gray-600 hover:text-gray-900"
+              >
+                Back to Sign In
+              </button>
+            </>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ResetPasswordForm;
@@ -7,6 +7,7 @@ import { Plus, Edit, Trash2, ArrowLeft, AlertCircle, Building, Calendar, Search
 interface Room {
   id: number;
   room_number: string;
+  floor?: string;
   created_at: string;
   updated_at: string;
 }
@@ -19,7 +20,7 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
   const [error, setError] = useState('');
   const [showForm, setShowForm] = useState(false);
   const [editingRoom, setEditingRoom] = useState<Room | null>(null);
-  const [formData, setFormData] = useState({ room_number: '' });
+  const [formData, setFormData] = useState({ room_number: '', floor: '' });
   const [searchTerm, setSearchTerm] = useState('');
 
   // Check if user has permission to access Room Management
@@ -143,7 +144,7 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       if (response.ok) {
         setShowForm(false);
         setEditingRoom(null);
-        setFormData({ room_number: '' });
+        setFormData({ room_number: '', floor: '' });
         loadRooms();
       } else {
         const data = await response.json();
@@ -156,7 +157,7 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
 
   const handleEdit = (room: Room) => {
     setEditingRoom(room);
-    setFormData({ room_number: room.room_number });
+    setFormData({ room_number: room.room_number, floor: room.floor || '' });
     setShowForm(true);
   };
 
@@ -186,7 +187,7 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
   const handleCancel = () => {
     setShowForm(false);
     setEditingRoom(null);
-    setFormData({ room_number: '' });
+    setFormData({ room_number: '', floor: '' });
     setError('');
   };
 
@@ -269,6 +270,19 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                   required
                 />
               </div>
+              <div>
+                <label htmlFor="floor" className="block text-sm font-medium text-gray-700 mb-2">
+                  Floor
+                </label>
+                <input
+                  type="text"
+                  id="floor"
+                  value={formData.floor}
+                  onChange={(e) => setFormData({ ...formData, floor: e.target.value })}
+                  className="block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-purple-500 focus:border-purple-500"
+                  placeholder="Managers assigned this floor can see its videos"
+                />
+              </div>
               <div className="flex space-x-3">
                 <button
                   type="submit"
@@ -319,6 +333,7 @@ const RoomManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                     <div>
                       <h3 className="text-lg font-semibold text-gray-900">
                         Room {room.room_number}
+                        {room.floor && <span className="ml-2 text-sm font-normal text-gray-500">Floor {room.floor}</span>}
                       </h3>
                       <div className="flex items-center space-x-4 text-sm text-gray-600">
                         <div className="flex items-center space-x-1">
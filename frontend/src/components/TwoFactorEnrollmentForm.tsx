@@ -0,0 +1,46 @@
+'use client';
+
+import React from 'react';
+import { useAuth } from '@/contexts/AuthContext';
+import { ShieldCheck } from 'lucide-react';
+import TwoFactorSetup from './TwoFactorSetup';
+
+// Shown instead of the dashboard until a user whose role requires
+// two-factor authentication has enrolled
+const TwoFactorEnrollmentForm: React.FC = () => {
+  const { user, updateUser, logout } = useAuth();
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-50 via-white to-cyan-50 p-4">
+      <div className="w-full max-w-lg">
+        <div className="bg-white rounded-2xl shadow-xl border border-gray-100 p-6 sm:p-8">
+          <div className="text-center mb-6">
+            <div className="mx-auto w-12 h-12 bg-gradient-to-br from-blue-500 to-cyan-500 rounded-xl flex items-center justify-center mb-4">
+              <ShieldCheck className="w-6 h-6 text-white" />
+            </div>
+            <h2 className="text-2xl font-bold text-gray-900 mb-2">
+              Set Up Two-Factor Authentication
+            </h2>
+            <p className="text-gray-600">
+              {user?.username}, your role requires a code from an authenticator app at every sign in
+            </p>
+          </div>
+
+          <TwoFactorSetup
+            onComplete={() => updateUser({ two_factor_enabled: true, two_factor_setup_required: false })}
+          />
+
+          <button
+            type="button"
+            onClick={logout}
+            className="mt-6 w-full text-sm text-gray-600 hover:text-gray-900"
+          >
+            Sign out
+          </button>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default TwoFactorEnrollmentForm;
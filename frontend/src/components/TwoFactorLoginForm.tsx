@@ -0,0 +1,168 @@
+'use client';
+
+import React, { useState } from 'react';
+import { useAuth } from '@/contexts/AuthContext';
+import { ShieldCheck, Shield, KeyRound } from 'lucide-react';
+
+interface TwoFactorLoginFormProps {
+  challengeToken: string;
+  onBack: () => void;
+}
+
+// Second login step for accounts with two-factor authentication
+const TwoFactorLoginForm: React.FC<TwoFactorLoginFormProps> = ({ challengeToken, onBack }) => {
+  const { loginWithToken } = useAuth();
+  const [code, setCode] = useState('');
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [isLoading, setIsLoading] = useState(false);
+  const [error, setError] = useState('');
+
+  const getApiUrl = () => {
+    if (typeof window !== 'undefined') {
+      const hostname = window.location.hostname;
+      const protocol = window.location.protocol;
+
+      // If frontend is HTTPS, backend should also be HTTPS
+      if (protocol === 'https:') {
+        return `https://${hostname}:8080`;
+      }
+
+      // For localhost or 127.0.0.1, use localhost
+      if (hostname === 'localhost' || hostname === '127.0.0.1') {
+        return 'http://localhost:8080';
+      }
+
+      // For other hosts, use the same hostname with backend port
+      return `http://${hostname}:8080`;
+    }
+    return 'http://localhost:8080';
+  };
+
+  const API_BASE_URL = getApiUrl();
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/auth/2fa/verify`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+          challenge_token: challengeToken,
+          code,
+        }),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.error || 'Verification failed');
+      }
+
+      await loginWithToken(data.token, data.user, data);
+    } catch (err: any) {
+      console.error('Two-factor verification error:', err);
+      setError(err.message || 'Verification failed');
+      setCode('');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const inputClassName = "block w-full pl-10 pr-4 py-3 border border-gray-300 rounded-xl shadow-sm placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-blue-500 text-base tracking-widest";
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-50 via-white to-cyan-50 p-4">
+      <div className="w-full max-w-md">
+        <div className="bg-white rounded-2xl shadow-xl border border-gray-100 p-6 sm:p-8">
+          <div className="text-center mb-6">
+            <div className="mx-auto w-12 h-12 bg-gradient-to-br from-blue-500 to-cyan-500 rounded-xl flex items-center justify-center mb-4">
+              <ShieldCheck className="w-6 h-6 text-white" />
+            </div>
+            <h2 className="text-2xl font-bold text-gray-900 mb-2">
+              Two-Factor Authentication
+            </h2>
+            <p className="text-gray-600">
+              {useRecoveryCode
+                ? 'Enter one of your recovery codes'
+                : 'Enter the 6-digit code from your authenticator app'}
+            </p>
+          </div>
+
+          {/* Error Message */}
+          {error && (
+            <div className="mb-6 bg-red-50 border border-red-200 rounded-xl p-4">
+              <div className="flex items-center">
+                <div className="flex-shrink-0">
+                  <Shield className="h-5 w-5 text-red-400" />
+                </div>
+                <div className="ml-3">
+                  <p className="text-sm text-red-700 font-medium">
+                    {error}
+                  </p>
+                </div>
+              </div>
+            </div>
+          )}
+
+          <form onSubmit={handleSubmit} className="space-y-6">
+            <div>
+              <label htmlFor="two_factor_code" className="block text-sm font-medium text-gray-700 mb-2">
+                {useRecoveryCode ? 'Recovery Code' : 'Verification Code'}
+              </label>
+              <div className="relative">
+                <div className="absolute inset-y-0 left-0 pl-3 flex items-center pointer-events-none">
+                  <KeyRound className="h-5 w-5 text-gray-400" />
+                </div>
+                <input
+                  id="two_factor_code"
+                  type="text"
+                  required
+                  autoFocus
+                  autoComplete="one-time-code"
+                  inputMode={useRecoveryCode ? 'text' : 'numeric'}
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  className={useRecoveryCode ? `${inputClassName} uppercase` : inputClassName}
+                  placeholder={useRecoveryCode ? 'XXXXX-XXXXX' : '123456'}
+                />
+              </div>
+            </div>
+
+            <button
+              type="submit"
+              disabled={isLoading}
+              className="w-full flex justify-center items-center py-3 px-4 border border-transparent rounded-xl shadow-sm text-base font-medium text-white bg-gradient-to-r from-blue-600 to-cyan-600 hover:from-blue-700 hover:to-cyan-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 disabled:opacity-50 disabled:cursor-not-allowed transition-all duration-200"
+            >
+              {isLoading ? 'Verifying...' : 'Verify'}
+            </button>
+          </form>
+
+          <button
+            type="button"
+            onClick={() => {
+              setUseRecoveryCode(!useRecoveryCode);
+              setCode('');
+            }}
+            className="mt-4 w-full text-sm text-blue-600 hover:text-blue-800"
+          >
+            {useRecoveryCode ? 'Use your authenticator app' : 'Lost your device? Use a recovery code'}
+          </button>
+
+          <button
+            type="button"
+            onClick={onBack}
+            className="mt-2 w-full text-sm text-gray-600 hover:text-gray-900"
+          >
+            Back to Sign In
+          </button>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default TwoFactorLoginForm;
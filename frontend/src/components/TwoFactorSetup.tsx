@@ -0,0 +1,205 @@
+'use client';
+
+import React, { useState } from 'react';
+import { useAuth } from '@/contexts/AuthContext';
+import { AlertCircle, ShieldCheck, Copy } from 'lucide-react';
+
+interface SetupDetails {
+  secret: string;
+  otpauth_url: string;
+  qr_code: string;
+}
+
+// Enrolls the signed in user's authenticator app: scan the QR code, confirm
+// with a code, then save the recovery codes
+const TwoFactorSetup: React.FC<{ onComplete: () => void }> = ({ onComplete }) => {
+  const { token } = useAuth();
+  const [setup, setSetup] = useState<SetupDetails | null>(null);
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [isLoading, setIsLoading] = useState(false);
+  const [error, setError] = useState('');
+
+  // Dynamic API URL
+  const getApiUrl = () => {
+    if (typeof window !== 'undefined') {
+      const hostname = window.location.hostname;
+      const protocol = window.location.protocol;
+
+      // If frontend is HTTPS, backend should also be HTTPS
+      if (protocol === 'https:') {
+        return `https://${hostname}:8080`;
+      }
+
+      // For localhost or 127.0.0.1, use localhost
+      if (hostname === 'localhost' || hostname === '127.0.0.1') {
+        return 'http://localhost:8080';
+      }
+
+      // For other hosts, use the same hostname with backend port
+      return `http://${hostname}:8080`;
+    }
+    return 'http://localhost:8080';
+  };
+
+  const API_BASE_URL = getApiUrl();
+
+  const handleStart = async () => {
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/2fa/setup`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to start setup');
+        return;
+      }
+
+      setSetup(data);
+    } catch (error) {
+      setError('Failed to start setup');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const handleEnable = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/me/2fa/enable`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': `Bearer ${token}`,
+        },
+        body: JSON.stringify({ code }),
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to enable two-factor authentication');
+        return;
+      }
+
+      setRecoveryCodes(data.recovery_codes);
+    } catch (error) {
+      setError('Failed to enable two-factor authentication');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const buttonClassName = "flex items-center px-4 py-2 bg-gradient-to-r from-indigo-500 to-blue-500 text-white rounded-xl hover:from-indigo-600 hover:to-blue-600 transition-all duration-200 shadow-sm font-medium disabled:opacity-50 disabled:cursor-not-allowed";
+
+  return (
+    <div className="space-y-4">
+      {error && (
+        <div className="bg-red-50 border border-red-200 rounded-xl p-4">
+          <div className="flex items-center">
+            <AlertCircle className="h-5 w-5 text-red-400 mr-2" />
+            <p className="text-sm text-red-700 font-medium">{error}</p>
+          </div>
+        </div>
+      )}
+
+      {recoveryCodes.length > 0 ? (
+        <RecoveryCodeList codes={recoveryCodes} onDone={onComplete} />
+      ) : setup ? (
+        <form onSubmit={handleEnable} className="space-y-4">
+          <p className="text-sm text-gray-600">
+            Scan the QR code with an authenticator app such as Google Authenticator, Microsoft Authenticator or 1Password, then enter the 6-digit code it shows.
+          </p>
+          <div className="flex flex-col sm:flex-row items-center sm:items-start gap-4">
+            <img src={setup.qr_code} alt="Two-factor QR code" className="w-48 h-48 border border-gray-200 rounded-xl" />
+            <div className="text-sm text-gray-600">
+              <p className="mb-1">Can&apos;t scan it? Enter this key instead:</p>
+              <code className="block font-mono text-gray-900 bg-gray-50 border border-gray-200 rounded-lg px-3 py-2 break-all">
+                {setup.secret}
+              </code>
+            </div>
+          </div>
+          <div className="flex items-end gap-3">
+            <div>
+              <label className="block text-sm font-medium text-gray-700 mb-2">Verification Code</label>
+              <input
+                type="text"
+                required
+                inputMode="numeric"
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className="block w-40 px-3 py-2 border border-gray-300 rounded-xl tracking-widest focus:outline-none focus:ring-2 focus:ring-indigo-500 focus:border-indigo-500"
+                placeholder="123456"
+              />
+            </div>
+            <button type="submit" disabled={isLoading} className={buttonClassName}>
+              <ShieldCheck className="w-4 h-4 mr-2" />
+              {isLoading ? 'Verifying...' : 'Enable'}
+            </button>
+          </div>
+        </form>
+      ) : (
+        <button type="button" onClick={handleStart} disabled={isLoading} className={buttonClassName}>
+          <ShieldCheck className="w-4 h-4 mr-2" />
+          {isLoading ? 'Starting...' : 'Set Up Two-Factor Authentication'}
+        </button>
+      )}
+    </div>
+  );
+};
+
+// Shows freshly generated recovery codes; they cannot be displayed again
+export const RecoveryCodeList: React.FC<{ codes: string[]; onDone: () => void }> = ({ codes, onDone }) => {
+  const [copied, setCopied] = useState(false);
+
+  const handleCopy = async () => {
+    try {
+      await navigator.clipboard.writeText(codes.join('\n'));
+      setCopied(true);
+    } catch (error) {
+      console.error('Copy failed:', error);
+    }
+  };
+
+  return (
+    <div className="space-y-4">
+      <p className="text-sm text-gray-600">
+        Save these recovery codes somewhere safe. Each one signs you in once if you lose your authenticator, and they will not be shown again.
+      </p>
+      <div className="grid grid-cols-2 gap-2 font-mono text-sm text-gray-900 bg-gray-50 border border-gray-200 rounded-xl p-4">
+        {codes.map((code) => (
+          <span key={code}>{code}</span>
+        ))}
+      </div>
+      <div className="flex space-x-3">
+        <button
+          type="button"
+          onClick={handleCopy}
+          className="flex items-center px-4 py-2 border border-gray-300 text-gray-700 rounded-xl hover:bg-gray-50 transition-colors font-medium"
+        >
+          <Copy className="w-4 h-4 mr-2" />
+          {copied ? 'Copied' : 'Copy'}
+        </button>
+        <button
+          type="button"
+          onClick={onDone}
+          className="px-4 py-2 bg-gradient-to-r from-indigo-500 to-blue-500 text-white rounded-xl hover:from-indigo-600 hover:to-blue-600 transition-all duration-200 shadow-sm font-medium"
+        >
+          I have saved my codes
+        </button>
+      </div>
+    </div>
+  );
+};
+
+export default TwoFactorSetup;
@@ -2,7 +2,7 @@
 
 import React, { useState, useEffect } from 'react';
 import { useAuth } from '@/contexts/AuthContext';
-import { Plus, Edit, Trash2, ArrowLeft, Eye, EyeOff, AlertCircle, User } from 'lucide-react';
+import { Plus, Edit, Trash2, ArrowLeft, Eye, EyeOff, AlertCircle, User, Unlock, KeyRound, ShieldOff } from 'lucide-react';
 
 interface User {
   id: number;
@@ -10,29 +10,68 @@ interface User {
   email: string;
   role: string;
   is_active: boolean;
+  room_ids?: number[];
+  floors?: string[];
+  failed_login_attempts?: number;
+  locked_until?: string | null;
+  two_factor_enabled?: boolean;
   created_at: string;
   updated_at: string;
 }
 
+interface Room {
+  id: number;
+  room_number: string;
+}
+
 const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
   const { token, user } = useAuth();
   const [users, setUsers] = useState<User[]>([]);
   const [isLoading, setIsLoading] = useState(true);
   const [error, setError] = useState('');
+  const [resetNotice, setResetNotice] = useState('');
   const [showForm, setShowForm] = useState(false);
   const [editingUser, setEditingUser] = useState<User | null>(null);
   const [showPassword, setShowPassword] = useState(false);
-  const [formData, setFormData] = useState({
+  const [rooms, setRooms] = useState<Room[]>([]);
+  const [formData, setFormData] = useState<{
+    username: string;
+    email: string;
+    password: string;
+    role: string;
+    is_active: boolean;
+    room_ids: number[];
+    floors: string;
+  }>({
     username: '',
     email: '',
     password: '',
     role: 'user',
-    is_active: true
+    is_active: true,
+    room_ids: [],
+    floors: ''
   });
 
   // Check if user has permission to access User Management
   const hasPermission = user?.role === 'manager' || user?.role === 'supervisor';
 
+  // Users can only manage and assign roles below their own
+  const roleRanks: Record<string, number> = { user: 0, manager: 1, supervisor: 2 };
+  const currentRank = roleRanks[user?.role ?? ''] ?? -1;
+  const isSelf = (target: User) => target.id === user?.id;
+  const canManage = (target: User) => (roleRanks[target.role] ?? -1) < currentRank;
+  const canUnlock = user?.permissions?.includes('users.unlock') ?? false;
+  // Reset codes are returned to the caller, so they only target lower roles
+  const canResetPassword = (target: User) =>
+    (user?.permissions?.includes('passwords.reset') ?? false) && !isSelf(target) && (roleRanks[target.role] ?? -1) < currentRank;
+  const isLocked = (target: User) => !!target.locked_until && new Date(target.locked_until) > new Date();
+  const editingSelf = editingUser !== null && isSelf(editingUser);
+  const roleOptions = [
+    { value: 'user', label: 'User' },
+    { value: 'manager', label: 'Manager' },
+    { value: 'supervisor', label: 'Supervisor' },
+  ].filter((option) => editingSelf ? option.value === formData.role : roleRanks[option.value] < currentRank);
+
   const getApiUrl = () => {
     if (typeof window !== 'undefined') {
       const hostname = window.location.hostname;
@@ -64,6 +103,7 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
   useEffect(() => {
     if (hasPermission) {
       loadUsers();
+      loadRooms();
     }
   }, [hasPermission]);
 
@@ -88,6 +128,32 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
     }
   };
 
+  const loadRooms = async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/rooms`, {
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        const data = await response.json();
+        setRooms(data.rooms || []);
+      }
+    } catch (error) {
+      console.error('Error loading rooms:', error);
+    }
+  };
+
+  const handleRoomToggle = (roomId: number) => {
+    setFormData(prev => ({
+      ...prev,
+      room_ids: prev.room_ids.includes(roomId)
+        ? prev.room_ids.filter(id => id !== roomId)
+        : [...prev.room_ids, roomId]
+    }));
+  };
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError('');
@@ -105,7 +171,10 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
           'Content-Type': 'application/json',
           'Authorization': `Bearer ${token}`,
         },
-        body: JSON.stringify(formData),
+        body: JSON.stringify({
+          ...formData,
+          floors: formData.floors.split(',').map(floor => floor.trim()).filter(Boolean),
+        }),
       });
 
       if (response.ok) {
@@ -116,7 +185,9 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
           email: '',
           password: '',
           role: 'user',
-          is_active: true
+          is_active: true,
+          room_ids: [],
+          floors: ''
         });
         loadUsers();
       } else {
@@ -135,7 +206,9 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       email: user.email,
       password: '',
       role: user.role,
-      is_active: user.is_active
+      is_active: user.is_active,
+      room_ids: user.room_ids || [],
+      floors: (user.floors || []).join(', ')
     });
     setShowForm(true);
   };
@@ -163,6 +236,79 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
     }
   };
 
+  const handleUnlock = async (userId: number) => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/lockouts/users/${userId}`, {
+        method: 'DELETE',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      if (response.ok) {
+        loadUsers();
+      } else {
+        setError('Failed to unlock user');
+      }
+    } catch (error) {
+      setError('Failed to unlock user');
+    }
+  };
+
+  const handleResetPassword = async (target: User) => {
+    if (!confirm(`Issue a password reset code for ${target.username}?`)) {
+      return;
+    }
+    setResetNotice('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/users/${target.id}/password-reset`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to reset password');
+        return;
+      }
+
+      const expires = new Date(data.expires_at).toLocaleString();
+      setResetNotice(data.code
+        ? `Reset code for ${target.username}: ${data.code} (valid until ${expires}). Pass it on to the user; it is shown only once.`
+        : `A reset code was sent to ${target.username} (valid until ${expires}).`);
+    } catch (error) {
+      setError('Failed to reset password');
+    }
+  };
+
+  const handleResetTwoFactor = async (target: User) => {
+    if (!confirm(`Reset two-factor authentication for ${target.username}? They will have to set it up again.`)) {
+      return;
+    }
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/users/${target.id}/2fa`, {
+        method: 'DELETE',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        setError(data.error || 'Failed to reset two-factor authentication');
+        return;
+      }
+
+      await loadUsers();
+    } catch (error) {
+      setError('Failed to reset two-factor authentication');
+    }
+  };
+
   const handleCancel = () => {
     setShowForm(false);
     setEditingUser(null);
@@ -171,7 +317,9 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       email: '',
       password: '',
       role: 'user',
-      is_active: true
+      is_active: true,
+      room_ids: [],
+      floors: ''
     });
   };
 
@@ -240,6 +388,21 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
           </div>
         )}
 
+        {/* Password Reset Code */}
+        {resetNotice && (
+          <div className="mb-6 bg-green-50 border border-green-200 rounded-xl p-4">
+            <div className="flex items-center justify-between">
+              <div className="flex items-center">
+                <KeyRound className="h-5 w-5 text-green-500 mr-2" />
+                <p className="text-sm text-green-800 font-medium">{resetNotice}</p>
+              </div>
+              <button onClick={() => setResetNotice('')} className="text-sm text-green-700 hover:text-green-900">
+                Dismiss
+              </button>
+            </div>
+          </div>
+        )}
+
         {/* Add/Edit User Form */}
         {showForm && (
           <div className="bg-white rounded-2xl shadow-sm border border-gray-200 p-6 mb-6">
@@ -275,9 +438,11 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
               </div>
               
               <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                {/* Existing users get a new password through a reset code */}
+                {!editingUser && (
                 <div>
                   <label className="block text-sm font-medium text-gray-700 mb-2">
-                    Password {editingUser && '(leave blank to keep current)'}
+                    Password
                   </label>
                   <div className="relative">
                     <input
@@ -285,7 +450,7 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                       value={formData.password}
                       onChange={(e) => setFormData({...formData, password: e.target.value})}
                       className="block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-orange-500 focus:border-orange-500"
-                      placeholder={editingUser ? 'Leave blank to keep current' : 'Enter password'}
+                      placeholder="Enter password"
                     />
                     <button
                       type="button"
@@ -299,7 +464,9 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                       )}
                     </button>
                   </div>
+                  <p className="mt-1 text-xs text-gray-500">The user must change it at first login</p>
                 </div>
+                )}
                 <div>
                   <label className="block text-sm font-medium text-gray-700 mb-2">
                     Role
@@ -307,14 +474,54 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                   <select
                     value={formData.role}
                     onChange={(e) => setFormData({...formData, role: e.target.value})}
-                    className="block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-orange-500 focus:border-orange-500"
+                    disabled={editingSelf}
+                    className="block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-orange-500 focus:border-orange-500 disabled:bg-gray-100"
                   >
-                    <option value="user">User</option>
-                    <option value="supervisor">Supervisor</option>
-                    <option value="manager">Manager</option>
+                    {roleOptions.map((option) => (
+                      <option key={option.value} value={option.value}>{option.label}</option>
+                    ))}
                   </select>
                 </div>
               </div>
+
+              {/* Visibility: users see their rooms, managers their floors */}
+              {formData.role === 'manager' && (
+                <div>
+                  <label htmlFor="floors" className="block text-sm font-medium text-gray-700 mb-2">
+                    Floors
+                  </label>
+                  <input
+                    type="text"
+                    id="floors"
+                    value={formData.floors}
+                    onChange={(e) => setFormData({...formData, floors: e.target.value})}
+                    disabled={editingSelf}
+                    className="block w-full px-3 py-2 border border-gray-300 rounded-xl focus:outline-none focus:ring-2 focus:ring-orange-500 focus:border-orange-500 disabled:bg-gray-100"
+                    placeholder="Comma separated, e.g. 1, 2"
+                  />
+                </div>
+              )}
+              {formData.role === 'user' && rooms.length > 0 && (
+                <div>
+                  <label className="block text-sm font-medium text-gray-700 mb-2">
+                    Assigned Rooms
+                  </label>
+                  <div className="flex flex-wrap gap-2">
+                    {rooms.map((room) => (
+                      <label key={room.id} className="flex items-center space-x-2 px-3 py-1 border border-gray-300 rounded-xl text-sm">
+                        <input
+                          type="checkbox"
+                          checked={formData.room_ids.includes(room.id)}
+                          onChange={() => handleRoomToggle(room.id)}
+                          disabled={editingSelf}
+                          className="w-4 h-4 text-orange-600 focus:ring-orange-500 border-gray-300 rounded"
+                        />
+                        <span>{room.room_number}</span>
+                      </label>
+                    ))}
+                  </div>
+                </div>
+              )}
               
               <div className="flex items-center">
                 <input
@@ -322,6 +529,7 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                   id="is_active"
                   checked={formData.is_active}
                   onChange={(e) => setFormData({...formData, is_active: e.target.checked})}
+                  disabled={editingSelf}
                   className="w-4 h-4 text-orange-600 focus:ring-orange-500 border-gray-300 rounded focus:ring-2"
                 />
                 <label htmlFor="is_active" className="ml-2 block text-sm text-gray-900">
@@ -408,24 +616,65 @@ const UserManagement: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                       }`}>
                         {user.is_active ? 'Active' : 'Inactive'}
                       </span>
+                      {isLocked(user) && (
+                        <span className="ml-2 inline-flex px-2 py-1 text-xs font-semibold rounded-full bg-yellow-100 text-yellow-800">
+                          Locked
+                        </span>
+                      )}
+                      {user.two_factor_enabled && (
+                        <span className="ml-2 inline-flex px-2 py-1 text-xs font-semibold rounded-full bg-indigo-100 text-indigo-800">
+                          2FA
+                        </span>
+                      )}
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-500">
                       {new Date(user.created_at).toLocaleDateString()}
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap text-right text-sm font-medium">
                       <div className="flex justify-end space-x-2">
-                        <button
-                          onClick={() => handleEdit(user)}
-                          className="text-indigo-600 hover:text-indigo-900"
-                        >
-                          <Edit className="h-4 w-4" />
-                        </button>
-                        <button
-                          onClick={() => handleDelete(user.id)}
-                          className="text-red-600 hover:text-red-900"
-                        >
-                          <Trash2 className="h-4 w-4" />
-                        </button>
+                        {canUnlock && isLocked(user) && (
+                          <button
+                            onClick={() => handleUnlock(user.id)}
+                            className="text-yellow-600 hover:text-yellow-900"
+                            title="Unlock"
+                          >
+                            <Unlock className="h-4 w-4" />
+                          </button>
+                        )}
+                        {canResetPassword(user) && (
+                          <button
+                            onClick={() => handleResetPassword(user)}
+                            className="text-green-600 hover:text-green-900"
+                            title="Reset password"
+                          >
+                            <KeyRound className="h-4 w-4" />
+                          </button>
+                        )}
+                        {canResetPassword(user) && user.two_factor_enabled && (
+                          <button
+                            onClick={() => handleResetTwoFactor(user)}
+                            className="text-orange-600 hover:text-orange-900"
+                            title="Reset two-factor authentication"
+                          >
+                            <ShieldOff className="h-4 w-4" />
+                          </button>
+                        )}
+                        {(isSelf(user) || canManage(user)) && (
+                          <button
+                            onClick={() => handleEdit(user)}
+                            className="text-indigo-600 hover:text-indigo-900"
+                          >
+                            <Edit className="h-4 w-4" />
+                          </button>
+                        )}
+                        {!isSelf(user) && canManage(user) && (
+                          <button
+                            onClick={() => handleDelete(user.id)}
+                            className="text-red-600 hover:text-red-900"
+                          >
+                            <Trash2 className="h-4 w-4" />
+                          </button>
+                        )}
                       </div>
                     </td>
                   </tr>
@@ -1,20 +1,31 @@
 'use client';
 
-import React, { createContext, useContext, useState, useEffect } from 'react';
+import React, { createContext, useContext, useState, useEffect, useCallback } from 'react';
 
 interface User {
   id: number;
   username: string;
   email: string;
   role: string;
+  permissions?: string[];
+  must_change_password?: boolean;
+  two_factor_enabled?: boolean;
+  two_factor_setup_required?: boolean;
+}
+
+// Refresh details returned alongside an access token
+interface SessionTokens {
+  refresh_token?: string;
+  expires_in?: number;
 }
 
 interface AuthContextType {
   user: User | null;
   token: string | null;
   login: (username: string, password: string) => Promise<void>;
-  loginWithToken: (token: string, userData?: User) => Promise<void>;
+  loginWithToken: (token: string, userData?: User, session?: SessionTokens) => Promise<void>;
   logout: () => void;
+  updateUser: (changes: Partial<User>) => void;
   isAuthenticated: boolean;
 }
 
@@ -46,6 +57,73 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
   const [user, setUser] = useState<User | null>(null);
   const [token, setToken] = useState<string | null>(null);
   const [isAuthenticated, setIsAuthenticated] = useState(false);
+  const [tokenExpiresAt, setTokenExpiresAt] = useState<number | null>(null);
+
+  // Access tokens are short-lived; remember when to renew them
+  const storeTokens = useCallback((accessToken: string, session?: SessionTokens) => {
+    setToken(accessToken);
+    localStorage.setItem('token', accessToken);
+    if (session?.refresh_token) {
+      localStorage.setItem('refreshToken', session.refresh_token);
+    }
+    if (session?.expires_in) {
+      const expiresAt = Date.now() + session.expires_in * 1000;
+      setTokenExpiresAt(expiresAt);
+      localStorage.setItem('tokenExpiresAt', String(expiresAt));
+    }
+  }, []);
+
+  const clearSession = useCallback(() => {
+    setUser(null);
+    setToken(null);
+    setTokenExpiresAt(null);
+    setIsAuthenticated(false);
+    localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    localStorage.removeItem('tokenExpiresAt');
+    localStorage.removeItem('user');
+  }, []);
+
+  // Exchange the refresh token for a new access token, or sign out if the
+  // session has ended
+  const refreshSession = useCallback(async () => {
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (!refreshToken) {
+      return;
+    }
+
+    try {
+      const response = await fetch(`${getApiUrl()}/api/auth/refresh`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+
+      if (response.status === 401) {
+        clearSession();
+        return;
+      }
+      if (!response.ok) {
+        throw new Error('Failed to refresh session');
+      }
+
+      const data = await response.json();
+      storeTokens(data.token, data);
+    } catch (error) {
+      console.error('Session refresh error:', error);
+    }
+  }, [clearSession, storeTokens]);
+
+  // Renew the access token a minute before it expires
+  useEffect(() => {
+    if (!tokenExpiresAt) {
+      return;
+    }
+    const timer = setTimeout(refreshSession, Math.max(tokenExpiresAt - Date.now() - 60000, 0));
+    return () => clearTimeout(timer);
+  }, [tokenExpiresAt, refreshSession]);
 
   useEffect(() => {
     // Check for stored token on app load
@@ -57,6 +135,11 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
         setToken(storedToken);
         setUser(JSON.parse(storedUser));
         setIsAuthenticated(true);
+
+        const storedExpiry = localStorage.getItem('tokenExpiresAt');
+        if (storedExpiry) {
+          setTokenExpiresAt(Number(storedExpiry));
+        }
       } catch (error) {
         console.error('Error parsing stored user data:', error);
         localStorage.removeItem('token');
@@ -84,12 +167,11 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
 
       const data = await response.json();
       
-      setToken(data.token);
+      storeTokens(data.token, data);
       setUser(data.user);
       setIsAuthenticated(true);
       
       // Store in localStorage
-      localStorage.setItem('token', data.token);
       localStorage.setItem('user', JSON.stringify(data.user));
       
     } catch (error) {
@@ -98,22 +180,36 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     }
   };
 
-  const loginWithToken = async (token: string, userData?: User) => {
-    setToken(token);
+  const loginWithToken = async (token: string, userData?: User, session?: SessionTokens) => {
+    storeTokens(token, session);
     if (userData) {
       setUser(userData);
       localStorage.setItem('user', JSON.stringify(userData));
     }
     setIsAuthenticated(true);
-    localStorage.setItem('token', token);
+  };
+
+  // Apply changes made to the signed in user, e.g. after a password change
+  const updateUser = (changes: Partial<User>) => {
+    if (!user) {
+      return;
+    }
+    const updated = { ...user, ...changes };
+    setUser(updated);
+    localStorage.setItem('user', JSON.stringify(updated));
   };
 
   const logout = () => {
-    setUser(null);
-    setToken(null);
-    setIsAuthenticated(false);
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+    // Revoke the session server-side; the local state is cleared regardless
+    if (token) {
+      fetch(`${getApiUrl()}/api/auth/logout`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+      }).catch((error) => console.error('Logout error:', error));
+    }
+    clearSession();
   };
 
   return (
@@ -123,6 +219,7 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
       login,
       loginWithToken,
       logout,
+      updateUser,
       isAuthenticated,
     }}>
       {children}
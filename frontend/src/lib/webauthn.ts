@@ -0,0 +1,91 @@
+// Helpers for passing WebAuthn options and credentials between the browser
+// API, which works with ArrayBuffers, and the backend, which sends and
+// expects base64url strings.
+
+const toBuffer = (value: string): ArrayBuffer => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+};
+
+const toBase64Url = (buffer: ArrayBuffer | null): string | undefined => {
+  if (!buffer) {
+    return undefined;
+  }
+  const bytes = new Uint8Array(buffer);
+  let binary = '';
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+};
+
+// eslint-disable-next-line @typescript-eslint/no-explicit-any
+type ServerOptions = { publicKey: any };
+
+const toDescriptors = (list?: { id: string; type: PublicKeyCredentialType; transports?: AuthenticatorTransport[] }[]) =>
+  list?.map((descriptor) => ({ ...descriptor, id: toBuffer(descriptor.id) }));
+
+export const isWebAuthnSupported = () =>
+  typeof window !== 'undefined' && !!window.PublicKeyCredential;
+
+// Registers a new passkey on this device from the backend's options
+export const createCredential = async (options: ServerOptions) => {
+  const publicKey = options.publicKey;
+  const credential = await navigator.credentials.create({
+    publicKey: {
+      ...publicKey,
+      challenge: toBuffer(publicKey.challenge),
+      user: { ...publicKey.user, id: toBuffer(publicKey.user.id) },
+      excludeCredentials: toDescriptors(publicKey.excludeCredentials),
+    },
+  }) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('Passkey registration was cancelled');
+  }
+
+  const response = credential.response as AuthenticatorAttestationResponse;
+  return {
+    id: credential.id,
+    rawId: toBase64Url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64Url(response.clientDataJSON),
+      attestationObject: toBase64Url(response.attestationObject),
+      transports: response.getTransports?.() ?? [],
+    },
+  };
+};
+
+// Signs the backend's login challenge with a passkey on this device
+export const getCredential = async (options: ServerOptions) => {
+  const publicKey = options.publicKey;
+  const credential = await navigator.credentials.get({
+    publicKey: {
+      ...publicKey,
+      challenge: toBuffer(publicKey.challenge),
+      allowCredentials: toDescriptors(publicKey.allowCredentials),
+    },
+  }) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('Passkey sign in was cancelled');
+  }
+
+  const response = credential.response as AuthenticatorAssertionResponse;
+  return {
+    id: credential.id,
+    rawId: toBase64Url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64Url(response.clientDataJSON),
+      authenticatorData: toBase64Url(response.authenticatorData),
+      signature: toBase64Url(response.signature),
+      userHandle: toBase64Url(response.userHandle),
+    },
+  };
+};